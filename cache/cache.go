@@ -1,4 +1,23 @@
 package cache
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache is a key value store with expiration shared by the replicas of a service,
+// a ttl of zero keeps the key until it is deleted
 type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX sets the key only if it does not exist and reports whether it was set
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+	// CompareAndDelete deletes the key only if it holds value
+	CompareAndDelete(ctx context.Context, key string, value []byte) (bool, error)
+	// CompareAndExpire resets the ttl of the key only if it holds value
+	CompareAndExpire(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
 }
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type (
+	item struct {
+		value     []byte
+		expiresAt time.Time
+	}
+
+	// MemoryCache is a Cache local to the process, it suits tests and single replica services
+	MemoryCache struct {
+		mu        sync.Mutex
+		items     map[string]item
+		lastSweep time.Time
+	}
+)
+
+var _ Cache = (*MemoryCache)(nil)
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		items:     map[string]item{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	it, ok := c.get(key, time.Now())
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(it.value), nil
+}
+
+func (c *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.sweep(now)
+	c.items[key] = newItem(value, ttl, now)
+	return nil
+}
+
+func (c *MemoryCache) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := c.get(key, now); ok {
+		return false, nil
+	}
+
+	c.sweep(now)
+	c.items[key] = newItem(value, ttl, now)
+	return true, nil
+}
+
+func (c *MemoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+	return nil
+}
+
+func (c *MemoryCache) CompareAndDelete(_ context.Context, key string, value []byte) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	it, ok := c.get(key, time.Now())
+	if !ok || !bytes.Equal(it.value, value) {
+		return false, nil
+	}
+
+	delete(c.items, key)
+	return true, nil
+}
+
+func (c *MemoryCache) CompareAndExpire(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	it, ok := c.get(key, now)
+	if !ok || !bytes.Equal(it.value, value) {
+		return false, nil
+	}
+
+	c.items[key] = newItem(it.value, ttl, now)
+	return true, nil
+}
+
+func (c *MemoryCache) get(key string, now time.Time) (item, bool) {
+	it, ok := c.items[key]
+	if !ok {
+		return item{}, false
+	}
+	if it.expired(now) {
+		delete(c.items, key)
+		return item{}, false
+	}
+	return it, true
+}
+
+// sweep drops the expired keys never read again, at most once per sweep interval
+func (c *MemoryCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < sweepInterval {
+		return
+	}
+	c.lastSweep = now
+
+	for key, it := range c.items {
+		if it.expired(now) {
+			delete(c.items, key)
+		}
+	}
+}
+
+func newItem(value []byte, ttl time.Duration, now time.Time) item {
+	it := item{value: bytes.Clone(value)}
+	if ttl > 0 {
+		it.expiresAt = now.Add(ttl)
+	}
+	return it
+}
+
+func (it item) expired(now time.Time) bool {
+	return !it.expiresAt.IsZero() && !now.Before(it.expiresAt)
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The comparisons run in scripts so the value is checked and the key changed atomically
+
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+var compareAndExpireScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+else
+	redis.call('PERSIST', KEYS[1])
+end
+return 1
+`)
+
+// RedisCache is a Cache kept in a Redis compatible server (Redis, Valkey, KeyDB or
+// Dragonfly), the replicas of a service sharing the server share the keys, the locks and
+// the persisted queries among them
+type RedisCache struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+var _ Cache = (*RedisCache)(nil)
+
+// NewRedisCache returns a cache keeping the keys under prefix, e.g. the name of the
+// service, the keys are not prefixed when it is empty
+func NewRedisCache(client redis.UniversalClient, prefix string) *RedisCache {
+	return &RedisCache{client: client, prefix: prefix}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, c.key(key), value, expiration(ttl)).Err()
+}
+
+func (c *RedisCache) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, c.key(key), value, expiration(ttl)).Result()
+}
+
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+	return c.client.Del(ctx, c.key(key)).Err()
+}
+
+func (c *RedisCache) CompareAndDelete(ctx context.Context, key string, value []byte) (bool, error) {
+	n, err := compareAndDeleteScript.Run(ctx, c.client, []string{c.key(key)}, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (c *RedisCache) CompareAndExpire(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	ms := expiration(ttl).Milliseconds()
+	if ttl > 0 {
+		ms = max(ms, 1)
+	}
+	n, err := compareAndExpireScript.Run(ctx, c.client, []string{c.key(key)}, value, ms).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (c *RedisCache) key(key string) string {
+	if c.prefix == "" {
+		return key
+	}
+	return c.prefix + ":" + key
+}
+
+// expiration returns ttl for the client, a ttl of zero keeps the key like in MemoryCache
+// and go-redis takes a negative one as KEEPTTL
+func expiration(ttl time.Duration) time.Duration {
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
@@ -0,0 +1,30 @@
+// Command openapi writes the OpenAPI document of the REST APIs shipped with the platform,
+// like the administration of the job queue, for contract tests:
+//
+//	go run ./cmd/openapi -o openapi.json
+package main
+
+import (
+	"log"
+	"os"
+
+	myjobs "github.com/gianglt2198/platforms/jobs"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/gofiber/fiber/v2"
+)
+
+func main() {
+	app := fiber.New()
+	myjobs.NewAdminHandler(myjobs.NewClient(nil)).Register(app.Group("/api"))
+
+	err := openapi.Dump(os.Args[1:], func() *openapi.Document {
+		return openapi.Generate(app, openapi.Config{
+			Title:   "Platform",
+			Problem: routes.Problem{},
+		})
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,7 @@
+package common
+
+import "context"
+
+// Handler is a use case, the transports serve it with their own adapters, e.g.
+// routes.Usecase over REST, mygrpc.Unary over gRPC and mygraphql.Query over GraphQL
+type Handler[T any, R any] func(context.Context, T) (R, error)
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"context"
+	"slices"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	ID          string         `json:"id"`
+	Email       string         `json:"email,omitempty"`
+	Name        string         `json:"name,omitempty"`
+	Type        string         `json:"type,omitempty"`
+	TenantID    string         `json:"tenant_id,omitempty"`
+	Roles       []string       `json:"roles,omitempty"`
+	Permissions []string       `json:"permissions,omitempty"`
+	Scopes      []string       `json:"scopes,omitempty"`
+	Claims      map[string]any `json:"-"`
+}
+
+// WithPrincipal returns ctx carrying p under KEY_AUTH_USER
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, KEY_AUTH_USER, p)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal or by the
+// authentication middleware
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(KEY_AUTH_USER).(*Principal)
+	return p, ok && p != nil
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+func (p *Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// AsMap returns the principal in the shape of the user map read by the repositories
+func (p *Principal) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"id":          p.ID,
+		"email":       p.Email,
+		"name":        p.Name,
+		"type":        p.Type,
+		"tenant_id":   p.TenantID,
+		"roles":       p.Roles,
+		"permissions": p.Permissions,
+	}
+}
@@ -0,0 +1,20 @@
+package mydatabase
+
+import (
+	"context"
+
+	"github.com/gianglt2198/platforms/common"
+)
+
+// authUser returns the user stamped on the created, updated and deleted rows, either the
+// map stored under KEY_AUTH_USER or the principal set by the authentication middleware.
+// It is nil for an anonymous context
+func authUser(ctx context.Context) map[string]interface{} {
+	if user, ok := ctx.Value(KEY_AUTH_USER).(map[string]interface{}); ok {
+		return user
+	}
+	if p, ok := common.PrincipalFromContext(ctx); ok {
+		return p.AsMap()
+	}
+	return nil
+}
@@ -1,12 +1,13 @@
 package mydatabase
 
 import (
+	"context"
 	"log"
 	"os"
 	"sync"
 	"time"
 
-	"github.com/gianglt2198/platforms/pkg/utils"
+	"github.com/gianglt2198/platforms/pkg/resilience"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -31,7 +32,15 @@ func ProvideDb(cfg *DBConfig) *gorm.DB {
 			logLevel = logger.Info
 		}
 
-		db, err := utils.BackoffRetryMechanism(5, func() (*gorm.DB, error) {
+		retry := resilience.NewRetry(resilience.RetryConfig{
+			Name:        "db-connect",
+			MaxAttempts: 5,
+			BaseDelay:   time.Second,
+			MaxDelay:    10 * time.Second,
+		})
+
+		var err error
+		db, err = resilience.Do(context.Background(), retry, func(context.Context) (*gorm.DB, error) {
 			return gorm.Open(postgres.Open(cfg.Connection), &gorm.Config{
 				Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
 					SlowThreshold:             time.Second,
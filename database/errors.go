@@ -0,0 +1,12 @@
+package mydatabase
+
+import myerrors "github.com/gianglt2198/platforms/errors"
+
+// queryError wraps an error of gorm, the constraint violations of Postgres are
+// translated to their own codes so they reach the clients as 409, 422 or 400
+func queryError(err error) *myerrors.AppError {
+	if appErr := myerrors.FromPostgres(err); appErr != nil {
+		return appErr
+	}
+	return myerrors.QueryInvalid(err.Error()).WithCause(err)
+}
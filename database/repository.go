@@ -6,7 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strings"
+	"strconv"
 	"time"
 
 	"gorm.io/gorm"
@@ -114,7 +114,27 @@ func (r *Repository[T]) CreateOne(ctx context.Context, entity *T) (*T, *myerrors
 	err := db.WithContext(ctx).Create(entity).Error
 
 	if err != nil {
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
+	}
+
+	return entity, nil
+}
+
+// CreateOneWithClauses creates entity with the extra clauses of the statement, e.g. an
+// ON CONFLICT with a target condition, its errors are translated like the ones of CreateOne
+func (r *Repository[T]) CreateOneWithClauses(ctx context.Context, entity *T, clauses ...clause.Expression) (*T, *myerrors.AppError) {
+	db := r.db
+
+	if currentTran, ok := ctx.Value(KEY_CURRENT_TRAN).(*gorm.DB); ok {
+		if currentTran != nil {
+			db = currentTran
+		}
+	}
+
+	err := db.WithContext(ctx).Clauses(clauses...).Create(entity).Error
+
+	if err != nil {
+		return nil, queryError(err)
 	}
 
 	return entity, nil
@@ -129,7 +149,7 @@ func (r *Repository[T]) Create(ctx context.Context, entities ...*T) ([]*T, *myer
 		}
 	}
 
-	currentUser := ctx.Value(KEY_AUTH_USER).(map[string]interface{})
+	currentUser := authUser(ctx)
 	if currentUser != nil {
 		for _, e := range entities {
 			now := time.Now().UTC()
@@ -150,7 +170,7 @@ func (r *Repository[T]) Create(ctx context.Context, entities ...*T) ([]*T, *myer
 	err := db.WithContext(ctx).Create(&entities).Error
 
 	if err != nil {
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return entities, nil
@@ -165,7 +185,7 @@ func (r *Repository[T]) CreateWithOnConflicting(ctx context.Context, conflictCol
 		}
 	}
 
-	currentUser := ctx.Value(KEY_AUTH_USER).(map[string]interface{})
+	currentUser := authUser(ctx)
 	if currentUser != nil {
 		for _, e := range entities {
 			now := time.Now().UTC()
@@ -196,7 +216,7 @@ func (r *Repository[T]) CreateWithOnConflicting(ctx context.Context, conflictCol
 	}).Create(&entities).Error
 
 	if err != nil {
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return entities, nil
@@ -207,7 +227,7 @@ func (r *Repository[T]) UpdateById(ctx context.Context, id int, updatedFields *T
 
 	db := r.db
 
-	currentUser := ctx.Value(KEY_AUTH_USER).(map[string]interface{})
+	currentUser := authUser(ctx)
 
 	if currentUser != nil {
 		currentTime := time.Now().UTC()
@@ -223,7 +243,7 @@ func (r *Repository[T]) UpdateById(ctx context.Context, id int, updatedFields *T
 	}
 
 	if err != nil {
-		return myerrors.QueryInvalid(err.Error())
+		return queryError(err)
 	}
 
 	return nil
@@ -244,7 +264,7 @@ func (r *Repository[T]) UpdateBy(
 		}
 	}
 
-	currentUser := ctx.Value(KEY_AUTH_USER).(map[string]interface{})
+	currentUser := authUser(ctx)
 
 	if currentUser != nil {
 		currentTime := time.Now().UTC()
@@ -259,7 +279,7 @@ func (r *Repository[T]) UpdateBy(
 		Error
 
 	if err != nil {
-		return myerrors.QueryInvalid(err.Error())
+		return queryError(err)
 	}
 
 	return nil
@@ -277,7 +297,7 @@ func (r *Repository[T]) UpdateByMap(ctx context.Context, values map[string]inter
 	}
 
 	if hasAttribute(model, "DeletedAt") {
-		cond.Where = strings.Join([]string{cond.Where, " AND deleted_at IS NULL"}, " and ")
+		cond.Where = notDeleted(cond.Where)
 	}
 
 	if hasAttribute(model, "UpdatedAt") {
@@ -286,7 +306,7 @@ func (r *Repository[T]) UpdateByMap(ctx context.Context, values map[string]inter
 	}
 
 	if hasAttribute(model, "UpdatedBy") {
-		currentUser := ctx.Value(KEY_AUTH_USER).(map[string]interface{})
+		currentUser := authUser(ctx)
 
 		if currentUser != nil {
 			values["updated_by"] = currentUser["id"]
@@ -295,7 +315,7 @@ func (r *Repository[T]) UpdateByMap(ctx context.Context, values map[string]inter
 
 	err := db.WithContext(ctx).Model(&model).Where(cond.Where, cond.Params...).Updates(values).Error
 	if err != nil {
-		return myerrors.QueryInvalid(err.Error())
+		return queryError(err)
 	}
 
 	return nil
@@ -316,12 +336,12 @@ func (r *Repository[T]) DeleteById(ctx context.Context, id int) *myerrors.AppErr
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return myerrors.QueryInvalid("record not found")
 		}
-		return myerrors.QueryInvalid(err.Error())
+		return queryError(err)
 	}
 
 	var err error
 	if hasAttribute(entity, "DeletedAt") {
-		currentUser := ctx.Value(KEY_AUTH_USER).(map[string]interface{})
+		currentUser := authUser(ctx)
 
 		if currentUser != nil {
 			err = db.WithContext(ctx).
@@ -346,7 +366,7 @@ func (r *Repository[T]) DeleteById(ctx context.Context, id int) *myerrors.AppErr
 	}
 
 	if err != nil {
-		return myerrors.QueryInvalid(err.Error())
+		return queryError(err)
 	}
 
 	return nil
@@ -364,7 +384,7 @@ func (r *Repository[T]) DeleteBy(ctx context.Context, cond WhereOption) *myerror
 	}
 	var err error
 	if hasAttribute(entity, "DeletedAt") {
-		currentUser := ctx.Value(KEY_AUTH_USER).(map[string]interface{})
+		currentUser := authUser(ctx)
 
 		if currentUser != nil {
 			err = db.WithContext(ctx).Where(cond.Where, cond.Params...).
@@ -387,7 +407,7 @@ func (r *Repository[T]) DeleteBy(ctx context.Context, cond WhereOption) *myerror
 	}
 
 	if err != nil {
-		return myerrors.QueryInvalid(err.Error())
+		return queryError(err)
 	}
 
 	return nil
@@ -412,9 +432,9 @@ func (r *Repository[T]) FindById(ctx context.Context, id int) (*T, *myerrors.App
 	err := db.WithContext(ctx).Model(&entity).Where(cond, id).First(&entity).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, myerrors.QueryNotFound(err.Error())
+			return nil, myerrors.QueryNotFound(err.Error()).WithCause(err)
 		}
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return &entity, nil
@@ -443,7 +463,7 @@ func (r *Repository[T]) FindAll(ctx context.Context) (*[]T, *myerrors.AppError)
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return &entities, nil
 		}
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return &entities, nil
@@ -476,7 +496,7 @@ func (r *Repository[T]) PaginationQuery(ctx context.Context, option *PaginationQ
 
 	if err != nil {
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return 0, nil, myerrors.QueryInvalid(err.Error())
+			return 0, nil, queryError(err)
 		}
 	}
 
@@ -494,7 +514,7 @@ func (r *Repository[T]) PaginationQuery(ctx context.Context, option *PaginationQ
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return 0, &items, nil
 		}
-		return 0, &items, myerrors.QueryInvalid(err.Error())
+		return 0, &items, queryError(err)
 	}
 
 	defer rows.Close()
@@ -511,7 +531,7 @@ func (r *Repository[T]) FindOneBy(ctx context.Context, option *FindOption) (*T,
 
 	cond := option.Where
 	if hasAttribute(entity, "DeletedAt") && !option.ExcludeDeleted {
-		cond = strings.Join([]string{option.Where, " AND deleted_at IS NULL"}, " and ")
+		cond = notDeleted(option.Where)
 	}
 
 	db := r.db
@@ -544,9 +564,9 @@ func (r *Repository[T]) FindOneBy(ctx context.Context, option *FindOption) (*T,
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, myerrors.QueryNotFound(err.Error())
+			return nil, myerrors.QueryNotFound(err.Error()).WithCause(err)
 		}
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return &entity, nil
@@ -569,7 +589,7 @@ func (r *Repository[T]) FindBy(ctx context.Context, option *FindOption) (*[]T, *
 	if option.Where != "" {
 		cond := option.Where
 		if hasAttribute(model, "DeletedAt") && !option.ExcludeDeleted {
-			cond = strings.Join([]string{option.Where, " AND deleted_at IS NULL"}, " and ")
+			cond = notDeleted(option.Where)
 		}
 		query = query.Where(cond, option.Params...)
 	}
@@ -614,7 +634,7 @@ func (r *Repository[T]) FindBy(ctx context.Context, option *FindOption) (*[]T, *
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return &entities, nil
 		}
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return &entities, nil
@@ -660,7 +680,7 @@ func (r *Repository[T]) CountBy(ctx context.Context, cond WhereOption) int {
 	if cond.Where != "" {
 		where := cond.Where
 		if hasAttribute(entity, "DeletedAt") {
-			where = strings.Join([]string{cond.Where, " AND deleted_at IS NULL"}, " and ")
+			where = notDeleted(cond.Where)
 		}
 
 		query = query.Where(where, cond.Params...)
@@ -679,7 +699,7 @@ func (r *Repository[T]) FirstOrInitBy(ctx context.Context, option FindOption, en
 	if option.Where != "" {
 		cond := option.Where
 		if hasAttribute(model, "DeletedAt") {
-			cond = strings.Join([]string{option.Where, " AND deleted_at IS NULL"}, " and ")
+			cond = notDeleted(option.Where)
 		}
 		query = query.Where(cond, option.Params...)
 	}
@@ -693,7 +713,7 @@ func (r *Repository[T]) FirstOrInitBy(ctx context.Context, option FindOption, en
 	err := query.FirstOrInit(entity).Error
 
 	if err != nil {
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return entity, nil
@@ -715,7 +735,7 @@ func (r *Repository[T]) FirstOrCreateBy(ctx context.Context, option FindOption,
 	if option.Where != "" {
 		cond := option.Where
 		if hasAttribute(model, "DeletedAt") {
-			cond = strings.Join([]string{option.Where, " AND deleted_at IS NULL"}, " and ")
+			cond = notDeleted(option.Where)
 		}
 		query = query.Where(cond, option.Params...)
 	}
@@ -726,7 +746,7 @@ func (r *Repository[T]) FirstOrCreateBy(ctx context.Context, option FindOption,
 		}
 	}
 
-	currentUser := ctx.Value(KEY_AUTH_USER).(map[string]interface{})
+	currentUser := authUser(ctx)
 
 	if currentUser != nil {
 		SetAttribute(entity, "CreatedAt", time.Now().UTC())
@@ -736,7 +756,7 @@ func (r *Repository[T]) FirstOrCreateBy(ctx context.Context, option FindOption,
 	err := query.FirstOrCreate(entity).Error
 
 	if err != nil {
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return entity, nil
@@ -753,7 +773,7 @@ func (r *Repository[T]) GetExistsIdsByIds(ctx context.Context, ids []uint) (*[]u
 	err := r.db.WithContext(ctx).Model(&model).Where("id IN ?", ids).Find(&selectedIds).Error
 
 	if err != nil {
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	var existsIds []uint
@@ -777,12 +797,21 @@ func (r *Repository[T]) IsExistById(ctx context.Context, id int) (*bool, *myerro
 	err := db.Find(&exists).Error
 
 	if err != nil {
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return &exists, nil
 }
 
+// notDeleted restricts where to the rows not soft deleted, where is wrapped so its OR
+// conditions do not escape the restriction
+func notDeleted(where string) string {
+	if where == "" {
+		return "deleted_at IS NULL"
+	}
+	return "(" + where + ") AND deleted_at IS NULL"
+}
+
 func hasAttribute[T any](obj T, attributeName string) bool {
 	val := reflect.ValueOf(obj)
 	if val.Kind() == reflect.Ptr {
@@ -804,5 +833,56 @@ func SetAttribute[T any](obj T, fieldName string, value interface{}) {
 		return
 	}
 
-	field.Set(reflect.ValueOf(value))
+	// The id of a principal is a string while the audit columns are often numbers
+	if converted, ok := convertValue(reflect.ValueOf(value), field.Type()); ok {
+		field.Set(converted)
+	}
+}
+
+func convertValue(val reflect.Value, typ reflect.Type) (reflect.Value, bool) {
+	if !val.IsValid() {
+		return val, false
+	}
+	if val.Type().AssignableTo(typ) {
+		return val, true
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		inner, ok := convertValue(val, typ.Elem())
+		if !ok {
+			return val, false
+		}
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(inner)
+		return ptr, true
+	}
+
+	switch {
+	case val.Kind() == reflect.String && val.CanConvert(typ) && typ.Kind() == reflect.String:
+		return val.Convert(typ), true
+	case val.Kind() == reflect.String && isInt(typ.Kind()):
+		n, err := strconv.ParseInt(val.String(), 10, 64)
+		if err != nil {
+			return val, false
+		}
+		return reflect.ValueOf(n).Convert(typ), true
+	case val.Kind() == reflect.String && isUint(typ.Kind()):
+		n, err := strconv.ParseUint(val.String(), 10, 64)
+		if err != nil {
+			return val, false
+		}
+		return reflect.ValueOf(n).Convert(typ), true
+	case (isInt(val.Kind()) || isUint(val.Kind())) && (isInt(typ.Kind()) || isUint(typ.Kind())):
+		return val.Convert(typ), true
+	}
+
+	return val, false
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUint(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uint64
 }
@@ -36,7 +36,7 @@ func (t *Transaction[T]) Execute(ctx context.Context, f func(context.Context) (*
 
 	if err := tx.Error; err != nil {
 		slog.Error("[Transaction]fail to begin tran", slog.Any("err", err))
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	slog.Info("[Transaction]--executing...")
@@ -53,7 +53,7 @@ func (t *Transaction[T]) Execute(ctx context.Context, f func(context.Context) (*
 	slog.Info("[Transaction]--commit tran")
 	err := tx.Commit().Error
 	if err != nil {
-		return nil, myerrors.QueryInvalid(err.Error())
+		return nil, queryError(err)
 	}
 
 	return result, nil
@@ -0,0 +1,246 @@
+package myerrors
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultLocale = "en"
+
+//go:embed locales/*.yaml
+var embeddedLocales embed.FS
+
+type (
+	// catalogFile is the layout of a locale file, the validation messages are keyed by
+	// validator tag and may use the {field} and {param} placeholders
+	catalogFile struct {
+		Locale     string            `yaml:"locale"`
+		Errors     map[string]string `yaml:"errors"`
+		Validation map[string]string `yaml:"validation"`
+	}
+
+	// Catalog holds the messages of the error codes and of the validation tags per locale
+	Catalog struct {
+		mu         sync.RWMutex
+		fallback   string
+		errors     map[string]map[string]string
+		validation map[string]map[string]string
+	}
+)
+
+var (
+	defaultCatalog     *Catalog
+	defaultCatalogOnce sync.Once
+)
+
+// NewCatalog returns an empty catalog falling back to fallback for the missing messages
+func NewCatalog(fallback string) *Catalog {
+	return &Catalog{
+		fallback:   fallback,
+		errors:     map[string]map[string]string{},
+		validation: map[string]map[string]string{},
+	}
+}
+
+// DefaultCatalog returns the catalog of the embedded locales, services can Load theirs in it
+func DefaultCatalog() *Catalog {
+	defaultCatalogOnce.Do(func() {
+		defaultCatalog = NewCatalog(DefaultLocale)
+		if err := defaultCatalog.Load(embeddedLocales, "locales/*.yaml"); err != nil {
+			panic(fmt.Sprintf("loading embedded error catalog: %v", err))
+		}
+	})
+	return defaultCatalog
+}
+
+// Load merges the locale files of fsys matching pattern, YAML or JSON, into the catalog
+func (c *Catalog) Load(fsys fs.FS, pattern string) error {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		// JSON is valid YAML
+		var file catalogFile
+		if err := yaml.Unmarshal(content, &file); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if file.Locale == "" {
+			file.Locale = strings.TrimSuffix(path.Base(name), path.Ext(name))
+		}
+
+		c.Add(file.Locale, file.Errors, file.Validation)
+	}
+
+	return nil
+}
+
+// Add merges the messages of a locale into the catalog
+func (c *Catalog) Add(locale string, errors, validation map[string]string) {
+	locale = normalizeLocale(locale)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	merge(c.errors, locale, errors)
+	merge(c.validation, locale, validation)
+}
+
+// Locales returns the locales having messages
+func (c *Catalog) Locales() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	locales := make([]string, 0, len(c.errors))
+	for locale := range c.errors {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Message returns the message of code in locale, then in the fallback locale
+func (c *Catalog) Message(locale, code string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lookup(c.errors, locale, code)
+}
+
+// Localize returns a copy of err with the message of its code in locale, err is
+// returned as is when the catalog has no message for its code
+func (c *Catalog) Localize(err *AppError, locale string) *AppError {
+	if err == nil {
+		return nil
+	}
+
+	message, ok := c.Message(locale, err.Code)
+	if !ok {
+		return err
+	}
+
+	localized := *err
+	localized.Message = message
+	return &localized
+}
+
+// FieldMessage returns the message of a validation tag for field in locale
+func (c *Catalog) FieldMessage(locale, tag, field, param string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	message, ok := c.lookup(c.validation, locale, tag)
+	if !ok {
+		message, ok = c.lookup(c.validation, locale, "default")
+	}
+	if !ok {
+		return field + " is invalid"
+	}
+
+	return strings.NewReplacer("{field}", field, "{param}", param).Replace(message)
+}
+
+// MatchLocale returns the best locale of the catalog for an Accept-Language header
+func (c *Catalog) MatchLocale(acceptLanguage string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if tag == "*" {
+			break
+		}
+		if _, ok := c.errors[tag]; ok {
+			return tag
+		}
+		if base, _, found := strings.Cut(tag, "-"); found {
+			if _, ok := c.errors[base]; ok {
+				return base
+			}
+		}
+	}
+
+	return c.fallback
+}
+
+func (c *Catalog) lookup(messages map[string]map[string]string, locale, key string) (string, bool) {
+	locale = normalizeLocale(locale)
+
+	candidates := []string{locale}
+	if base, _, found := strings.Cut(locale, "-"); found {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, c.fallback)
+
+	for _, l := range candidates {
+		if message, ok := messages[l][key]; ok {
+			return message, true
+		}
+	}
+	return "", false
+}
+
+func merge(dst map[string]map[string]string, locale string, src map[string]string) {
+	if len(src) == 0 {
+		return
+	}
+	if dst[locale] == nil {
+		dst[locale] = map[string]string{}
+	}
+	for k, v := range src {
+		dst[locale][k] = v
+	}
+}
+
+// parseAcceptLanguage returns the tags of the header ordered by decreasing quality
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		tags = append(tags, weighted{tag: normalizeLocale(tag), q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	res := make([]string, len(tags))
+	for i, t := range tags {
+		res[i] = t.tag
+	}
+	return res
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
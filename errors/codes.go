@@ -0,0 +1,129 @@
+package myerrors
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type Category string
+
+const (
+	CategoryValidation   Category = "validation"
+	CategoryNotFound     Category = "not_found"
+	CategoryConflict     Category = "conflict"
+	CategoryUnauthorized Category = "unauthorized"
+	CategoryForbidden    Category = "forbidden"
+	CategoryTimeout      Category = "timeout"
+	CategoryUnavailable  Category = "unavailable"
+	CategoryInternal     Category = "internal"
+)
+
+// CodeInfo is the registered meaning of an error code
+type CodeInfo struct {
+	Code     string   `json:"code"`
+	Status   int      `json:"status"`
+	Category Category `json:"category"`
+	// NatsStatus is the code set in the Nats-Service-Error-Code header of the replies,
+	// the HTTP status when empty
+	NatsStatus string `json:"nats_status"`
+	// Message is the default message of the errors created with New
+	Message string `json:"message"`
+}
+
+var (
+	codesMu sync.RWMutex
+	codes   = map[string]CodeInfo{}
+)
+
+func init() {
+	for _, info := range []CodeInfo{
+		{Code: "query.001", Status: http.StatusNotFound, Message: "record not found"},
+		{Code: "query.002", Status: http.StatusInternalServerError, Message: "query failed"},
+		{Code: "mq.001", Status: http.StatusGatewayTimeout, Message: "response from MQ took too long"},
+		{Code: "mq.002", Status: http.StatusUnauthorized, Message: "need authenticated for request."},
+		{Code: "mq.003", Status: http.StatusForbidden, Message: "need specific roles for request"},
+		{Code: "mq.004", Status: http.StatusForbidden, Message: "access with wrong user type"},
+		{Code: "payload.001", Status: http.StatusBadRequest, Message: "payload is invalid"},
+		{Code: "internal.001", Status: http.StatusInternalServerError, Message: "internal failure"},
+		{Code: "internal.002", Status: http.StatusGatewayTimeout, Message: "operation timed out"},
+		{Code: "db.001", Status: http.StatusConflict, Message: "resource already exists"},
+		{Code: "db.002", Status: http.StatusUnprocessableEntity, Message: "referenced resource does not exist"},
+		{Code: "db.003", Status: http.StatusBadRequest, Message: "required value is missing"},
+		{Code: "db.004", Status: http.StatusBadRequest, Message: "value violates a constraint"},
+		{Code: "auth.001", Status: http.StatusUnauthorized, Message: "authentication is required"},
+		{Code: "auth.002", Status: http.StatusUnauthorized, Message: "token is invalid"},
+		{Code: "auth.003", Status: http.StatusUnauthorized, Message: "token has expired"},
+		{Code: "ratelimit.001", Status: http.StatusTooManyRequests, Message: "too many requests"},
+		{Code: "idempotency.001", Status: http.StatusBadRequest, Message: "idempotency key is invalid"},
+		{Code: "idempotency.002", Status: http.StatusConflict, Message: "a request with the same idempotency key is in progress"},
+		{Code: "idempotency.003", Status: http.StatusUnprocessableEntity, Message: "idempotency key was used for another request"},
+		{Code: "graphql.001", Status: http.StatusBadRequest, Message: "query is invalid"},
+		{Code: "graphql.002", Status: http.StatusBadRequest, Message: "query is too deep"},
+		{Code: "graphql.003", Status: http.StatusBadRequest, Message: "query is too complex"},
+		{Code: "graphql.004", Status: http.StatusNotFound, Message: "persisted query not found"},
+		{Code: "graphql.005", Status: http.StatusBadRequest, Message: "persisted query hash does not match the query"},
+		{Code: "graphql.006", Status: http.StatusForbidden, Message: "only persisted queries are accepted"},
+		{Code: "graphql.007", Status: http.StatusRequestEntityTooLarge, Message: "query is too long"},
+	} {
+		RegisterCode(info)
+	}
+}
+
+// RegisterCode adds or replaces a code, the category and the NATS status default to
+// the ones of the HTTP status
+func RegisterCode(info CodeInfo) {
+	if info.Category == "" {
+		info.Category = CategoryOf(info.Status)
+	}
+	if info.NatsStatus == "" {
+		info.NatsStatus = strconv.Itoa(info.Status)
+	}
+
+	codesMu.Lock()
+	defer codesMu.Unlock()
+	codes[info.Code] = info
+}
+
+func LookupCode(code string) (CodeInfo, bool) {
+	codesMu.RLock()
+	defer codesMu.RUnlock()
+
+	info, ok := codes[code]
+	return info, ok
+}
+
+// Codes returns the registered codes sorted by code
+func Codes() []CodeInfo {
+	codesMu.RLock()
+	defer codesMu.RUnlock()
+
+	res := make([]CodeInfo, 0, len(codes))
+	for _, info := range codes {
+		res = append(res, info)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Code < res[j].Code })
+	return res
+}
+
+// CategoryOf returns the category matching an HTTP status
+func CategoryOf(status int) Category {
+	switch {
+	case status == http.StatusNotFound:
+		return CategoryNotFound
+	case status == http.StatusConflict:
+		return CategoryConflict
+	case status == http.StatusUnauthorized:
+		return CategoryUnauthorized
+	case status == http.StatusForbidden:
+		return CategoryForbidden
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return CategoryTimeout
+	case status == http.StatusServiceUnavailable || status == http.StatusTooManyRequests:
+		return CategoryUnavailable
+	case status >= 400 && status < 500:
+		return CategoryValidation
+	}
+	return CategoryInternal
+}
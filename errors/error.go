@@ -1,27 +1,103 @@
 package myerrors
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"runtime"
+	"strings"
 )
 
-type AppError struct {
-	Status  int    `json:"status"`
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Data    string `json:"data,omitempty"`
-}
+const maxStackDepth = 32
+
+type (
+	// FieldError describes why one field of the input was rejected
+	FieldError struct {
+		Field   string `json:"field"`
+		Code    string `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+
+	// Details is the structured context of an error
+	Details struct {
+		Fields   []FieldError   `json:"fields,omitempty"`
+		Metadata map[string]any `json:"metadata,omitempty"`
+		// Internal is the metadata describing the internals, e.g. the tables, it is never
+		// serialized and the responses only show it outside production
+		Internal map[string]any `json:"-"`
+	}
+
+	AppError struct {
+		Status   int      `json:"status"`
+		Code     string   `json:"code"`
+		Message  string   `json:"message"`
+		Data     string   `json:"data,omitempty"`
+		Category Category `json:"category,omitempty"`
+		Details  *Details `json:"details,omitempty"`
+
+		cause error
+		stack []uintptr
+	}
+)
 
 func (r *AppError) IsZero() bool {
 	return r.Status == 0
 }
 
+// Error returns the code and the message, followed by the cause unless the message is its
+// text already, e.g. PayloadInvalid(err.Error()).WithCause(err)
 func (r *AppError) Error() string {
+	if r.cause != nil && r.cause.Error() != r.Message {
+		return fmt.Sprintf("%s:%s: %v", r.Code, r.Message, r.cause)
+	}
 	return fmt.Sprintf("%s:%s", r.Code, r.Message)
 }
 
+// Unwrap returns the cause so errors.Is and errors.As see through the AppError
+func (r *AppError) Unwrap() error {
+	return r.cause
+}
+
+// Is matches AppErrors by code, e.g. errors.Is(err, myerrors.New("db.001", ""))
+func (r *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == r.Code
+}
+
 func NewAppError(code, message string, status int) *AppError {
-	return &AppError{Code: code, Message: message, Status: status}
+	category := CategoryOf(status)
+	if info, ok := LookupCode(code); ok && info.Status == status {
+		category = info.Category
+	}
+
+	return &AppError{
+		Code:     code,
+		Message:  message,
+		Status:   status,
+		Category: category,
+		stack:    callers(),
+	}
+}
+
+// New returns an error with the status and category registered for code, the message
+// registered is used when message is empty
+func New(code, message string) *AppError {
+	info, ok := LookupCode(code)
+	if !ok {
+		info = CodeInfo{Status: http.StatusInternalServerError, Category: CategoryInternal}
+	}
+	if message == "" {
+		message = info.Message
+	}
+
+	return &AppError{
+		Code:     code,
+		Message:  message,
+		Status:   info.Status,
+		Category: info.Category,
+		stack:    callers(),
+	}
 }
 
 func (r *AppError) WithData(data string) *AppError {
@@ -29,6 +105,139 @@ func (r *AppError) WithData(data string) *AppError {
 	return r
 }
 
+// WithCause records the error that led to r
+func (r *AppError) WithCause(err error) *AppError {
+	r.cause = err
+	return r
+}
+
+func (r *AppError) WithCategory(category Category) *AppError {
+	r.Category = category
+	return r
+}
+
+// WithField adds the error of one field of the input
+func (r *AppError) WithField(field, code, message string) *AppError {
+	if r.Details == nil {
+		r.Details = &Details{}
+	}
+	r.Details.Fields = append(r.Details.Fields, FieldError{Field: field, Code: code, Message: message})
+	return r
+}
+
+// WithMetadata adds a value to the details
+func (r *AppError) WithMetadata(key string, value any) *AppError {
+	if r.Details == nil {
+		r.Details = &Details{}
+	}
+	if r.Details.Metadata == nil {
+		r.Details.Metadata = map[string]any{}
+	}
+	r.Details.Metadata[key] = value
+	return r
+}
+
+// WithInternalMetadata adds a value to the details that is not shown in production
+func (r *AppError) WithInternalMetadata(key string, value any) *AppError {
+	if r.Details == nil {
+		r.Details = &Details{}
+	}
+	if r.Details.Internal == nil {
+		r.Details.Internal = map[string]any{}
+	}
+	r.Details.Internal[key] = value
+	return r
+}
+
+// Caller returns where the error was created as file:line
+func (r *AppError) Caller() string {
+	frames := r.frames()
+	if len(frames) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", frames[0].File, frames[0].Line)
+}
+
+// StackTrace returns the stack captured when the error was created
+func (r *AppError) StackTrace() string {
+	var b strings.Builder
+	for _, f := range r.frames() {
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+	}
+	return b.String()
+}
+
+// frames skips the frames of this package so the stack starts at the caller
+func (r *AppError) frames() []runtime.Frame {
+	if len(r.stack) == 0 {
+		return nil
+	}
+
+	var res []runtime.Frame
+	frames := runtime.CallersFrames(r.stack)
+	for {
+		f, more := frames.Next()
+		if !strings.Contains(f.Function, "platforms/errors.") {
+			res = append(res, f)
+		}
+		if !more {
+			break
+		}
+	}
+	return res
+}
+
+func callers() []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(3, pcs)
+	return pcs[:n]
+}
+
+// From returns err as an AppError, an error that is not one is wrapped in an internal failure
+func From(err error) *AppError {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return New("internal.002", "").WithCause(err)
+	}
+	if pgErr := FromPostgres(err); pgErr != nil {
+		return pgErr
+	}
+	return InternalFailure(err.Error()).WithCause(err)
+}
+
+// HTTPStatus returns the HTTP status for err
+func HTTPStatus(err error) int {
+	if appErr := From(err); appErr != nil && appErr.Status != 0 {
+		return appErr.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// NatsStatus returns the NATS service error code for err
+func NatsStatus(err error) string {
+	appErr := From(err)
+	if appErr == nil {
+		return ""
+	}
+	if info, ok := LookupCode(appErr.Code); ok && info.Status == appErr.Status {
+		return info.NatsStatus
+	}
+	return fmt.Sprint(HTTPStatus(appErr))
+}
+
+// IsCode reports whether err is an AppError with the given code
+func IsCode(err error, code string) bool {
+	var appErr *AppError
+	return errors.As(err, &appErr) && appErr.Code == code
+}
+
 func QueryNotFound(message string) *AppError {
 	return NewAppError("query.001", message, http.StatusNotFound)
 }
@@ -58,9 +267,21 @@ func MQWrongAccess() *AppError {
 }
 
 func IsQueryNotFound(err error) bool {
-	appErr, ok := err.(*AppError)
-	if !ok {
+	var appErr *AppError
+	if !errors.As(err, &appErr) {
 		return false
 	}
 	return appErr.Code == "query.001" && appErr.Status == http.StatusNotFound
 }
+
+func PayloadInvalid(message string) *AppError {
+	return NewAppError("payload.001", message, http.StatusBadRequest)
+}
+
+func InternalFailure(message string) *AppError {
+	return NewAppError("internal.001", message, http.StatusInternalServerError)
+}
+
+func TooManyRequests() *AppError {
+	return NewAppError("ratelimit.001", "too many requests", http.StatusTooManyRequests)
+}
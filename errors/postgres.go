@@ -0,0 +1,51 @@
+package myerrors
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+)
+
+// FromPostgres translates the constraint violations of Postgres, it returns nil for the
+// other errors. The values of the row are left out as they may be sensitive, the table and
+// the constraint are shown outside production only
+func FromPostgres(err error) *AppError {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+
+	var appErr *AppError
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		appErr = New("db.001", "")
+	case pgForeignKeyViolation:
+		appErr = New("db.002", "")
+	case pgNotNullViolation:
+		appErr = New("db.003", "")
+		if pgErr.ColumnName != "" {
+			appErr.WithField(pgErr.ColumnName, "required", "")
+		}
+	case pgCheckViolation:
+		appErr = New("db.004", "")
+	default:
+		return nil
+	}
+
+	appErr.WithCause(err)
+	if pgErr.TableName != "" {
+		appErr.WithInternalMetadata("table", pgErr.TableName)
+	}
+	if pgErr.ConstraintName != "" {
+		appErr.WithInternalMetadata("constraint", pgErr.ConstraintName)
+	}
+	return appErr
+}
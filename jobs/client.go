@@ -0,0 +1,139 @@
+package myjobs
+
+import (
+	"context"
+	"time"
+
+	mydatabase "github.com/gianglt2198/platforms/database"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultMaxAttempts = 25
+)
+
+// Client enqueues jobs and administers the queue
+type Client struct {
+	repo *mydatabase.Repository[Job]
+}
+
+func NewClient(db *gorm.DB) *Client {
+	return &Client{
+		repo: mydatabase.NewRepository[Job](db),
+	}
+}
+
+// Enqueue persists a job for args, it joins the current transaction of ctx if any so the
+// job is only visible once the caller commits. A job skipped because of its unique key
+// is returned with a zero ID
+func Enqueue[A JobArgs](ctx context.Context, c *Client, args A, opts ...EnqueueOptions) (*Job, *myerrors.AppError) {
+	var opt EnqueueOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	payload, err := utils.TransformToByteArray(args)
+	if err != nil {
+		return nil, myerrors.PayloadInvalid(err.Error()).WithCause(err)
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		Queue:       opt.Queue,
+		Kind:        args.Kind(),
+		Payload:     string(payload),
+		Status:      StatusPending,
+		Priority:    opt.Priority,
+		RunAt:       opt.RunAt.UTC(),
+		MaxAttempts: opt.MaxAttempts,
+		CreatedAt:   now,
+	}
+	if job.Queue == "" {
+		job.Queue = DefaultQueue
+	}
+	if opt.RunAt.IsZero() {
+		job.RunAt = now
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = defaultMaxAttempts
+	}
+
+	if opt.UniqueKey == "" {
+		return c.repo.CreateOne(ctx, job)
+	}
+
+	job.UniqueKey = &opt.UniqueKey
+	return c.repo.CreateOneWithClauses(ctx, job, clause.OnConflict{
+		Columns:     []clause.Column{{Name: "unique_key"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "status IN ('PENDING','RUNNING')"}}},
+		DoNothing:   true,
+	})
+}
+
+// Find returns the job with the given id
+func (c *Client) Find(ctx context.Context, id uint) (*Job, *myerrors.AppError) {
+	return c.repo.FindById(ctx, int(id))
+}
+
+// List returns a page of jobs filtered by status, queue and kind, the newest first
+func (c *Client) List(ctx context.Context, status Status, queue, kind string, page, take int) (int, *[]Job, *myerrors.AppError) {
+	where := "1 = 1"
+	params := []interface{}{}
+
+	if status != "" {
+		where += " AND status = ?"
+		params = append(params, status)
+	}
+	if queue != "" {
+		where += " AND queue = ?"
+		params = append(params, queue)
+	}
+	if kind != "" {
+		where += " AND kind = ?"
+		params = append(params, kind)
+	}
+
+	return c.repo.Pagination(ctx, &mydatabase.FindOption{
+		Where:  where,
+		Params: params,
+		Page:   page,
+		Take:   take,
+		Order:  "id DESC",
+	})
+}
+
+// Retry puts a dead or cancelled job back in its queue to run now
+func (c *Client) Retry(ctx context.Context, id uint) *myerrors.AppError {
+	return c.transition(ctx, id, []Status{StatusDead, StatusCancelled}, map[string]interface{}{
+		"status":     StatusPending,
+		"run_at":     time.Now().UTC(),
+		"attempts":   0,
+		"last_error": "",
+	})
+}
+
+// Cancel prevents a pending job from running, a running job finishes but its outcome is ignored
+func (c *Client) Cancel(ctx context.Context, id uint) *myerrors.AppError {
+	return c.transition(ctx, id, []Status{StatusPending, StatusRunning}, map[string]interface{}{
+		"status": StatusCancelled,
+	})
+}
+
+func (c *Client) transition(ctx context.Context, id uint, from []Status, values map[string]interface{}) *myerrors.AppError {
+	values["updated_at"] = time.Now().UTC()
+
+	result := c.repo.QueryBuilder(ctx).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(values)
+	if result.Error != nil {
+		return myerrors.QueryInvalid(result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return myerrors.QueryNotFound("no job in a state allowing this action")
+	}
+
+	return nil
+}
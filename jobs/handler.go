@@ -0,0 +1,121 @@
+package myjobs
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gianglt2198/platforms/middleware"
+	"github.com/gianglt2198/platforms/services/rest/middlewares"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	ListJobsRequest struct {
+		Status Status `query:"status" validate:"omitempty,oneof=PENDING RUNNING COMPLETED DEAD CANCELLED"`
+		Queue  string `query:"queue"`
+		Kind   string `query:"kind"`
+		Page   int    `query:"page" validate:"omitempty,min=1"`
+		Take   int    `query:"take" validate:"omitempty,min=1,max=200"`
+	}
+
+	JobRequest struct {
+		ID uint `params:"id" validate:"required"`
+	}
+)
+
+type (
+	// AdminHandlerConfig holds configuration for AdminHandler
+	AdminHandlerConfig struct {
+		// Guards authorize the callers of every route, middleware.RequireRoles("admin") by
+		// default. The router must run middleware.Authentication before
+		Guards []fiber.Handler
+	}
+
+	// AdminHandler exposes the job queue over REST to list, retry and cancel jobs
+	AdminHandler struct {
+		client *Client
+		cfg    AdminHandlerConfig
+	}
+)
+
+// DefaultAdminHandlerConfig returns the default configuration
+func DefaultAdminHandlerConfig() AdminHandlerConfig {
+	return AdminHandlerConfig{
+		Guards: []fiber.Handler{middleware.RequireRoles("admin")},
+	}
+}
+
+func (m AdminHandlerConfig) apply(cfg *AdminHandlerConfig) {
+	if len(m.Guards) > 0 {
+		cfg.Guards = m.Guards
+	}
+}
+
+func NewAdminHandler(client *Client, configs ...AdminHandlerConfig) *AdminHandler {
+	cfg := DefaultAdminHandlerConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return &AdminHandler{client: client, cfg: cfg}
+}
+
+func (h *AdminHandler) Register(router fiber.Router) {
+	router.Get("/jobs", routes.Usecase(h.list, http.StatusOK,
+		h.middlewares(routes.Doc(openapi.Summary("List the jobs"), openapi.Tags("jobs")),
+			middlewares.AllPayloadValidator[ListJobsRequest]())...))
+	router.Get("/jobs/:id", routes.Usecase(h.find, http.StatusOK,
+		h.middlewares(routes.Doc(openapi.Summary("Get a job"), openapi.Tags("jobs")),
+			middlewares.AllPayloadValidator[JobRequest]())...))
+	router.Post("/jobs/:id/retry", routes.Usecase(h.retry, http.StatusOK,
+		h.middlewares(routes.Doc(openapi.Summary("Retry a dead or cancelled job"), openapi.Tags("jobs")),
+			middlewares.AllPayloadValidator[JobRequest]())...))
+	router.Post("/jobs/:id/cancel", routes.Usecase(h.cancel, http.StatusOK,
+		h.middlewares(routes.Doc(openapi.Summary("Cancel a pending job"), openapi.Tags("jobs")),
+			middlewares.AllPayloadValidator[JobRequest]())...))
+}
+
+// middlewares appends the guards, Usecase runs them before the others
+func (h *AdminHandler) middlewares(ms ...fiber.Handler) []fiber.Handler {
+	return append(ms, h.cfg.Guards...)
+}
+
+func (h *AdminHandler) list(ctx context.Context, req ListJobsRequest) (*routes.Page[Job], error) {
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Take == 0 {
+		req.Take = 50
+	}
+
+	total, jobs, aerr := h.client.List(ctx, req.Status, req.Queue, req.Kind, req.Page, req.Take)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	return &routes.Page[Job]{Items: *jobs, Total: total, Page: req.Page, Take: req.Take}, nil
+}
+
+func (h *AdminHandler) find(ctx context.Context, req JobRequest) (*Job, error) {
+	job, aerr := h.client.Find(ctx, req.ID)
+	if aerr != nil {
+		return nil, aerr
+	}
+	return job, nil
+}
+
+func (h *AdminHandler) retry(ctx context.Context, req JobRequest) (*Job, error) {
+	if aerr := h.client.Retry(ctx, req.ID); aerr != nil {
+		return nil, aerr
+	}
+	return h.find(ctx, req)
+}
+
+func (h *AdminHandler) cancel(ctx context.Context, req JobRequest) (*Job, error) {
+	if aerr := h.client.Cancel(ctx, req.ID); aerr != nil {
+		return nil, aerr
+	}
+	return h.find(ctx, req)
+}
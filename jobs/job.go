@@ -0,0 +1,67 @@
+package myjobs
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusRunning   Status = "RUNNING"
+	StatusCompleted Status = "COMPLETED"
+	StatusDead      Status = "DEAD"
+	StatusCancelled Status = "CANCELLED"
+)
+
+const DefaultQueue = "default"
+
+type (
+	// JobArgs is implemented by the typed payloads of the jobs, Kind routes a job to its handler
+	JobArgs interface {
+		Kind() string
+	}
+
+	// Job is a unit of background work persisted in Postgres
+	Job struct {
+		ID          uint       `gorm:"primaryKey" json:"id"`
+		Queue       string     `gorm:"index:idx_jobs_fetch,priority:1;size:64" json:"queue"`
+		Kind        string     `gorm:"index;size:128" json:"kind"`
+		Payload     string     `gorm:"type:jsonb" json:"payload"`
+		Status      Status     `gorm:"index:idx_jobs_fetch,priority:2;size:16" json:"status"`
+		Priority    int        `json:"priority"`
+		RunAt       time.Time  `gorm:"index:idx_jobs_fetch,priority:3" json:"run_at"`
+		Attempts    int        `json:"attempts"`
+		MaxAttempts int        `json:"max_attempts"`
+		UniqueKey   *string    `gorm:"uniqueIndex:idx_jobs_unique_key,where:status IN ('PENDING','RUNNING');size:191" json:"unique_key,omitempty"`
+		LockedBy    string     `gorm:"size:64" json:"locked_by,omitempty"`
+		LockedAt    *time.Time `json:"locked_at,omitempty"`
+		LastError   string     `json:"last_error,omitempty"`
+		CompletedAt *time.Time `json:"completed_at,omitempty"`
+		CreatedAt   time.Time  `json:"created_at"`
+		UpdatedAt   *time.Time `json:"updated_at"`
+	}
+
+	// EnqueueOptions tunes how a job is queued
+	EnqueueOptions struct {
+		// Queue is the queue the job is put in, workers only fetch their queues
+		Queue string
+		// RunAt delays the job until the given time
+		RunAt time.Time
+		// Priority orders the due jobs of a queue, higher runs first
+		Priority int
+		// MaxAttempts is the number of runs before the job is dead
+		MaxAttempts int
+		// UniqueKey prevents enqueuing while a job with the same key is pending or running
+		UniqueKey string
+	}
+)
+
+func (Job) TableName() string { return "jobs" }
+
+// Migrate creates the table of the jobs
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Job{})
+}
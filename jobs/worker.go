@@ -0,0 +1,320 @@
+package myjobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	mysubcriber "github.com/gianglt2198/platforms/pkg/tools/subcriber"
+	"gorm.io/gorm"
+)
+
+// ErrPermanent marks a failure that must not be retried, the job is dead right away
+var ErrPermanent = errors.New("permanent job failure")
+
+// Permanent wraps err so the job is not retried
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+type (
+	handlerFunc func(ctx context.Context, job *Job) error
+
+	// WorkerConfig holds configuration for a job worker
+	WorkerConfig struct {
+		// ID identifies the worker holding a job, defaults to the hostname and pid
+		ID string
+		// Queues are the queues the worker fetches jobs from
+		Queues []string
+		// Concurrency is the number of jobs run at the same time
+		Concurrency int
+		// PollInterval is the delay between two lookups of due jobs
+		PollInterval time.Duration
+		// LockTimeout bounds the run of a job, a job still running after it is given to another worker
+		LockTimeout time.Duration
+		// BaseBackoff is the delay before the first retry, doubled after each failure
+		BaseBackoff time.Duration
+		// MaxBackoff caps the delay between two retries
+		MaxBackoff time.Duration
+		// ShutdownTimeout is how long Run waits for the running jobs once its context is done
+		ShutdownTimeout time.Duration
+	}
+
+	// Worker fetches due jobs from Postgres and runs them with their registered handlers
+	Worker struct {
+		cfg      WorkerConfig
+		db       *gorm.DB
+		logger   oblogger.ObLogger
+		pool     *mysubcriber.WorkerSubscriber
+		slots    chan struct{}
+		mu       sync.RWMutex
+		handlers map[string]handlerFunc
+	}
+)
+
+// DefaultWorkerConfig returns the default configuration
+func DefaultWorkerConfig() WorkerConfig {
+	host, _ := os.Hostname()
+	return WorkerConfig{
+		ID:              fmt.Sprintf("%s-%d", host, os.Getpid()),
+		Queues:          []string{DefaultQueue},
+		Concurrency:     10,
+		PollInterval:    time.Second,
+		LockTimeout:     5 * time.Minute,
+		BaseBackoff:     time.Second,
+		MaxBackoff:      time.Hour,
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+func (m WorkerConfig) apply(cfg *WorkerConfig) {
+	if m.ID != "" {
+		cfg.ID = m.ID
+	}
+	if len(m.Queues) > 0 {
+		cfg.Queues = m.Queues
+	}
+	if m.Concurrency > 0 {
+		cfg.Concurrency = m.Concurrency
+	}
+	if m.PollInterval > 0 {
+		cfg.PollInterval = m.PollInterval
+	}
+	if m.LockTimeout > 0 {
+		cfg.LockTimeout = m.LockTimeout
+	}
+	if m.BaseBackoff > 0 {
+		cfg.BaseBackoff = m.BaseBackoff
+	}
+	if m.MaxBackoff > 0 {
+		cfg.MaxBackoff = m.MaxBackoff
+	}
+	if m.ShutdownTimeout > 0 {
+		cfg.ShutdownTimeout = m.ShutdownTimeout
+	}
+}
+
+func NewWorker(db *gorm.DB, logger oblogger.ObLogger, configs ...WorkerConfig) *Worker {
+	cfg := DefaultWorkerConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return &Worker{
+		cfg:    cfg,
+		db:     db,
+		logger: logger,
+		pool: mysubcriber.NewWorkerSubscriber(mysubcriber.Config{
+			Name:    "jobs",
+			Workers: cfg.Concurrency,
+			Logger:  logger,
+		}),
+		slots:    make(chan struct{}, cfg.Concurrency),
+		handlers: map[string]handlerFunc{},
+	}
+}
+
+// Handle registers fn for the jobs of the kind of A, Kind must not depend on the fields of A
+func Handle[A JobArgs](w *Worker, fn func(ctx context.Context, job *Job, args A) error) {
+	var zero A
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.handlers[zero.Kind()] = func(ctx context.Context, job *Job) error {
+		var args A
+		if err := json.Unmarshal([]byte(job.Payload), &args); err != nil {
+			return Permanent(fmt.Errorf("decoding payload: %w", err))
+		}
+		return fn(ctx, job, args)
+	}
+}
+
+// Run fetches and runs jobs every poll interval until ctx is done, then waits for the
+// running jobs up to the shutdown timeout. The worker does not run again afterwards
+func (w *Worker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	logCtx := context.WithValue(ctx, "requestId", "jobs-"+w.cfg.ID)
+
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), w.cfg.ShutdownTimeout)
+			defer cancel()
+			return w.pool.Shutdown(shutdownCtx)
+		case <-ticker.C:
+			if _, err := w.Tick(ctx); err != nil {
+				w.logger.Error(logCtx, "[Worker]Tick", err)
+			}
+		}
+	}
+}
+
+// Tick requeues the jobs whose lock expired, then starts as many due jobs as there
+// are free slots and returns how many were started
+func (w *Worker) Tick(ctx context.Context) (int, error) {
+	if err := w.rescue(ctx); err != nil {
+		return 0, err
+	}
+
+	free := cap(w.slots) - len(w.slots)
+	if free == 0 {
+		return 0, nil
+	}
+
+	jobs, err := w.fetch(ctx, free)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		w.slots <- struct{}{}
+		if err := w.pool.Submit(ctx, func() {
+			defer func() { <-w.slots }()
+			w.execute(job)
+		}); err != nil {
+			<-w.slots
+			// The lock expires and the job is picked up again
+			return i, err
+		}
+	}
+
+	return len(jobs), nil
+}
+
+func (w *Worker) kinds() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	kinds := make([]string, 0, len(w.handlers))
+	for kind := range w.handlers {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+func (w *Worker) handler(kind string) handlerFunc {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.handlers[kind]
+}
+
+// fetch claims up to limit due jobs, the rows locked by other workers are skipped
+func (w *Worker) fetch(ctx context.Context, limit int) ([]Job, error) {
+	kinds := w.kinds()
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+
+	var jobs []Job
+	err := w.db.WithContext(ctx).Raw(`
+		UPDATE jobs SET status = ?, attempts = attempts + 1, locked_by = ?, locked_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE status = ? AND queue IN ? AND kind IN ? AND run_at <= ?
+			ORDER BY priority DESC, run_at ASC
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		StatusRunning, w.cfg.ID, now, now,
+		StatusPending, w.cfg.Queues, kinds, now,
+		limit,
+	).Scan(&jobs).Error
+
+	return jobs, err
+}
+
+// rescue puts back the jobs of crashed workers, a job out of attempts is dead
+func (w *Worker) rescue(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	return w.db.WithContext(ctx).Exec(`
+		UPDATE jobs SET
+			status = CASE WHEN attempts >= max_attempts THEN ? ELSE ? END,
+			last_error = 'lock expired',
+			locked_by = '', locked_at = NULL, run_at = ?, updated_at = ?
+		WHERE status = ? AND queue IN ? AND locked_at < ?`,
+		StatusDead, StatusPending, now, now,
+		StatusRunning, w.cfg.Queues, now.Add(-w.cfg.LockTimeout),
+	).Error
+}
+
+func (w *Worker) execute(job *Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.LockTimeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, "requestId", fmt.Sprintf("job-%d", job.ID))
+
+	err := w.run(ctx, job)
+
+	now := time.Now().UTC()
+	values := map[string]interface{}{
+		"locked_by":  "",
+		"locked_at":  nil,
+		"updated_at": now,
+	}
+
+	switch {
+	case err == nil:
+		values["status"] = StatusCompleted
+		values["completed_at"] = now
+		values["last_error"] = ""
+	case errors.Is(err, ErrPermanent) || job.Attempts >= job.MaxAttempts:
+		values["status"] = StatusDead
+		values["last_error"] = err.Error()
+	default:
+		values["status"] = StatusPending
+		values["run_at"] = now.Add(w.backoff(job.Attempts))
+		values["last_error"] = err.Error()
+	}
+
+	if err != nil {
+		w.logger.Warn(ctx, "[Worker]Job failed", "id", job.ID, "kind", job.Kind, "attempt", job.Attempts, "error", err.Error())
+	}
+
+	// A job cancelled or rescued in the meantime is not ours to update anymore
+	if dbErr := w.db.WithContext(context.WithoutCancel(ctx)).Model(&Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, StatusRunning, w.cfg.ID).
+		Updates(values).Error; dbErr != nil {
+		w.logger.Error(ctx, "[Worker]Updating job", dbErr)
+	}
+}
+
+func (w *Worker) run(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	fn := w.handler(job.Kind)
+	if fn == nil {
+		return fmt.Errorf("no handler for job kind %s", job.Kind)
+	}
+
+	return fn(ctx, job)
+}
+
+// backoff returns the delay before the next run, doubled for each attempt with a jitter of 20%
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := float64(w.cfg.BaseBackoff) * math.Pow(2, float64(attempts-1))
+	if delay > float64(w.cfg.MaxBackoff) {
+		delay = float64(w.cfg.MaxBackoff)
+	}
+
+	jitter := delay * 0.2 * (rand.Float64()*2 - 1)
+	return time.Duration(delay + jitter)
+}
@@ -0,0 +1,145 @@
+package mylock
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"time"
+
+	"github.com/gianglt2198/platforms/cache"
+	"gorm.io/gorm"
+)
+
+type (
+	// Backend takes the locks for a Locker
+	Backend interface {
+		// TryLock takes key if it is free and reports whether it was taken
+		TryLock(ctx context.Context, key string, ttl time.Duration) (Lease, bool, error)
+	}
+
+	// Lease is a lock held on a backend
+	Lease interface {
+		// Renew extends the lock by ttl, it fails with ErrLockLost once the lock is gone
+		Renew(ctx context.Context, ttl time.Duration) error
+		Release(ctx context.Context) error
+	}
+)
+
+// PostgresBackend takes session advisory locks, each lock holds a connection of the pool
+// until it is released so the lock is freed as soon as the holder dies. The ttl is not
+// used, renewing checks the session is still alive
+type PostgresBackend struct {
+	db *gorm.DB
+}
+
+func NewPostgresBackend(db *gorm.DB) *PostgresBackend {
+	return &PostgresBackend{db: db}
+}
+
+func (b *PostgresBackend) TryLock(ctx context.Context, key string, _ time.Duration) (Lease, bool, error) {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	id := advisoryKey(key)
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", id).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	return &postgresLease{conn: conn, id: id}, true, nil
+}
+
+type postgresLease struct {
+	conn *sql.Conn
+	id   int64
+}
+
+func (l *postgresLease) Renew(ctx context.Context, _ time.Duration) error {
+	if _, err := l.conn.ExecContext(ctx, "SELECT 1"); err != nil {
+		return errors.Join(ErrLockLost, err)
+	}
+	return nil
+}
+
+func (l *postgresLease) Release(ctx context.Context) error {
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.id)
+	return errors.Join(err, l.conn.Close())
+}
+
+// advisoryKey maps key to the 64 bits id of an advisory lock
+func advisoryKey(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+// CacheBackend takes locks as keys holding a random token, a lock not renewed before its
+// ttl expires is free for the others
+type CacheBackend struct {
+	cache  cache.Cache
+	prefix string
+}
+
+func NewCacheBackend(c cache.Cache) *CacheBackend {
+	return &CacheBackend{cache: c, prefix: "lock:"}
+}
+
+func (b *CacheBackend) TryLock(ctx context.Context, key string, ttl time.Duration) (Lease, bool, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, err := b.cache.SetNX(ctx, b.prefix+key, token, ttl)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	return &cacheLease{cache: b.cache, key: b.prefix + key, token: token}, true, nil
+}
+
+type cacheLease struct {
+	cache cache.Cache
+	key   string
+	token []byte
+}
+
+func (l *cacheLease) Renew(ctx context.Context, ttl time.Duration) error {
+	ok, err := l.cache.CompareAndExpire(ctx, l.key, l.token, ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *cacheLease) Release(ctx context.Context) error {
+	_, err := l.cache.CompareAndDelete(ctx, l.key, l.token)
+	return err
+}
+
+func newToken() ([]byte, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return []byte(hex.EncodeToString(b)), nil
+}
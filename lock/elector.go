@@ -0,0 +1,133 @@
+package mylock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// ElectedHandler is called when leadership is gained, ctx is cancelled when it is lost
+	ElectedHandler func(ctx context.Context)
+	// RevokedHandler is called when leadership is lost or given up
+	RevokedHandler func()
+
+	// ElectorConfig holds configuration for a leader elector
+	ElectorConfig struct {
+		// TTL is how long the leadership survives a leader that stopped renewing it
+		TTL time.Duration
+		// RetryInterval is the delay between two attempts of a follower to become leader
+		RetryInterval time.Duration
+	}
+
+	// LeaderElector keeps at most one leader among the replicas competing for the same key
+	LeaderElector struct {
+		cfg       ElectorConfig
+		locker    *Locker
+		key       string
+		leader    atomic.Bool
+		mu        sync.RWMutex
+		onElected []ElectedHandler
+		onRevoked []RevokedHandler
+	}
+)
+
+// DefaultElectorConfig returns the default configuration
+func DefaultElectorConfig() ElectorConfig {
+	return ElectorConfig{
+		TTL:           15 * time.Second,
+		RetryInterval: 5 * time.Second,
+	}
+}
+
+func (m ElectorConfig) apply(cfg *ElectorConfig) {
+	if m.TTL > 0 {
+		cfg.TTL = m.TTL
+	}
+	if m.RetryInterval > 0 {
+		cfg.RetryInterval = m.RetryInterval
+	}
+}
+
+func NewLeaderElector(locker *Locker, key string, configs ...ElectorConfig) *LeaderElector {
+	cfg := DefaultElectorConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return &LeaderElector{cfg: cfg, locker: locker, key: key}
+}
+
+// OnElected adds a handler called each time this replica becomes leader
+func (e *LeaderElector) OnElected(handler ElectedHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onElected = append(e.onElected, handler)
+}
+
+// OnRevoked adds a handler called each time this replica stops being leader
+func (e *LeaderElector) OnRevoked(handler RevokedHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onRevoked = append(e.onRevoked, handler)
+}
+
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run competes for leadership until ctx is done, leadership is given up on return
+func (e *LeaderElector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.cfg.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		lock, err := e.locker.TryAcquire(ctx, e.key, e.cfg.TTL)
+		switch {
+		case err == nil:
+			e.lead(ctx, lock)
+		case ctx.Err() != nil:
+			return nil
+		case !errors.Is(err, ErrNotAcquired):
+			// The backend is unavailable, try again on the next tick
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead holds the leadership until the lock is lost or ctx is done
+func (e *LeaderElector) lead(ctx context.Context, lock *Lock) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	e.leader.Store(true)
+
+	e.mu.RLock()
+	elected := append([]ElectedHandler(nil), e.onElected...)
+	revoked := append([]RevokedHandler(nil), e.onRevoked...)
+	e.mu.RUnlock()
+
+	for _, h := range elected {
+		h(leaderCtx)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-lock.Lost():
+	}
+
+	e.leader.Store(false)
+	cancel()
+	for _, h := range revoked {
+		h()
+	}
+
+	_ = lock.Release(context.WithoutCancel(ctx))
+}
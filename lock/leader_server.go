@@ -0,0 +1,173 @@
+package mylock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	mycore "github.com/gianglt2198/platforms/server"
+)
+
+// LeaderOnlyServer wraps a server so it only runs on the leader replica, it can be
+// registered in a ServerRegistry like the server it wraps
+type LeaderOnlyServer struct {
+	mycore.Server
+
+	elector     *LeaderElector
+	stopTimeout time.Duration
+
+	mu       sync.Mutex
+	status   mycore.ServerStatus
+	cancel   context.CancelFunc
+	done     chan struct{}
+	handlers []mycore.ErrorHandler
+}
+
+var _ mycore.Server = (*LeaderOnlyServer)(nil)
+
+// LeaderOnly returns server started when elector gains leadership and stopped when it
+// loses it, stopTimeout bounds the stop of the wrapped server
+func LeaderOnly(server mycore.Server, elector *LeaderElector, stopTimeout time.Duration) *LeaderOnlyServer {
+	s := &LeaderOnlyServer{
+		Server:      server,
+		elector:     elector,
+		stopTimeout: stopTimeout,
+		status:      mycore.ServerStatusStopped,
+	}
+
+	elector.OnElected(s.elected)
+	elector.OnRevoked(s.revoked)
+
+	return s
+}
+
+// Start competes for leadership in the background, the wrapped server starts once elected
+func (s *LeaderOnlyServer) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return nil
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.status = mycore.ServerStatusStandby
+
+	go func() {
+		defer close(s.done)
+		_ = s.elector.Run(runCtx)
+	}()
+
+	return nil
+}
+
+// Stop gives up leadership, which stops the wrapped server
+func (s *LeaderOnlyServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	s.status = mycore.ServerStatusStopped
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *LeaderOnlyServer) Restart(ctx context.Context) error {
+	if err := s.Stop(ctx); err != nil {
+		return err
+	}
+	return s.Start(ctx)
+}
+
+// Status is the status of the wrapped server on the leader and STANDBY on the followers
+func (s *LeaderOnlyServer) Status() mycore.ServerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status == mycore.ServerStatusStandby || s.status == mycore.ServerStatusStopped {
+		return s.status
+	}
+	return s.Server.Status()
+}
+
+func (s *LeaderOnlyServer) Health() (*mycore.HealthStatus, error) {
+	if !s.elector.IsLeader() {
+		return &mycore.HealthStatus{
+			Status:   s.Status(),
+			Protocol: s.Protocol(),
+			Version:  s.Version(),
+			Metadata: map[string]any{"leader": false},
+		}, nil
+	}
+
+	health, err := s.Server.Health()
+	if health != nil {
+		if health.Metadata == nil {
+			health.Metadata = map[string]any{}
+		}
+		health.Metadata["leader"] = true
+	}
+	return health, err
+}
+
+func (s *LeaderOnlyServer) OnError(handler mycore.ErrorHandler) {
+	s.mu.Lock()
+	s.handlers = append(s.handlers, handler)
+	s.mu.Unlock()
+
+	s.Server.OnError(handler)
+}
+
+func (s *LeaderOnlyServer) elected(ctx context.Context) {
+	s.mu.Lock()
+	s.status = mycore.ServerStatusRunning
+	s.mu.Unlock()
+
+	// Servers like fiber block in Start, it must not hold the elector
+	go func() {
+		if err := s.Server.Start(ctx); err != nil {
+			s.fail(err)
+		}
+	}()
+}
+
+func (s *LeaderOnlyServer) revoked() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.stopTimeout)
+	defer cancel()
+
+	if err := s.Server.Stop(ctx); err != nil {
+		s.fail(err)
+	}
+
+	s.mu.Lock()
+	if s.status == mycore.ServerStatusRunning {
+		s.status = mycore.ServerStatusStandby
+	}
+	s.mu.Unlock()
+}
+
+func (s *LeaderOnlyServer) fail(err error) {
+	s.mu.Lock()
+	handlers := append([]mycore.ErrorHandler(nil), s.handlers...)
+	s.mu.Unlock()
+
+	for _, h := range handlers {
+		_ = h(err)
+	}
+}
@@ -0,0 +1,193 @@
+package mylock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotAcquired = errors.New("lock is held by another owner")
+	ErrLockLost    = errors.New("lock was lost")
+)
+
+type (
+	// Config holds configuration for a locker
+	Config struct {
+		// RetryInterval is the delay between two attempts of Acquire
+		RetryInterval time.Duration
+		// RenewRatio is the part of the ttl after which a held lock is renewed
+		RenewRatio float64
+	}
+
+	// Locker takes named locks on a backend
+	Locker struct {
+		cfg     Config
+		backend Backend
+	}
+
+	// Lock is a held lock, it is renewed in the background until released
+	Lock struct {
+		key    string
+		lease  Lease
+		lost   chan struct{}
+		cancel context.CancelFunc
+		done   chan struct{}
+		once   sync.Once
+		err    error
+	}
+)
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		RetryInterval: time.Second,
+		RenewRatio:    1.0 / 3,
+	}
+}
+
+func (m Config) apply(cfg *Config) {
+	if m.RetryInterval > 0 {
+		cfg.RetryInterval = m.RetryInterval
+	}
+	if m.RenewRatio > 0 && m.RenewRatio < 1 {
+		cfg.RenewRatio = m.RenewRatio
+	}
+}
+
+func NewLocker(backend Backend, configs ...Config) *Locker {
+	cfg := DefaultConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return &Locker{cfg: cfg, backend: backend}
+}
+
+// Acquire waits until key is taken or ctx is done, the lock is renewed every part
+// of ttl until it is released or lost
+func (l *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	ticker := time.NewTicker(l.cfg.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		lock, err := l.TryAcquire(ctx, key, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// TryAcquire takes key if it is free and fails with ErrNotAcquired otherwise
+func (l *Locker) TryAcquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	lease, ok, err := l.backend.TryLock(ctx, key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+
+	renewCtx, cancel := context.WithCancel(context.Background())
+	lock := &Lock{
+		key:    key,
+		lease:  lease,
+		lost:   make(chan struct{}),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go lock.renew(renewCtx, ttl, time.Duration(float64(ttl)*l.cfg.RenewRatio))
+
+	return lock, nil
+}
+
+// WithLock runs fn while holding key, the context given to fn is cancelled if the lock is lost
+func (l *Locker) WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lock, err := l.Acquire(ctx, key, ttl)
+	if err != nil {
+		return err
+	}
+	defer lock.Release(context.WithoutCancel(ctx))
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lock.Lost():
+			cancel()
+		case <-lockCtx.Done():
+		}
+	}()
+
+	return fn(lockCtx)
+}
+
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Lost is closed when the lock could not be renewed, the holder must stop its work
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns why the lock was lost
+func (l *Lock) Err() error {
+	select {
+	case <-l.lost:
+		return l.err
+	default:
+		return nil
+	}
+}
+
+// Release stops the renewal and frees the lock
+func (l *Lock) Release(ctx context.Context) error {
+	var err error
+	l.once.Do(func() {
+		l.cancel()
+		<-l.done
+		err = l.lease.Release(ctx)
+	})
+	return err
+}
+
+func (l *Lock) renew(ctx context.Context, ttl, every time.Duration) {
+	defer close(l.done)
+
+	if every <= 0 {
+		every = time.Second
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.lease.Renew(ctx, ttl)
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			// A failing backend is retried as long as the lock may still be ours
+			if errors.Is(err, ErrLockLost) || time.Since(renewed) >= ttl {
+				l.err = err
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
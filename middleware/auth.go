@@ -1 +1,328 @@
 package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gianglt2198/platforms/common"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var hmacAlgorithms = []string{"HS256", "HS384", "HS512"}
+
+// QueryAccessToken is the query parameter of the token of the browser streams, whose
+// EventSource and WebSocket cannot set the Authorization header
+const QueryAccessToken = "access_token"
+
+type (
+	// TokenSource reads the token of a request, "" when it has none
+	TokenSource func(c *fiber.Ctx) string
+
+	// ClaimsMapper builds the principal of the verified claims of a token
+	ClaimsMapper func(claims jwt.MapClaims) (*common.Principal, error)
+
+	// AuthConfig holds configuration for the authentication middleware
+	AuthConfig struct {
+		// Issuer is the expected iss claim, not checked when empty
+		Issuer string
+		// Audience is the list of accepted aud claims, not checked when empty
+		Audience []string
+		// Algorithms are the accepted signing algorithms
+		Algorithms []string
+		// Secret verifies the HS256, HS384 and HS512 tokens
+		Secret []byte
+		// Keys verifies the asymmetric tokens, see NewRemoteJWKS, NewOIDCJWKS and JWKSFromFile
+		Keys KeySource
+		// Leeway tolerates clock skew on exp, nbf and iat
+		Leeway time.Duration
+		// TokenSources read the token of the requests without Authorization header, in order,
+		// e.g. TokenFromQuery and TokenFromCookie for the browser streams
+		TokenSources []TokenSource
+		// Optional lets the requests without a token through anonymously
+		Optional bool
+		// Skip bypasses the authentication for some requests, like health checks
+		Skip func(c *fiber.Ctx) bool
+		// ClaimsMapper maps the claims to the principal, DefaultClaimsMapper by default
+		ClaimsMapper ClaimsMapper
+	}
+)
+
+// DefaultAuthConfig returns the default configuration
+func DefaultAuthConfig() AuthConfig {
+	return AuthConfig{
+		Algorithms:   []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256"},
+		Leeway:       30 * time.Second,
+		ClaimsMapper: DefaultClaimsMapper,
+	}
+}
+
+func (m AuthConfig) apply(cfg *AuthConfig) {
+	if m.Issuer != "" {
+		cfg.Issuer = m.Issuer
+	}
+	if len(m.Audience) > 0 {
+		cfg.Audience = m.Audience
+	}
+	if len(m.Algorithms) > 0 {
+		cfg.Algorithms = m.Algorithms
+	}
+	if len(m.Secret) > 0 {
+		cfg.Secret = m.Secret
+		// A secret alone only accepts the HS algorithms, with keys it accepts both
+		if len(m.Algorithms) == 0 {
+			if m.Keys == nil {
+				cfg.Algorithms = hmacAlgorithms
+			} else {
+				cfg.Algorithms = append(slices.Clone(cfg.Algorithms), hmacAlgorithms...)
+			}
+		}
+	}
+	if m.Keys != nil {
+		cfg.Keys = m.Keys
+	}
+	if m.Leeway > 0 {
+		cfg.Leeway = m.Leeway
+	}
+	if len(m.TokenSources) > 0 {
+		cfg.TokenSources = append(cfg.TokenSources, m.TokenSources...)
+	}
+	if m.Optional {
+		cfg.Optional = true
+	}
+	if m.Skip != nil {
+		cfg.Skip = m.Skip
+	}
+	if m.ClaimsMapper != nil {
+		cfg.ClaimsMapper = m.ClaimsMapper
+	}
+}
+
+// Authentication verifies the bearer token of the requests and stores the principal in
+// the locals and in the user context under common.KEY_AUTH_USER, where the repositories
+// find it through the context of the request
+func Authentication(configs ...AuthConfig) fiber.Handler {
+	authenticator := NewAuthenticator(configs...)
+	cfg := authenticator.cfg
+
+	return func(c *fiber.Ctx) error {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			return c.Next()
+		}
+
+		token := bearerToken(c.Get(fiber.HeaderAuthorization))
+		for _, source := range cfg.TokenSources {
+			if token != "" {
+				break
+			}
+			token = source(c)
+		}
+		if token == "" {
+			if cfg.Optional {
+				return c.Next()
+			}
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer`)
+			return myerrors.New("auth.001", "")
+		}
+
+		principal, aerr := authenticator.Authenticate(c.UserContext(), token)
+		if aerr != nil {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return aerr
+		}
+
+		c.Locals(common.KEY_AUTH_USER, principal)
+		c.SetUserContext(common.WithPrincipal(c.UserContext(), principal))
+
+		return c.Next()
+	}
+}
+
+// CurrentPrincipal returns the principal authenticated for the request
+func CurrentPrincipal(c *fiber.Ctx) (*common.Principal, bool) {
+	p, ok := c.Locals(common.KEY_AUTH_USER).(*common.Principal)
+	return p, ok && p != nil
+}
+
+// Authenticator verifies tokens, it is shared by the REST middleware and the NATS guards
+type Authenticator struct {
+	cfg    AuthConfig
+	parser *jwt.Parser
+}
+
+func NewAuthenticator(configs ...AuthConfig) *Authenticator {
+	cfg := DefaultAuthConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(cfg.Algorithms),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+
+	return &Authenticator{cfg: cfg, parser: jwt.NewParser(opts...)}
+}
+
+// Authenticate verifies token and maps its claims to a principal
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*common.Principal, *myerrors.AppError) {
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return a.key(ctx, t)
+	})
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, myerrors.New("auth.003", "").WithCause(err)
+		}
+		return nil, myerrors.New("auth.002", "").WithCause(err)
+	}
+
+	if len(a.cfg.Audience) > 0 && !audienceAccepted(claims, a.cfg.Audience) {
+		return nil, myerrors.New("auth.002", "").WithCause(jwt.ErrTokenInvalidAudience)
+	}
+
+	principal, err := a.cfg.ClaimsMapper(claims)
+	if err != nil {
+		return nil, myerrors.New("auth.002", "").WithCause(err)
+	}
+	return principal, nil
+}
+
+func (a *Authenticator) key(ctx context.Context, t *jwt.Token) (any, error) {
+	alg := t.Method.Alg()
+	if strings.HasPrefix(alg, "HS") {
+		if len(a.cfg.Secret) == 0 {
+			return nil, fmt.Errorf("%s tokens are not accepted", alg)
+		}
+		return a.cfg.Secret, nil
+	}
+
+	if a.cfg.Keys == nil {
+		return nil, ErrKeyNotFound
+	}
+	kid, _ := t.Header["kid"].(string)
+	return a.cfg.Keys.Key(ctx, kid, alg)
+}
+
+func audienceAccepted(claims jwt.MapClaims, accepted []string) bool {
+	aud, err := claims.GetAudience()
+	if err != nil {
+		return false
+	}
+	for _, a := range aud {
+		for _, b := range accepted {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TokenFromQuery reads the token from the query parameter name, see QueryAccessToken. The
+// URLs end up in the logs of the proxies, only the tokens expiring within maxTTL are read,
+// e.g. the ones of a minute issued to open a stream
+func TokenFromQuery(name string, maxTTL time.Duration) TokenSource {
+	parser := jwt.NewParser()
+
+	return func(c *fiber.Ctx) string {
+		token := c.Query(name)
+		if token == "" {
+			return ""
+		}
+
+		// The token is verified afterwards, only its lifetime is read here
+		claims := jwt.MapClaims{}
+		if _, _, err := parser.ParseUnverified(token, claims); err != nil {
+			return token
+		}
+		exp, err := claims.GetExpirationTime()
+		if err != nil || exp == nil || time.Until(exp.Time) > maxTTL {
+			return ""
+		}
+		return token
+	}
+}
+
+// TokenFromCookie reads the token from the cookie name. The browsers send the cookies with
+// the requests of other sites, the WebSocket streams check their origin
+func TokenFromCookie(name string) TokenSource {
+	return func(c *fiber.Ctx) string {
+		return c.Cookies(name)
+	}
+}
+
+func bearerToken(header string) string {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// DefaultClaimsMapper reads the standard OIDC claims, the roles from roles or the
+// realm_access.roles of Keycloak, the permissions from permissions and the scopes from
+// scope or scp
+func DefaultClaimsMapper(claims jwt.MapClaims) (*common.Principal, error) {
+	sub, err := claims.GetSubject()
+	if err != nil || sub == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	p := &common.Principal{
+		ID:          sub,
+		Email:       stringClaim(claims, "email"),
+		Name:        stringClaim(claims, "name"),
+		Type:        stringClaim(claims, "typ"),
+		TenantID:    stringClaim(claims, "tenant_id"),
+		Roles:       stringsClaim(claims["roles"]),
+		Permissions: stringsClaim(claims["permissions"]),
+		Claims:      claims,
+	}
+	if p.Name == "" {
+		p.Name = stringClaim(claims, "preferred_username")
+	}
+	if realm, ok := claims["realm_access"].(map[string]any); ok && len(p.Roles) == 0 {
+		p.Roles = stringsClaim(realm["roles"])
+	}
+	if scope := stringClaim(claims, "scope"); scope != "" {
+		p.Scopes = strings.Fields(scope)
+	} else {
+		p.Scopes = stringsClaim(claims["scp"])
+	}
+
+	return p, nil
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+func stringsClaim(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	case []string:
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gianglt2198/platforms/pkg/authz"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Authorize checks policy against the principal set by Authentication, it can be mounted on
+// a group or passed to routes.Usecase:
+//
+//	routes.Usecase(h.Delete, fiber.StatusOK, middlewares.AllPayloadValidator[Request](), middleware.RequireRoles("admin"))
+//
+// Usecase runs its middlewares last to first, put the guards last to check them before
+// the payload
+func Authorize(policy authz.Policy) fiber.Handler {
+	return openapi.Annotate(func(c *fiber.Ctx) error {
+		p, _ := CurrentPrincipal(c)
+		if aerr := policy.Authorize(c.UserContext(), p); aerr != nil {
+			return aerr
+		}
+		return routes.Next(c)
+	}, func(op *openapi.Operation) {
+		op.AddSecurity(openapi.SecurityBearer)
+		op.AddResponse("401", openapi.ProblemResponse(http.StatusText(http.StatusUnauthorized)))
+		op.AddResponse("403", openapi.ProblemResponse(http.StatusText(http.StatusForbidden)))
+	})
+}
+
+// RequireRoles lets through the principals having at least one of roles
+func RequireRoles(roles ...string) fiber.Handler {
+	return openapi.Annotate(Authorize(authz.RequireRoles(roles...)), func(op *openapi.Operation) {
+		op.SetExtension("x-roles", roles)
+	})
+}
+
+// RequirePermissions lets through the principals granted all the permissions
+func RequirePermissions(permissions ...string) fiber.Handler {
+	return openapi.Annotate(Authorize(authz.RequirePermissions(permissions...)), func(op *openapi.Operation) {
+		op.SetExtension("x-permissions", permissions)
+	})
+}
@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gianglt2198/platforms/cache"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/idempotency"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+type (
+	// IdempotencyConfig holds configuration for the idempotency middleware
+	IdempotencyConfig struct {
+		// Store holds the keys, the memory cache by default. Use a shared cache or a
+		// idempotency.PostgresStore so the replicas see the same keys
+		Store idempotency.Store
+		// TTL is how long a response is replayed, 24 hours by default
+		TTL time.Duration
+		// LockTTL is how long a request in progress holds its key, it must exceed the
+		// longest request. One minute by default
+		LockTTL time.Duration
+		// Header carries the key, Idempotency-Key by default
+		Header string
+		// Methods are the methods made idempotent, POST by default
+		Methods []string
+		// Required rejects the requests without key with idempotency.001
+		Required bool
+		// Scope separates the keys of the callers, the principal by default
+		Scope KeyFunc
+		// Replayable reports whether a response of status is stored, by default the 2xx and
+		// the 4xx but 401, 403, 408, 409 and 429 which a retry may change. The other
+		// responses release the key
+		Replayable func(status int) bool
+	}
+)
+
+// DefaultIdempotencyConfig returns the default configuration
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		TTL:        24 * time.Hour,
+		LockTTL:    time.Minute,
+		Header:     HeaderIdempotencyKey,
+		Methods:    []string{fiber.MethodPost},
+		Scope:      scopeByPrincipal,
+		Replayable: replayable,
+	}
+}
+
+func (m IdempotencyConfig) apply(cfg *IdempotencyConfig) {
+	if m.Store != nil {
+		cfg.Store = m.Store
+	}
+	if m.TTL > 0 {
+		cfg.TTL = m.TTL
+	}
+	if m.LockTTL > 0 {
+		cfg.LockTTL = m.LockTTL
+	}
+	if m.Header != "" {
+		cfg.Header = m.Header
+	}
+	if len(m.Methods) > 0 {
+		cfg.Methods = m.Methods
+	}
+	if m.Required {
+		cfg.Required = true
+	}
+	if m.Scope != nil {
+		cfg.Scope = m.Scope
+	}
+	if m.Replayable != nil {
+		cfg.Replayable = m.Replayable
+	}
+}
+
+// Idempotency makes the retries of a request safe, the first request with a key runs and
+// its response is stored with a fingerprint of the request:
+//   - a retry gets the stored response with Idempotent-Replayed: true
+//   - a retry while the first request runs gets idempotency.002, a 409
+//   - a request reusing the key with another method, URL or body gets idempotency.003, a 422
+//
+// Usecase runs its middlewares last to first, put it after the guards and before the
+// payload validation:
+//
+//	routes.Usecase(h.Pay, fiber.StatusCreated, middlewares.AllPayloadValidator[Request](), middleware.Idempotency(), middleware.RequireRoles("customer"))
+func Idempotency(configs ...IdempotencyConfig) fiber.Handler {
+	cfg := DefaultIdempotencyConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	if cfg.Store == nil {
+		cfg.Store = idempotency.NewCacheStore(cache.NewMemoryCache())
+	}
+
+	return openapi.Annotate(func(c *fiber.Ctx) error {
+		if !slices.Contains(cfg.Methods, c.Method()) {
+			return routes.Next(c)
+		}
+
+		key := c.Get(cfg.Header)
+		if key == "" && !cfg.Required {
+			return routes.Next(c)
+		}
+		if key == "" || len(key) > maxIdempotencyKeyLength {
+			return myerrors.New("idempotency.001", "")
+		}
+
+		key = "idempotency:" + hash(cfg.Scope(c), key)
+		fingerprint := hash(c.Method(), c.OriginalURL(), string(c.Body()))
+
+		held, err := cfg.Store.Claim(c.UserContext(), key, fingerprint, cfg.LockTTL)
+		if err != nil {
+			return myerrors.InternalFailure("cannot claim the idempotency key").WithCause(err)
+		}
+
+		if held != nil {
+			switch {
+			case held.Fingerprint != fingerprint:
+				return myerrors.New("idempotency.003", "")
+			case !held.Completed:
+				return myerrors.New("idempotency.002", "")
+			}
+
+			c.Set(HeaderIdempotentReplayed, "true")
+			c.Set(fiber.HeaderContentType, held.ContentType)
+			if err := c.Status(held.Status).Send(held.Body); err != nil {
+				return err
+			}
+			return routes.Stop(c)
+		}
+
+		return routes.After(c, func(c *fiber.Ctx) {
+			ctx := c.UserContext()
+			res := c.Response()
+
+			if !cfg.Replayable(res.StatusCode()) {
+				_ = cfg.Store.Release(ctx, key)
+				return
+			}
+
+			err := cfg.Store.Complete(ctx, key, &idempotency.Record{
+				Fingerprint: fingerprint,
+				Completed:   true,
+				Status:      res.StatusCode(),
+				ContentType: string(res.Header.ContentType()),
+				Body:        append([]byte(nil), res.Body()...),
+			}, cfg.TTL)
+			if err != nil {
+				// Not stored, the key is released rather than held until LockTTL
+				_ = cfg.Store.Release(ctx, key)
+			}
+		})
+	}, func(op *openapi.Operation) {
+		maxLength := maxIdempotencyKeyLength
+		op.Parameters = append(op.Parameters, &openapi.Parameter{
+			Name:        cfg.Header,
+			In:          "header",
+			Description: "Unique key of the request, its retries get the response of the first one",
+			Required:    cfg.Required,
+			Schema:      &openapi.Schema{Type: "string", MaxLength: &maxLength},
+		})
+		op.AddResponse("409", openapi.ProblemResponse(http.StatusText(http.StatusConflict)))
+		op.AddResponse("422", openapi.ProblemResponse(http.StatusText(http.StatusUnprocessableEntity)))
+	})
+}
+
+func scopeByPrincipal(c *fiber.Ctx) string {
+	if p, ok := CurrentPrincipal(c); ok {
+		return "principal:" + p.ID
+	}
+	return "anonymous"
+}
+
+func replayable(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout,
+		http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 200 && status < 500
+}
+
+// hash joins the parts, the separator keeps them from running into each other
+func hash(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
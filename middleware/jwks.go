@@ -0,0 +1,283 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrKeyNotFound = errors.New("signing key not found")
+
+type (
+	// KeySource returns the key verifying the tokens signed with kid and alg
+	KeySource interface {
+		Key(ctx context.Context, kid, alg string) (any, error)
+	}
+
+	// JWK is a JSON Web Key, RFC 7517
+	JWK struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg,omitempty"`
+		Use string `json:"use,omitempty"`
+		// RSA
+		N string `json:"n,omitempty"`
+		E string `json:"e,omitempty"`
+		// EC
+		Crv string `json:"crv,omitempty"`
+		X   string `json:"x,omitempty"`
+		Y   string `json:"y,omitempty"`
+		// Symmetric
+		K string `json:"k,omitempty"`
+	}
+
+	// JWKS is a JSON Web Key Set
+	JWKS struct {
+		Keys []JWK `json:"keys"`
+	}
+
+	// StaticKeys is a KeySource of fixed keys by kid, the empty kid matches the tokens without one
+	StaticKeys map[string]any
+
+	// JWKSConfig holds configuration for a remote key set
+	JWKSConfig struct {
+		// RefreshInterval is how long the fetched keys are used before fetching them again
+		RefreshInterval time.Duration
+		// MinRefreshInterval limits the fetches triggered by unknown key ids
+		MinRefreshInterval time.Duration
+		// Client fetches the key set
+		Client *http.Client
+	}
+
+	// RemoteJWKS fetches a key set from a URL and caches it, a token signed with an
+	// unknown kid triggers a refresh so rotated keys are picked up
+	RemoteJWKS struct {
+		cfg       JWKSConfig
+		url       string
+		mu        sync.RWMutex
+		keys      StaticKeys
+		fetchedAt time.Time
+		triedAt   time.Time
+	}
+)
+
+func (s StaticKeys) Key(_ context.Context, kid, _ string) (any, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	if len(s) == 1 && kid == "" {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// ParseJWKS returns the keys of a JSON key set by kid
+func ParseJWKS(content []byte) (StaticKeys, error) {
+	var set JWKS
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+
+	keys := StaticKeys{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// JWKSFromFile loads a key set from a local file, for tests and air-gapped environments
+func JWKSFromFile(path string) (StaticKeys, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(content)
+}
+
+// PublicKey returns the key as *rsa.PublicKey, *ecdsa.PublicKey or []byte
+func (k JWK) PublicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// DefaultJWKSConfig returns the default configuration
+func DefaultJWKSConfig() JWKSConfig {
+	return JWKSConfig{
+		RefreshInterval:    time.Hour,
+		MinRefreshInterval: time.Minute,
+		Client:             &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (m JWKSConfig) apply(cfg *JWKSConfig) {
+	if m.RefreshInterval > 0 {
+		cfg.RefreshInterval = m.RefreshInterval
+	}
+	if m.MinRefreshInterval > 0 {
+		cfg.MinRefreshInterval = m.MinRefreshInterval
+	}
+	if m.Client != nil {
+		cfg.Client = m.Client
+	}
+}
+
+// NewRemoteJWKS returns a key source reading the key set at url, it is fetched on first use
+func NewRemoteJWKS(url string, configs ...JWKSConfig) *RemoteJWKS {
+	cfg := DefaultJWKSConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	return &RemoteJWKS{cfg: cfg, url: url}
+}
+
+// NewOIDCJWKS discovers the key set of an OpenID Connect issuer
+func NewOIDCJWKS(ctx context.Context, issuer string, configs ...JWKSConfig) (*RemoteJWKS, error) {
+	cfg := DefaultJWKSConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, cfg.Client, url, &discovery); err != nil {
+		return nil, fmt.Errorf("discovering %s: %w", issuer, err)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discovering %s: no jwks_uri", issuer)
+	}
+
+	return &RemoteJWKS{cfg: cfg, url: discovery.JWKSURI}, nil
+}
+
+func (s *RemoteJWKS) Key(ctx context.Context, kid, alg string) (any, error) {
+	s.mu.RLock()
+	keys, age := s.keys, time.Since(s.fetchedAt)
+	s.mu.RUnlock()
+
+	if keys != nil && age < s.cfg.RefreshInterval {
+		if key, err := keys.Key(ctx, kid, alg); err == nil {
+			return key, nil
+		}
+		// The key may have been rotated, but do not let bad tokens hammer the issuer
+		if age < s.cfg.MinRefreshInterval {
+			return nil, ErrKeyNotFound
+		}
+	}
+
+	keys, err := s.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return keys.Key(ctx, kid, alg)
+}
+
+func (s *RemoteJWKS) refresh(ctx context.Context) (StaticKeys, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another request may have refreshed the keys while waiting for the lock, or the
+	// issuer failed a moment ago
+	if s.keys != nil && time.Since(s.triedAt) < s.cfg.MinRefreshInterval {
+		return s.keys, nil
+	}
+	s.triedAt = time.Now()
+
+	var set json.RawMessage
+	if err := getJSON(ctx, s.cfg.Client, s.url, &set); err != nil {
+		// Keep serving the keys we have while the issuer is unavailable
+		if s.keys != nil {
+			return s.keys, nil
+		}
+		return nil, err
+	}
+
+	keys, err := ParseJWKS(set)
+	if err != nil {
+		return nil, err
+	}
+
+	s.keys, s.fetchedAt = keys, time.Now()
+	return keys, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
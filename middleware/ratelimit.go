@@ -1 +1,185 @@
 package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/ratelimit"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+)
+
+type (
+	// KeyFunc returns the key the requests are counted under, the requests without a key
+	// are not limited
+	KeyFunc func(c *fiber.Ctx) string
+
+	// RateLimitConfig holds configuration for the rate limiting middleware
+	RateLimitConfig struct {
+		// Limiter applies the rate, 100 requests per minute in memory by default. Use a
+		// ratelimit.RedisStore to share the limits between the replicas
+		Limiter *ratelimit.Limiter
+		// KeyFunc selects the caller, KeyByIP by default
+		KeyFunc KeyFunc
+		// Overrides replaces the limiter of the requests matching "METHOD /path" or "/path",
+		// the routes with parameters take the middleware directly, e.g. in routes.Usecase
+		Overrides map[string]*ratelimit.Limiter
+		// Skip bypasses the limit for some requests, like health checks
+		Skip func(c *fiber.Ctx) bool
+		// DisableHeaders does not send the RateLimit headers of the allowed requests
+		DisableHeaders bool
+	}
+)
+
+// DefaultRateLimitConfig returns the default configuration
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		KeyFunc: KeyByIP,
+	}
+}
+
+func (m RateLimitConfig) apply(cfg *RateLimitConfig) {
+	if m.Limiter != nil {
+		cfg.Limiter = m.Limiter
+	}
+	if m.KeyFunc != nil {
+		cfg.KeyFunc = m.KeyFunc
+	}
+	if len(m.Overrides) > 0 {
+		cfg.Overrides = m.Overrides
+	}
+	if m.Skip != nil {
+		cfg.Skip = m.Skip
+	}
+	if m.DisableHeaders {
+		cfg.DisableHeaders = true
+	}
+}
+
+// RateLimit rejects the requests over the rate of their key with ratelimit.001 and a
+// Retry-After header. The RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers follow the IETF draft
+func RateLimit(configs ...RateLimitConfig) fiber.Handler {
+	cfg := DefaultRateLimitConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	if cfg.Limiter == nil {
+		cfg.Limiter = ratelimit.New("http")
+	}
+
+	return openapi.Annotate(func(c *fiber.Ctx) error {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			return routes.Next(c)
+		}
+
+		key := cfg.KeyFunc(c)
+		if key == "" {
+			return routes.Next(c)
+		}
+
+		limiter := cfg.Limiter
+		if l, ok := cfg.Overrides[c.Method()+" "+c.Path()]; ok {
+			limiter = l
+		} else if l, ok := cfg.Overrides[c.Path()]; ok {
+			limiter = l
+		}
+
+		// A failing store is counted in the metrics, the result then follows FailClosed
+		res, _ := limiter.Allow(c.UserContext(), key)
+
+		if !cfg.DisableHeaders || !res.Allowed {
+			c.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+			c.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+			c.Set(HeaderRateLimitReset, strconv.Itoa(seconds(res.Reset)))
+			c.Set(HeaderRateLimitPolicy, limiter.Rate().Policy())
+		}
+
+		if !res.Allowed {
+			retryAfter := max(seconds(res.RetryAfter), 1)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return myerrors.TooManyRequests().
+				WithCause(ratelimit.ErrLimited).
+				WithMetadata("retry_after", retryAfter)
+		}
+
+		return routes.Next(c)
+	}, func(op *openapi.Operation) {
+		response := openapi.ProblemResponse(http.StatusText(http.StatusTooManyRequests))
+		response.Headers = map[string]*openapi.Header{
+			fiber.HeaderRetryAfter: {Description: "Seconds before retrying", Schema: &openapi.Schema{Type: "integer"}},
+		}
+		op.AddResponse("429", response)
+		op.SetExtension("x-rate-limit", cfg.Limiter.Rate().Policy())
+	})
+}
+
+// KeyByIP counts the requests by client IP, configure fiber.Config.ProxyHeader behind a
+// proxy
+func KeyByIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
+
+// KeyByPrincipal counts the requests by authenticated principal, and the anonymous ones
+// by client IP
+func KeyByPrincipal(c *fiber.Ctx) string {
+	if p, ok := CurrentPrincipal(c); ok {
+		return "principal:" + p.ID
+	}
+	return KeyByIP(c)
+}
+
+// KeyByAPIKey counts the requests by the API key found in header once valid accepts it, e.g.
+// by looking it up like the authentication does, the keys are hashed so the store does not
+// hold them. The requests without a valid key are counted by client IP, so leaving the
+// header out or sending random keys does not escape the limit
+func KeyByAPIKey(header string, valid func(c *fiber.Ctx, key string) bool) KeyFunc {
+	return func(c *fiber.Ctx) string {
+		key := c.Get(header)
+		if key == "" || valid == nil || !valid(c, key) {
+			return KeyByIP(c)
+		}
+		sum := sha256.Sum256([]byte(key))
+		return "apikey:" + hex.EncodeToString(sum[:16])
+	}
+}
+
+// KeyByRoute counts the requests by route, for a limit shared by all the callers
+func KeyByRoute(c *fiber.Ctx) string {
+	return "route:" + c.Method() + " " + c.Route().Path
+}
+
+// Keys combines key functions, e.g. Keys(KeyByRoute, KeyByPrincipal) limits each caller on
+// each route. The requests are not limited when one of them has no key, the key functions of
+// this package always return one
+func Keys(funcs ...KeyFunc) KeyFunc {
+	return func(c *fiber.Ctx) string {
+		keys := make([]string, 0, len(funcs))
+		for _, f := range funcs {
+			key := f(c)
+			if key == "" {
+				return ""
+			}
+			keys = append(keys, key)
+		}
+		return strings.Join(keys, "|")
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,124 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/gianglt2198/platforms/common"
+	myerrors "github.com/gianglt2198/platforms/errors"
+)
+
+type (
+	// Policy decides whether the principal may go on, a nil principal is an anonymous caller.
+	// The errors are the MQ codes of myerrors: mq.002 when authentication is missing,
+	// mq.003 when a role or permission is missing and mq.004 for a wrong principal type
+	Policy interface {
+		Authorize(ctx context.Context, p *common.Principal) *myerrors.AppError
+	}
+
+	// PolicyFunc adapts a function to a Policy
+	PolicyFunc func(ctx context.Context, p *common.Principal) *myerrors.AppError
+)
+
+func (f PolicyFunc) Authorize(ctx context.Context, p *common.Principal) *myerrors.AppError {
+	return f(ctx, p)
+}
+
+// Check runs policy against the principal of ctx
+func Check(ctx context.Context, policy Policy) *myerrors.AppError {
+	p, _ := common.PrincipalFromContext(ctx)
+	return policy.Authorize(ctx, p)
+}
+
+// Authenticated lets any authenticated principal through
+func Authenticated() Policy {
+	return PolicyFunc(func(_ context.Context, p *common.Principal) *myerrors.AppError {
+		if p == nil {
+			return myerrors.MQUnauthorization()
+		}
+		return nil
+	})
+}
+
+// RequireRoles lets through the principals having at least one of roles
+func RequireRoles(roles ...string) Policy {
+	return PolicyFunc(func(_ context.Context, p *common.Principal) *myerrors.AppError {
+		if p == nil {
+			return myerrors.MQUnauthorization()
+		}
+		for _, role := range roles {
+			if p.HasRole(role) {
+				return nil
+			}
+		}
+		return myerrors.MQAccessDenined().WithMetadata("roles", roles)
+	})
+}
+
+// RequirePermissions lets through the principals granted all the permissions
+func RequirePermissions(permissions ...string) Policy {
+	return PolicyFunc(func(_ context.Context, p *common.Principal) *myerrors.AppError {
+		if p == nil {
+			return myerrors.MQUnauthorization()
+		}
+		for _, permission := range permissions {
+			if !p.HasPermission(permission) {
+				return myerrors.MQAccessDenined().WithMetadata("permissions", permissions)
+			}
+		}
+		return nil
+	})
+}
+
+// RequireType lets through the principals of one of types, e.g. user or service
+func RequireType(types ...string) Policy {
+	return PolicyFunc(func(_ context.Context, p *common.Principal) *myerrors.AppError {
+		if p == nil {
+			return myerrors.MQUnauthorization()
+		}
+		for _, t := range types {
+			if p.Type == t {
+				return nil
+			}
+		}
+		return myerrors.MQWrongAccess()
+	})
+}
+
+// Predicate lets through the principals for which fn holds, for attribute based rules
+// such as ownership or tenancy
+func Predicate(fn func(ctx context.Context, p *common.Principal) bool) Policy {
+	return PolicyFunc(func(ctx context.Context, p *common.Principal) *myerrors.AppError {
+		if p == nil {
+			return myerrors.MQUnauthorization()
+		}
+		if !fn(ctx, p) {
+			return myerrors.MQAccessDenined()
+		}
+		return nil
+	})
+}
+
+// All lets through when every policy does, the first refusal is returned
+func All(policies ...Policy) Policy {
+	return PolicyFunc(func(ctx context.Context, p *common.Principal) *myerrors.AppError {
+		for _, policy := range policies {
+			if aerr := policy.Authorize(ctx, p); aerr != nil {
+				return aerr
+			}
+		}
+		return nil
+	})
+}
+
+// Any lets through when one policy does, the last refusal is returned otherwise
+func Any(policies ...Policy) Policy {
+	return PolicyFunc(func(ctx context.Context, p *common.Principal) *myerrors.AppError {
+		aerr := myerrors.MQAccessDenined()
+		for _, policy := range policies {
+			if aerr = policy.Authorize(ctx, p); aerr == nil {
+				return nil
+			}
+		}
+		return aerr
+	})
+}
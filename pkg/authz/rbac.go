@@ -0,0 +1,106 @@
+package authz
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gianglt2198/platforms/common"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"gorm.io/gorm"
+)
+
+// RolePermission grants a permission to a role
+type RolePermission struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	Role       string `gorm:"uniqueIndex:idx_role_permission;size:128" json:"role"`
+	Permission string `gorm:"uniqueIndex:idx_role_permission;size:128" json:"permission"`
+}
+
+func (RolePermission) TableName() string { return "role_permissions" }
+
+// Migrate creates the table of the role permissions
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&RolePermission{})
+}
+
+// RBAC grants permissions through the roles of the principals, on top of the permissions
+// carried by the principals themselves
+type RBAC struct {
+	mu     sync.RWMutex
+	grants map[string]map[string]struct{}
+}
+
+// NewRBAC returns an RBAC granting the permissions of each role
+func NewRBAC(grants map[string][]string) *RBAC {
+	r := &RBAC{}
+	r.Set(grants)
+	return r
+}
+
+// LoadRBAC reads the grants from the role_permissions table
+func LoadRBAC(ctx context.Context, db *gorm.DB) (*RBAC, error) {
+	r := &RBAC{}
+	return r, r.Reload(ctx, db)
+}
+
+// Reload replaces the grants with the content of the role_permissions table
+func (r *RBAC) Reload(ctx context.Context, db *gorm.DB) error {
+	var rows []RolePermission
+	if err := db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	grants := map[string][]string{}
+	for _, row := range rows {
+		grants[row.Role] = append(grants[row.Role], row.Permission)
+	}
+	r.Set(grants)
+	return nil
+}
+
+// Set replaces the grants
+func (r *RBAC) Set(grants map[string][]string) {
+	index := make(map[string]map[string]struct{}, len(grants))
+	for role, permissions := range grants {
+		index[role] = make(map[string]struct{}, len(permissions))
+		for _, permission := range permissions {
+			index[role][permission] = struct{}{}
+		}
+	}
+
+	r.mu.Lock()
+	r.grants = index
+	r.mu.Unlock()
+}
+
+// Can reports whether p holds permission directly or through one of its roles
+func (r *RBAC) Can(p *common.Principal, permission string) bool {
+	if p.HasPermission(permission) {
+		return true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, role := range p.Roles {
+		if _, ok := r.grants[role][permission]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Require lets through the principals granted all the permissions
+func (r *RBAC) Require(permissions ...string) Policy {
+	return PolicyFunc(func(_ context.Context, p *common.Principal) *myerrors.AppError {
+		if p == nil {
+			return myerrors.MQUnauthorization()
+		}
+		for _, permission := range permissions {
+			if !r.Can(p, permission) {
+				return myerrors.MQAccessDenined().WithMetadata("permissions", permissions)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	mydatabase "github.com/gianglt2198/platforms/database"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// IdempotencyKey is the record of a key kept by PostgresStore
+	IdempotencyKey struct {
+		Key         string    `gorm:"primaryKey;size:191" json:"key"`
+		Fingerprint string    `gorm:"size:64" json:"fingerprint"`
+		Completed   bool      `json:"completed"`
+		Status      int       `json:"status"`
+		ContentType string    `json:"content_type"`
+		Body        []byte    `json:"body"`
+		CreatedAt   time.Time `json:"created_at"`
+		ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+	}
+
+	// PostgresStore keeps the keys in Postgres, for the services without a shared cache.
+	// The expired keys are taken over by the next claim, Purge deletes them
+	PostgresStore struct {
+		repo *mydatabase.Repository[IdempotencyKey]
+	}
+)
+
+func (IdempotencyKey) TableName() string { return "idempotency_keys" }
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{
+		repo: mydatabase.NewRepository[IdempotencyKey](db),
+	}
+}
+
+// Migrate creates the table used by PostgresStore
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&IdempotencyKey{})
+}
+
+func (s *PostgresStore) Claim(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	now := time.Now().UTC()
+
+	result := s.repo.QueryBuilder(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"fingerprint":  fingerprint,
+			"completed":    false,
+			"status":       0,
+			"content_type": "",
+			"body":         nil,
+			"created_at":   now,
+			"expires_at":   now.Add(ttl),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "idempotency_keys.expires_at < ?", Vars: []interface{}{now}},
+		}},
+	}).Create(&IdempotencyKey{
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	})
+
+	if result.Error != nil {
+		return nil, myerrors.QueryInvalid(result.Error.Error())
+	}
+	if result.RowsAffected > 0 {
+		return nil, nil
+	}
+
+	var held IdempotencyKey
+	if err := s.repo.QueryBuilder(ctx).Where("key = ?", key).First(&held).Error; err != nil {
+		return nil, myerrors.QueryInvalid(err.Error())
+	}
+
+	return &Record{
+		Fingerprint: held.Fingerprint,
+		Completed:   held.Completed,
+		Status:      held.Status,
+		ContentType: held.ContentType,
+		Body:        held.Body,
+	}, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	err := s.repo.QueryBuilder(ctx).Where("key = ?", key).Updates(map[string]interface{}{
+		"completed":    true,
+		"status":       record.Status,
+		"content_type": record.ContentType,
+		"body":         record.Body,
+		"expires_at":   time.Now().UTC().Add(ttl),
+	}).Error
+	if err != nil {
+		return myerrors.QueryInvalid(err.Error())
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	err := s.repo.QueryBuilder(ctx).Where("key = ? AND completed = ?", key, false).Delete(&IdempotencyKey{}).Error
+	if err != nil {
+		return myerrors.QueryInvalid(err.Error())
+	}
+
+	return nil
+}
+
+// Purge deletes the keys expired before the given time
+func (s *PostgresStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result := s.repo.QueryBuilder(ctx).Where("expires_at < ?", before.UTC()).Delete(&IdempotencyKey{})
+	if result.Error != nil {
+		return 0, myerrors.QueryInvalid(result.Error.Error())
+	}
+
+	return result.RowsAffected, nil
+}
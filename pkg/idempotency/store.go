@@ -0,0 +1,90 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gianglt2198/platforms/cache"
+)
+
+type (
+	// Record is what a store holds under an idempotency key, the request in progress until
+	// Completed and then its response
+	Record struct {
+		// Fingerprint identifies the request the key was first used for
+		Fingerprint string `json:"fingerprint"`
+		Completed   bool   `json:"completed"`
+		Status      int    `json:"status,omitempty"`
+		ContentType string `json:"content_type,omitempty"`
+		Body        []byte `json:"body,omitempty"`
+	}
+
+	// Store holds the idempotency keys shared by the replicas of a service
+	Store interface {
+		// Claim takes key for the request of fingerprint until ttl, it returns the record
+		// already held under key instead, nil when the key was claimed
+		Claim(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error)
+		// Complete replaces the claim of key by the response of the request until ttl
+		Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error
+		// Release forgets the claim of key so the request can be retried
+		Release(ctx context.Context, key string) error
+	}
+
+	// CacheStore keeps the keys in the platform cache, a cache.RedisCache shares them between
+	// the replicas
+	CacheStore struct {
+		cache cache.Cache
+	}
+)
+
+func NewCacheStore(c cache.Cache) *CacheStore {
+	return &CacheStore{cache: c}
+}
+
+func (s *CacheStore) Claim(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	claim, err := json.Marshal(&Record{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+
+	// The record may expire between SetNX and Get, the claim is then retried
+	for attempt := 0; attempt < 3; attempt++ {
+		claimed, err := s.cache.SetNX(ctx, key, claim, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			return nil, nil
+		}
+
+		data, err := s.cache.Get(ctx, key)
+		if errors.Is(err, cache.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, err
+		}
+		return &record, nil
+	}
+
+	return nil, errors.New("idempotency: cannot claim " + key)
+}
+
+func (s *CacheStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.cache.Set(ctx, key, data, ttl)
+}
+
+func (s *CacheStore) Release(ctx context.Context, key string) error {
+	return s.cache.Delete(ctx, key)
+}
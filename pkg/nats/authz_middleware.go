@@ -0,0 +1,174 @@
+package mynats
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gianglt2198/platforms/common"
+	"github.com/gianglt2198/platforms/pkg/authz"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// HeaderPrincipal carries the JSON principal of the caller
+	HeaderPrincipal = "principal"
+	// HeaderPrincipalIssuedAt carries the unix time the principal was signed at
+	HeaderPrincipalIssuedAt = "principal-iat"
+	// HeaderPrincipalSignature carries the HMAC-SHA256 of the subject, the issue time and the
+	// principal, base64url encoded
+	HeaderPrincipalSignature = "principal-signature"
+)
+
+type (
+	// PrincipalSignerConfig holds configuration for PrincipalSigner
+	PrincipalSignerConfig struct {
+		// MaxSkew bounds the age of a signature and the drift of the clocks of the services,
+		// 30 seconds by default
+		MaxSkew time.Duration
+	}
+
+	// PrincipalSigner signs the principals of the outgoing messages with a key shared by the
+	// services of the cluster and verifies the ones of the incoming messages, a principal
+	// without a valid signature is dropped. The signature binds the subject and the issue
+	// time so a principal is not replayed on another subject or later. A nil signer neither
+	// sends nor accepts principals
+	PrincipalSigner struct {
+		key []byte
+		cfg PrincipalSignerConfig
+		now func() time.Time
+	}
+)
+
+// DefaultPrincipalSignerConfig returns the default configuration
+func DefaultPrincipalSignerConfig() PrincipalSignerConfig {
+	return PrincipalSignerConfig{
+		MaxSkew: 30 * time.Second,
+	}
+}
+
+func (m PrincipalSignerConfig) apply(cfg *PrincipalSignerConfig) {
+	if m.MaxSkew > 0 {
+		cfg.MaxSkew = m.MaxSkew
+	}
+}
+
+func NewPrincipalSigner(key []byte, configs ...PrincipalSignerConfig) *PrincipalSigner {
+	cfg := DefaultPrincipalSignerConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return &PrincipalSigner{key: key, cfg: cfg, now: time.Now}
+}
+
+// Inject writes the principal of ctx and its signature for subject into the header of an
+// outgoing message
+func (s *PrincipalSigner) Inject(ctx context.Context, subject string, header nats.Header) {
+	if s == nil {
+		return
+	}
+	p, ok := common.PrincipalFromContext(ctx)
+	if !ok {
+		return
+	}
+	content, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	iat := strconv.FormatInt(s.now().Unix(), 10)
+	header.Set(HeaderPrincipal, string(content))
+	header.Set(HeaderPrincipalIssuedAt, iat)
+	header.Set(HeaderPrincipalSignature, s.sign(subject, iat, content))
+}
+
+// Extract returns ctx carrying the principal of header when its signature for subject is
+// valid and fresh
+func (s *PrincipalSigner) Extract(ctx context.Context, subject string, header nats.Header) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return s.ExtractAt(ctx, subject, header, s.now())
+}
+
+// ExtractAt is Extract checking the freshness of the signature at a given time, e.g. the
+// time a stream stored a replayed message
+func (s *PrincipalSigner) ExtractAt(ctx context.Context, subject string, header nats.Header, at time.Time) context.Context {
+	if s == nil || header == nil {
+		return ctx
+	}
+	content := header.Get(HeaderPrincipal)
+	if content == "" {
+		return ctx
+	}
+
+	iat := header.Get(HeaderPrincipalIssuedAt)
+	unix, err := strconv.ParseInt(iat, 10, 64)
+	if err != nil {
+		return ctx
+	}
+	if age := at.Sub(time.Unix(unix, 0)); age > s.cfg.MaxSkew || age < -s.cfg.MaxSkew {
+		return ctx
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(header.Get(HeaderPrincipalSignature))
+	if err != nil || !hmac.Equal(signature, s.mac(subject, iat, []byte(content))) {
+		return ctx
+	}
+
+	var p common.Principal
+	if err := json.Unmarshal([]byte(content), &p); err != nil {
+		return ctx
+	}
+	return common.WithPrincipal(ctx, &p)
+}
+
+func (s *PrincipalSigner) sign(subject, iat string, content []byte) string {
+	return base64.RawURLEncoding.EncodeToString(s.mac(subject, iat, content))
+}
+
+func (s *PrincipalSigner) mac(subject, iat string, content []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(subject + "|" + iat + "|"))
+	h.Write(content)
+	return h.Sum(nil)
+}
+
+// PrincipalMiddleware puts the principal of the message verified by signer in the context
+// of the handler, the principal of the context is dropped so only the signed one is trusted
+func PrincipalMiddleware(signer *PrincipalSigner) Middleware {
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			ctx = common.WithPrincipal(ctx, nil)
+			return next(signer.Extract(ctx, msg.Subject, msg.Header), msg)
+		}
+	}
+}
+
+// AuthorizeMiddleware checks policy against the principal put in the context by
+// PrincipalMiddleware, a refused request is answered with the error of the policy
+func AuthorizeMiddleware(policy authz.Policy) Middleware {
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			if aerr := authz.Check(ctx, policy); aerr != nil {
+				replyError(msg, aerr)
+				return aerr
+			}
+			return next(ctx, msg)
+		}
+	}
+}
+
+// RequireRoles lets through the principals having at least one of roles
+func RequireRoles(roles ...string) Middleware {
+	return AuthorizeMiddleware(authz.RequireRoles(roles...))
+}
+
+// RequirePermissions lets through the principals granted all the permissions
+func RequirePermissions(permissions ...string) Middleware {
+	return AuthorizeMiddleware(authz.RequirePermissions(permissions...))
+}
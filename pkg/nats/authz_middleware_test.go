@@ -0,0 +1,123 @@
+package mynats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gianglt2198/platforms/common"
+	"github.com/nats-io/nats.go"
+)
+
+// newTestSigner returns a signer on a clock moved by advance
+func newTestSigner(key string) (s *PrincipalSigner, advance func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s = NewPrincipalSigner([]byte(key), PrincipalSignerConfig{MaxSkew: time.Minute})
+	s.now = func() time.Time { return now }
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+// signed returns the header of a message sent on subject by the principal alice
+func signed(s *PrincipalSigner, subject string) nats.Header {
+	header := nats.Header{}
+	ctx := common.WithPrincipal(context.Background(), &common.Principal{ID: "alice", Roles: []string{"user"}})
+	s.Inject(ctx, subject, header)
+	return header
+}
+
+func extracted(s *PrincipalSigner, subject string, header nats.Header) *common.Principal {
+	p, _ := common.PrincipalFromContext(s.Extract(context.Background(), subject, header))
+	return p
+}
+
+func TestPrincipalSignerRoundTrip(t *testing.T) {
+	s, _ := newTestSigner("key")
+
+	p := extracted(s, "orders.create", signed(s, "orders.create"))
+	if p == nil || p.ID != "alice" {
+		t.Fatalf("principal = %+v", p)
+	}
+}
+
+func TestPrincipalSignerRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		tamper  func(header nats.Header, advance func(time.Duration))
+	}{
+		{
+			name:    "tampered principal",
+			subject: "orders.create",
+			tamper: func(header nats.Header, _ func(time.Duration)) {
+				header.Set(HeaderPrincipal, `{"id":"alice","roles":["admin"]}`)
+			},
+		},
+		{
+			name:    "tampered issue time",
+			subject: "orders.create",
+			tamper: func(header nats.Header, _ func(time.Duration)) {
+				header.Set(HeaderPrincipalIssuedAt, "1704067260")
+			},
+		},
+		{
+			name:    "missing issue time",
+			subject: "orders.create",
+			tamper: func(header nats.Header, _ func(time.Duration)) {
+				header.Del(HeaderPrincipalIssuedAt)
+			},
+		},
+		{
+			name:    "expired",
+			subject: "orders.create",
+			tamper: func(_ nats.Header, advance func(time.Duration)) {
+				advance(time.Minute + time.Second)
+			},
+		},
+		{
+			name:    "issued in the future",
+			subject: "orders.create",
+			tamper: func(_ nats.Header, advance func(time.Duration)) {
+				advance(-time.Minute - time.Second)
+			},
+		},
+		{
+			name:    "wrong subject",
+			subject: "orders.delete",
+			tamper:  func(nats.Header, func(time.Duration)) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, advance := newTestSigner("key")
+			header := signed(s, "orders.create")
+			tt.tamper(header, advance)
+
+			if p := extracted(s, tt.subject, header); p != nil {
+				t.Errorf("principal = %+v, want none", p)
+			}
+		})
+	}
+}
+
+func TestPrincipalSignerRejectsAnotherKey(t *testing.T) {
+	s, _ := newTestSigner("key")
+	other, _ := newTestSigner("other")
+
+	if p := extracted(s, "orders.create", signed(other, "orders.create")); p != nil {
+		t.Errorf("principal = %+v, want none", p)
+	}
+}
+
+func TestPrincipalSignerExtractAt(t *testing.T) {
+	s, advance := newTestSigner("key")
+	header := signed(s, "orders.created")
+	storedAt := s.now()
+
+	// A replayed message is checked at the time it was stored
+	advance(time.Hour)
+	p, _ := common.PrincipalFromContext(s.ExtractAt(context.Background(), "orders.created", header, storedAt))
+	if p == nil || p.ID != "alice" {
+		t.Fatalf("principal = %+v", p)
+	}
+}
@@ -0,0 +1,119 @@
+package mynats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	mydatabase "github.com/gianglt2198/platforms/database"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errRolledBack = errors.New("mynats: idempotent handler rolled back")
+
+type (
+	// ProcessedMessage is the record of a message handled by an idempotent subscriber
+	ProcessedMessage struct {
+		ID          string    `gorm:"primaryKey;size:191" json:"id"`
+		ProcessedAt time.Time `json:"processed_at"`
+		ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+	}
+
+	// PostgresDedupStore writes the record in the transaction the handler runs in, the
+	// handler repositories pick the transaction from the context so both commit together
+	PostgresDedupStore struct {
+		repo *mydatabase.Repository[ProcessedMessage]
+		tran *mydatabase.Transaction[bool]
+	}
+)
+
+func (ProcessedMessage) TableName() string { return "processed_messages" }
+
+func NewPostgresDedupStore(db *gorm.DB) *PostgresDedupStore {
+	return &PostgresDedupStore{
+		repo: mydatabase.NewRepository[ProcessedMessage](db),
+		tran: mydatabase.NewTransaction[bool](db),
+	}
+}
+
+// MigrateProcessedMessages creates the table used by PostgresDedupStore
+func MigrateProcessedMessages(db *gorm.DB) error {
+	return db.AutoMigrate(&ProcessedMessage{})
+}
+
+func (s *PostgresDedupStore) Process(
+	ctx context.Context,
+	id string,
+	ttl time.Duration,
+	fn func(context.Context) error,
+) (bool, error) {
+	var handlerErr error
+
+	duplicate, aerr := s.tran.Execute(ctx, func(ctx context.Context) (*bool, *myerrors.AppError) {
+		claimed, aerr := s.claim(ctx, id, ttl)
+		if aerr != nil {
+			return nil, aerr
+		}
+
+		duplicate := !claimed
+		if duplicate {
+			return &duplicate, nil
+		}
+
+		if err := fn(ctx); err != nil {
+			handlerErr = err
+			return nil, myerrors.QueryInvalid(err.Error())
+		}
+
+		return &duplicate, nil
+	})
+
+	if handlerErr != nil {
+		return false, handlerErr
+	}
+	if aerr != nil {
+		return false, aerr
+	}
+	if duplicate == nil {
+		return false, errRolledBack
+	}
+
+	return *duplicate, nil
+}
+
+// claim inserts the record, an expired record with the same id is taken over
+func (s *PostgresDedupStore) claim(ctx context.Context, id string, ttl time.Duration) (bool, *myerrors.AppError) {
+	now := time.Now().UTC()
+
+	result := s.repo.QueryBuilder(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"processed_at": now,
+			"expires_at":   now.Add(ttl),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "processed_messages.expires_at < ?", Vars: []interface{}{now}},
+		}},
+	}).Create(&ProcessedMessage{
+		ID:          id,
+		ProcessedAt: now,
+		ExpiresAt:   now.Add(ttl),
+	})
+
+	if result.Error != nil {
+		return false, myerrors.QueryInvalid(result.Error.Error())
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+func (s *PostgresDedupStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result := s.repo.QueryBuilder(ctx).Where("expires_at < ?", before.UTC()).Delete(&ProcessedMessage{})
+	if result.Error != nil {
+		return 0, myerrors.QueryInvalid(result.Error.Error())
+	}
+
+	return result.RowsAffected, nil
+}
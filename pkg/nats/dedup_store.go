@@ -0,0 +1,72 @@
+package mynats
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// DedupStore records the processed message ids
+	DedupStore interface {
+		// Process runs fn unless id was already processed and not expired,
+		// it reports whether the message was a duplicate
+		Process(ctx context.Context, id string, ttl time.Duration, fn func(context.Context) error) (bool, error)
+		// Purge deletes the records expired before the given time
+		Purge(ctx context.Context, before time.Time) (int64, error)
+	}
+
+	// MemoryDedupStore keeps the records of a single process, the id is claimed before
+	// the handler runs and released when it fails
+	MemoryDedupStore struct {
+		mu      sync.Mutex
+		records map[string]time.Time
+	}
+)
+
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{
+		records: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryDedupStore) Process(
+	ctx context.Context,
+	id string,
+	ttl time.Duration,
+	fn func(context.Context) error,
+) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	if expiresAt, ok := s.records[id]; ok && expiresAt.After(now) {
+		s.mu.Unlock()
+		return true, nil
+	}
+	s.records[id] = now.Add(ttl)
+	s.mu.Unlock()
+
+	if err := fn(ctx); err != nil {
+		s.mu.Lock()
+		delete(s.records, id)
+		s.mu.Unlock()
+		return false, err
+	}
+
+	return false, nil
+}
+
+func (s *MemoryDedupStore) Purge(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for id, expiresAt := range s.records {
+		if expiresAt.Before(before) {
+			delete(s.records, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,28 @@
+package mynats
+
+import (
+	"context"
+
+	"github.com/gianglt2198/platforms/pkg/utils"
+	"github.com/nats-io/nats.go"
+)
+
+type (
+	// MsgHandler processes a raw message delivered on a subscription
+	MsgHandler func(ctx context.Context, msg *nats.Msg) error
+
+	// Middleware wraps a MsgHandler with extra behaviour
+	Middleware func(next MsgHandler) MsgHandler
+)
+
+// MsgSubscriber adapts a typed subscriber to a MsgHandler, the payload is decoded from json
+func MsgSubscriber[T any](f func(context.Context, T) error) MsgHandler {
+	return func(ctx context.Context, msg *nats.Msg) error {
+		input, err := utils.TransformToType[T](msg.Data)
+		if err != nil {
+			return err
+		}
+
+		return f(ctx, *input)
+	}
+}
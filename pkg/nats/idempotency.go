@@ -0,0 +1,138 @@
+package mynats
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	"github.com/nats-io/nats.go"
+)
+
+// IdempotencyConfig holds configuration for the idempotency middleware
+type IdempotencyConfig struct {
+	// Store records the processed message ids
+	Store DedupStore
+	// TTL is how long a processed id is remembered
+	TTL time.Duration
+	// Header carries the message id set by the publisher
+	Header string
+	// Scope prefixes the ids so several consumers of a subject keep their own records,
+	// it defaults to the subject of the message
+	Scope string
+	// KeyFunc overrides how the id of a message is computed
+	KeyFunc func(msg *nats.Msg) string
+	// OnDuplicate is called when a message is skipped
+	OnDuplicate func(ctx context.Context, msg *nats.Msg, id string)
+}
+
+// DefaultIdempotencyConfig returns the default configuration
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		TTL:    24 * time.Hour,
+		Header: nats.MsgIdHdr,
+	}
+}
+
+func (m IdempotencyConfig) apply(cfg *IdempotencyConfig) {
+	if m.Store != nil {
+		cfg.Store = m.Store
+	}
+	if m.TTL > 0 {
+		cfg.TTL = m.TTL
+	}
+	if m.Header != "" {
+		cfg.Header = m.Header
+	}
+	if m.Scope != "" {
+		cfg.Scope = m.Scope
+	}
+	if m.KeyFunc != nil {
+		cfg.KeyFunc = m.KeyFunc
+	}
+	if m.OnDuplicate != nil {
+		cfg.OnDuplicate = m.OnDuplicate
+	}
+}
+
+// IdempotencyMiddleware skips the messages whose id was already processed, the id comes
+// from the message id header or a hash of the payload. It suits events, a skipped
+// request operation gets no reply
+func IdempotencyMiddleware(configs ...IdempotencyConfig) Middleware {
+	cfg := DefaultIdempotencyConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryDedupStore()
+	}
+
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			id := cfg.messageID(msg)
+
+			duplicate, err := cfg.Store.Process(ctx, id, cfg.TTL, func(ctx context.Context) error {
+				return next(ctx, msg)
+			})
+
+			if duplicate && cfg.OnDuplicate != nil {
+				cfg.OnDuplicate(ctx, msg, id)
+			}
+
+			return err
+		}
+	}
+}
+
+func (cfg IdempotencyConfig) messageID(msg *nats.Msg) string {
+	scope := cfg.Scope
+	if scope == "" {
+		scope = msg.Subject
+	}
+
+	var key string
+	switch {
+	case cfg.KeyFunc != nil:
+		key = cfg.KeyFunc(msg)
+	case msg.Header.Get(cfg.Header) != "":
+		key = msg.Header.Get(cfg.Header)
+	default:
+		sum := sha256.Sum256(msg.Data)
+		key = hex.EncodeToString(sum[:])
+	}
+
+	return scope + ":" + key
+}
+
+// StartDedupCleanup purges the expired records of store every interval until ctx is done
+func StartDedupCleanup(ctx context.Context, store DedupStore, interval time.Duration, logger oblogger.ObLogger) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	// The logger reads the request id of the context, ctx has none
+	logCtx := context.WithValue(ctx, "requestId", "dedup-cleanup")
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				n, err := store.Purge(ctx, now)
+				if err != nil {
+					logger.Error(logCtx, "[Idempotency]Cleanup: fail to purge processed messages", err)
+					continue
+				}
+				if n > 0 {
+					logger.Info(logCtx, "[Idempotency]Cleanup: purged processed messages", n)
+				}
+			}
+		}
+	}()
+}
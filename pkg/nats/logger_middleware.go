@@ -0,0 +1,50 @@
+package mynats
+
+import (
+	"context"
+	"time"
+
+	"github.com/gianglt2198/platforms/common"
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderCorrelationID = "correlation-id"
+	REQUEST_ID          = "requestId"
+)
+
+// LoggerMiddleware logs every message with its correlation id, which is also stored in
+// the context under common.KEY_CORRELATION_ID and the request id read by oblogger
+func LoggerMiddleware(logger oblogger.ObLogger) Middleware {
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			correlationId := msg.Header.Get(HeaderCorrelationID)
+			if correlationId == "" {
+				correlationId = uuid.NewString()
+			}
+
+			ctx = context.WithValue(ctx, common.KEY_CORRELATION_ID, correlationId)
+			ctx = context.WithValue(ctx, REQUEST_ID, correlationId)
+
+			logFields := []zap.Field{
+				zap.String("subject", msg.Subject),
+				zap.String("correlation_id", correlationId),
+			}
+
+			start := time.Now()
+			err := next(ctx, msg)
+			logFields = append(logFields, zap.Int64("duration_ms", time.Since(start).Milliseconds()))
+
+			if err != nil {
+				logger.GetLogger().With(logFields...).Error("Message failed", zap.Error(err))
+				return err
+			}
+
+			logger.GetLogger().With(logFields...).Info("Message succeeded")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package mynats
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gianglt2198/platforms/observability"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// MetricConfig holds configuration for the metric middleware
+type MetricConfig struct {
+	// ServiceName is the name of the service being measured
+	ServiceName string
+	// ServiceVersion is the version of the service
+	ServiceVersion string
+	// Skip defines a function to skip the middleware
+	Skip func(msg *nats.Msg) bool
+	// Metrics list to track messages
+	// *** counter ***
+	natsMessagesCounter       metric.Int64Counter
+	natsMessageErrorsCounter  metric.Int64Counter
+	natsActiveMessagesCounter metric.Int64UpDownCounter
+
+	// *** histogram ***
+	natsMessageDurationHistogram metric.Int64Histogram
+}
+
+// DefaultMetricConfig returns the default configuration
+func DefaultMetricConfig() MetricConfig {
+	return MetricConfig{
+		ServiceName:    "platform-app",
+		ServiceVersion: "1.0.0",
+	}
+}
+
+func (m MetricConfig) apply(cfg *MetricConfig) {
+	if m.ServiceName != "" {
+		cfg.ServiceName = m.ServiceName
+	}
+	if m.ServiceVersion != "" {
+		cfg.ServiceVersion = m.ServiceVersion
+	}
+	if m.Skip != nil {
+		cfg.Skip = m.Skip
+	}
+}
+
+// MetricMiddleware records the processing latency and the errors per subject
+func MetricMiddleware(configs ...MetricConfig) Middleware {
+	cfg := DefaultMetricConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	cfg.newCounters()
+	cfg.newHistograms()
+
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			if cfg.Skip != nil && cfg.Skip(msg) {
+				return next(ctx, msg)
+			}
+
+			start := time.Now().UTC()
+
+			metricAttributes := attribute.NewSet(
+				attribute.String("messaging.destination", msg.Subject),
+				attribute.Bool("messaging.is_request", msg.Reply != ""),
+				attribute.String("service.name", cfg.ServiceName),
+				attribute.String("service.version", cfg.ServiceVersion),
+			)
+
+			cfg.natsMessagesCounter.Add(ctx, 1, metric.WithAttributeSet(metricAttributes))
+			cfg.natsActiveMessagesCounter.Add(ctx, 1, metric.WithAttributeSet(metricAttributes))
+
+			// Process
+			err := next(ctx, msg)
+
+			// Recording Metric
+			cfg.natsActiveMessagesCounter.Add(ctx, -1, metric.WithAttributeSet(metricAttributes))
+			cfg.natsMessageDurationHistogram.Record(ctx,
+				time.Since(start).Milliseconds(),
+				metric.WithAttributeSet(metricAttributes))
+
+			if err != nil {
+				cfg.natsMessageErrorsCounter.Add(ctx, 1, metric.WithAttributeSet(metricAttributes))
+				return err
+			}
+
+			return nil
+		}
+	}
+}
+
+func (c *MetricConfig) newCounters() {
+	m := observability.Meter(c.ServiceName)
+
+	var err error
+
+	c.natsMessagesCounter, err = m.Int64Counter(
+		"nats_service_messages_total",
+		metric.WithDescription("Total number of NATS messages received."),
+		metric.WithUnit("{messages}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter nats message counter failed: %v", err)
+	}
+
+	c.natsMessageErrorsCounter, err = m.Int64Counter(
+		"nats_service_message_errors_total",
+		metric.WithDescription("Total number of NATS messages whose handler failed."),
+		metric.WithUnit("{messages}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter nats message error counter failed: %v", err)
+	}
+
+	c.natsActiveMessagesCounter, err = m.Int64UpDownCounter(
+		"nats_service_active_messages_total",
+		metric.WithDescription("Number of in-flight NATS messages."),
+		metric.WithUnit("{messages}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter nats active message counter failed: %v", err)
+	}
+}
+
+func (c *MetricConfig) newHistograms() {
+	m := observability.Meter(c.ServiceName)
+
+	var err error
+
+	c.natsMessageDurationHistogram, err = m.Int64Histogram(
+		"nats_service_message_duration_milliseconds",
+		metric.WithDescription("The processing duration of a NATS message."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter nats message duration failed: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package mynats
+
+import (
+	"context"
+	"encoding/json"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+)
+
+// Chain composes the middlewares, the first one is the outermost
+func Chain(ms ...Middleware) Middleware {
+	return func(next MsgHandler) MsgHandler {
+		for i := len(ms) - 1; i >= 0; i-- {
+			next = ms[i](next)
+		}
+		return next
+	}
+}
+
+// headerCarrier adapts nats.Header, whose keys are case sensitive, to the otel propagators
+type headerCarrier nats.Header
+
+func (h headerCarrier) Get(key string) string { return nats.Header(h).Get(key) }
+func (h headerCarrier) Set(key, value string) { nats.Header(h).Set(key, value) }
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectTrace writes the trace context of ctx into the header of an outgoing message
+func InjectTrace(ctx context.Context, header nats.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(header))
+}
+
+// ExtractTrace returns ctx carrying the trace context found in the header of msg
+func ExtractTrace(ctx context.Context, msg *nats.Msg) context.Context {
+	if msg.Header == nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier(msg.Header))
+}
+
+const (
+	HeaderServiceErrorCode = "Nats-Service-Error-Code"
+	HeaderServiceError     = "Nats-Service-Error"
+)
+
+// replyError answers a request with the error so the caller does not wait for its timeout,
+// the headers carry the NATS status registered for the code of the error
+func replyError(msg *nats.Msg, aerr *myerrors.AppError) {
+	if msg.Reply == "" {
+		return
+	}
+
+	payload, _ := json.Marshal(aerr)
+	reply := nats.NewMsg(msg.Reply)
+	reply.Data = payload
+	reply.Header.Set(HeaderServiceErrorCode, myerrors.NatsStatus(aerr))
+	reply.Header.Set(HeaderServiceError, aerr.Message)
+	_ = msg.RespondMsg(reply)
+}
@@ -0,0 +1,56 @@
+package mynats
+
+import (
+	"context"
+	"math"
+
+	"github.com/gianglt2198/platforms/common"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/ratelimit"
+	"github.com/nats-io/nats.go"
+)
+
+// MsgKeyFunc returns the key the messages are counted under, the messages without a key
+// are not limited
+type MsgKeyFunc func(ctx context.Context, msg *nats.Msg) string
+
+// KeyBySubject counts the messages by subject, for a limit shared by all the callers
+func KeyBySubject(_ context.Context, msg *nats.Msg) string {
+	return "subject:" + msg.Subject
+}
+
+// KeyByPrincipal counts the messages by the principal verified by PrincipalMiddleware, and
+// the anonymous ones by subject
+func KeyByPrincipal(ctx context.Context, msg *nats.Msg) string {
+	if p, ok := common.PrincipalFromContext(ctx); ok {
+		return "principal:" + p.ID
+	}
+	return KeyBySubject(ctx, msg)
+}
+
+// RateLimitMiddleware rejects the messages over the rate of their key, KeyBySubject when
+// keyFunc is nil. A rejected request is answered with ratelimit.001
+func RateLimitMiddleware(limiter *ratelimit.Limiter, keyFunc MsgKeyFunc) Middleware {
+	if keyFunc == nil {
+		keyFunc = KeyBySubject
+	}
+
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			key := keyFunc(ctx, msg)
+			if key == "" {
+				return next(ctx, msg)
+			}
+
+			if res, _ := limiter.Allow(ctx, key); !res.Allowed {
+				aerr := myerrors.TooManyRequests().
+					WithCause(ratelimit.ErrLimited).
+					WithMetadata("retry_after", int(math.Ceil(res.RetryAfter.Seconds())))
+				replyError(msg, aerr)
+				return aerr
+			}
+
+			return next(ctx, msg)
+		}
+	}
+}
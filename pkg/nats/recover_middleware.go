@@ -0,0 +1,28 @@
+package mynats
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	"github.com/nats-io/nats.go"
+)
+
+// RecoverMiddleware turns a panic of the handler into an error instead of crashing the process
+func RecoverMiddleware(logger oblogger.ObLogger) Middleware {
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic handling %s: %v", msg.Subject, r)
+					logger.Error(ctx, "[NatsMiddleware]Recover: "+string(debug.Stack()), err)
+					replyError(msg, myerrors.InternalFailure("unexpected failure while handling the message"))
+				}
+			}()
+
+			return next(ctx, msg)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package mynats
+
+import (
+	"context"
+	"time"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/nats-io/nats.go"
+)
+
+// TimeoutMiddleware cancels the context of the handler after timeout, a handler that
+// ignores its context keeps running in the background once the error is returned
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- next(ctx, msg)
+			}()
+
+			select {
+			case err := <-done:
+				return err
+			case <-ctx.Done():
+				aerr := myerrors.MQTimeout().WithData(msg.Subject)
+				replyError(msg, aerr)
+				return aerr
+			}
+		}
+	}
+}
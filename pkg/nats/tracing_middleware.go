@@ -0,0 +1,93 @@
+package mynats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gianglt2198/platforms/observability"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingConfig holds configuration for the tracing middleware
+type TracingConfig struct {
+	// ServiceName is the name of the service being traced
+	ServiceName string
+	// ServiceVersion is the version of the service
+	ServiceVersion string
+	// Skip defines a function to skip the middleware
+	Skip func(msg *nats.Msg) bool
+}
+
+// DefaultTracingConfig returns the default configuration
+func DefaultTracingConfig() TracingConfig {
+	return TracingConfig{
+		ServiceName:    "platform-app",
+		ServiceVersion: "1.0.0",
+	}
+}
+
+func (m TracingConfig) apply(cfg *TracingConfig) {
+	if m.ServiceName != "" {
+		cfg.ServiceName = m.ServiceName
+	}
+	if m.ServiceVersion != "" {
+		cfg.ServiceVersion = m.ServiceVersion
+	}
+	if m.Skip != nil {
+		cfg.Skip = m.Skip
+	}
+}
+
+// TracingMiddleware continues the trace propagated in the message header with a consumer span
+func TracingMiddleware(configs ...TracingConfig) Middleware {
+	cfg := DefaultTracingConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			if cfg.Skip != nil && cfg.Skip(msg) {
+				return next(ctx, msg)
+			}
+
+			ctx = ExtractTrace(ctx, msg)
+			tracingCtx, span := observability.Tracer(msg.Subject).Start(ctx, "nats "+msg.Subject,
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("messaging.system", "nats"),
+					attribute.String("messaging.destination", msg.Subject),
+					attribute.String("messaging.correlation_id", msg.Header.Get(HeaderCorrelationID)),
+					attribute.Int("messaging.message_payload_size_bytes", len(msg.Data)),
+					attribute.Bool("messaging.is_request", msg.Reply != ""),
+					attribute.String("service.name", cfg.ServiceName),
+					attribute.String("service.version", cfg.ServiceVersion),
+				),
+			)
+			defer span.End()
+
+			start := time.Now()
+			err := next(tracingCtx, msg)
+
+			span.SetAttributes(
+				attribute.Float64("messaging.duration_ms", float64(time.Since(start).Milliseconds())),
+			)
+
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				span.RecordError(err, trace.WithAttributes(
+					attribute.String("error.type", fmt.Sprintf("%T", err)),
+					attribute.String("error.message", err.Error()),
+				))
+				return err
+			}
+
+			span.SetStatus(codes.Ok, "message handled")
+			return nil
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 
+	myerrors "github.com/gianglt2198/platforms/errors"
 	"github.com/gianglt2198/platforms/pkg/utils"
 )
 
@@ -19,7 +20,7 @@ func UseCase[T any, U any](
 		output, aerr := f(ctx, *input)
 		var replyData []byte
 		if aerr != nil {
-			replyData, _ = json.Marshal(aerr)
+			replyData, _ = json.Marshal(myerrors.From(aerr))
 		} else {
 			replyData, _ = json.Marshal(output)
 		}
@@ -0,0 +1,34 @@
+package mynats
+
+import (
+	"context"
+	"encoding/json"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/validation"
+	"github.com/nats-io/nats.go"
+)
+
+// ValidatorMiddleware decodes the payload into T and checks it with the validator shared
+// by the transports, its rules and the RequestValidator hook included. An invalid request
+// is answered with a payload error listing the failed fields
+func ValidatorMiddleware[T any]() Middleware {
+	return func(next MsgHandler) MsgHandler {
+		return func(ctx context.Context, msg *nats.Msg) error {
+			var data T
+			if err := json.Unmarshal(msg.Data, &data); err != nil {
+				aerr := myerrors.PayloadInvalid(err.Error()).WithCause(err)
+				replyError(msg, aerr)
+				return aerr
+			}
+
+			if err := validation.Validate(ctx, &data); err != nil {
+				aerr := validation.AppError(err)
+				replyError(msg, aerr)
+				return aerr
+			}
+
+			return next(ctx, msg)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package problem
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/validation"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	// Problem is an error response following RFC 7807, the transports render it as is or
+	// map it to their own errors, e.g. a gRPC status
+	Problem struct {
+		Type      string                `json:"type"`
+		Title     string                `json:"title"`
+		Status    int                   `json:"status"`
+		Detail    string                `json:"detail,omitempty"`
+		Instance  string                `json:"instance,omitempty"`
+		Code      string                `json:"code,omitempty"`
+		RequestID string                `json:"request_id,omitempty"`
+		Errors    []myerrors.FieldError `json:"errors,omitempty"`
+		Metadata  map[string]any        `json:"metadata,omitempty"`
+	}
+
+	// Config holds configuration for the conversion of the errors
+	Config struct {
+		// IsProdEnv hides the detail of the server errors and the internal metadata of all
+		// the errors
+		IsProdEnv bool
+		// TypeURI returns the type of the problem of an error code, "urn:problem:<code>" by default
+		TypeURI func(code string) string
+		// Catalog localizes the details and the field errors, myerrors.DefaultCatalog by default
+		Catalog *myerrors.Catalog
+		// StatusOf returns the status and the detail of the errors of a transport, e.g. the
+		// fiber errors, the status of an AppError wrapped with one of them is overridden
+		StatusOf func(err error) (status int, detail string, ok bool)
+	}
+)
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		TypeURI: func(code string) string {
+			return "urn:problem:" + code
+		},
+		Catalog: myerrors.DefaultCatalog(),
+	}
+}
+
+func (m Config) apply(cfg *Config) {
+	if m.IsProdEnv {
+		cfg.IsProdEnv = true
+	}
+	if m.TypeURI != nil {
+		cfg.TypeURI = m.TypeURI
+	}
+	if m.Catalog != nil {
+		cfg.Catalog = m.Catalog
+	}
+	if m.StatusOf != nil {
+		cfg.StatusOf = m.StatusOf
+	}
+}
+
+// New converts err, validation errors, an AppError, an error known by StatusOf or any error,
+// to a problem in locale
+func New(err error, locale string, configs ...Config) *Problem {
+	cfg := DefaultConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	problem := &Problem{Status: http.StatusInternalServerError}
+
+	var (
+		appErr     *myerrors.AppError
+		validErrs  validator.ValidationErrors
+		invalidErr *validator.InvalidValidationError
+	)
+	status, detail, known := 0, "", false
+	if cfg.StatusOf != nil {
+		status, detail, known = cfg.StatusOf(err)
+	}
+
+	switch {
+	case errors.As(err, &validErrs):
+		problem.Status = http.StatusBadRequest
+		problem.Code = "payload.001"
+		for _, fe := range validErrs {
+			problem.Errors = append(problem.Errors, myerrors.FieldError{
+				Field:   validation.FieldPath(fe),
+				Code:    fe.Tag(),
+				Message: cfg.Catalog.FieldMessage(locale, fe.Tag(), fe.Field(), fe.Param()),
+			})
+		}
+	case errors.As(err, &appErr):
+		problem.Status = appErr.Status
+		problem.Code = appErr.Code
+		problem.Detail = appErr.Message
+		if appErr.Details != nil {
+			for _, fe := range appErr.Details.Fields {
+				if fe.Message == "" {
+					fe.Message = cfg.Catalog.FieldMessage(locale, fe.Code, fe.Field, "")
+				}
+				problem.Errors = append(problem.Errors, fe)
+			}
+			problem.Metadata = maps.Clone(appErr.Details.Metadata)
+			// The internal metadata, e.g. the tables of the database errors, is shown outside
+			// production only whatever the status
+			if !cfg.IsProdEnv && len(appErr.Details.Internal) > 0 {
+				if problem.Metadata == nil {
+					problem.Metadata = map[string]any{}
+				}
+				maps.Copy(problem.Metadata, appErr.Details.Internal)
+			}
+		}
+		// An AppError wrapped with an error of the transport keeps the status of the latter
+		if known && status != 0 {
+			problem.Status = status
+		}
+	case known:
+		problem.Status = status
+		problem.Detail = detail
+	case errors.As(err, &invalidErr):
+		problem.Detail = invalidErr.Error()
+	default:
+		problem.Detail = err.Error()
+	}
+
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	problem.Title = http.StatusText(problem.Status)
+
+	// The detail of a server error may leak internals such as SQL, only the message
+	// of its code in the catalog is shown
+	if cfg.IsProdEnv && problem.Status >= http.StatusInternalServerError {
+		problem.Detail = ""
+		problem.Metadata = nil
+	}
+
+	if problem.Code != "" {
+		problem.Type = cfg.TypeURI(problem.Code)
+		problem.localize(cfg, locale)
+	} else {
+		problem.Type = "about:blank"
+	}
+
+	return problem
+}
+
+// localize replaces the detail by the message of the code, the original message is kept
+// in the metadata outside production
+func (p *Problem) localize(cfg Config, locale string) {
+	message, ok := cfg.Catalog.Message(locale, p.Code)
+	if !ok {
+		return
+	}
+
+	// The default message of the code adds nothing to its translation
+	if info, ok := myerrors.LookupCode(p.Code); ok && info.Message == p.Detail {
+		p.Detail = ""
+	}
+
+	if !cfg.IsProdEnv && p.Detail != "" && p.Detail != message {
+		if p.Metadata == nil {
+			p.Metadata = map[string]any{}
+		}
+		p.Metadata["message"] = p.Detail
+	}
+	p.Detail = message
+}
@@ -0,0 +1,385 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	mydatabase "github.com/gianglt2198/platforms/database"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	Eq        Operator = "eq"
+	Ne        Operator = "ne"
+	Gt        Operator = "gt"
+	Gte       Operator = "gte"
+	Lt        Operator = "lt"
+	Lte       Operator = "lte"
+	In        Operator = "in"
+	NotIn     Operator = "nin"
+	Contains  Operator = "contains"
+	IContains Operator = "icontains"
+	Prefix    Operator = "prefix"
+	Null      Operator = "null"
+)
+
+var (
+	// Equality are the operators of the identifiers and the enums
+	Equality = []Operator{Eq, Ne, In, NotIn}
+	// Comparison are the operators of the numbers and the dates
+	Comparison = []Operator{Eq, Ne, Gt, Gte, Lt, Lte, In, NotIn}
+	// Text are the operators of the free texts
+	Text = []Operator{Eq, Ne, In, NotIn, Contains, IContains, Prefix}
+
+	comparisons = map[Operator]string{Eq: " = ?", Ne: " <> ?", Gt: " > ?", Gte: " >= ?", Lt: " < ?", Lte: " <= ?"}
+	schemaCache = &sync.Map{}
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+)
+
+type (
+	// Operator compares a column to the value of a filter, filter[name][op]=value. A filter
+	// without operator is an equality
+	Operator string
+
+	// Field is a field of the entity the query may use
+	Field struct {
+		// Name is the name in the query string and the column of the entity, unless Column
+		// is set
+		Name   string
+		Column string
+		// Filter are the operators allowed in the filters, the field cannot be filtered
+		// when empty
+		Filter []Operator
+		// Sort allows the field in sort
+		Sort bool
+		// Select allows the field in fields
+		Select bool
+	}
+
+	// Config holds configuration for a Parser
+	Config struct {
+		// DefaultSort is the sort of the queries without one, like -created_at, it may use
+		// the fields not sortable
+		DefaultSort string
+		// DefaultPageSize and MaxPageSize bound page[size], 20 and 100 by default
+		DefaultPageSize int
+		MaxPageSize     int
+	}
+
+	// Parser turns the query strings of the lists of an entity into FindOption:
+	//
+	//	?filter[status]=active&filter[created_at][gte]=2024-01-01&sort=-created_at&fields=id,name&page[number]=2&page[size]=20
+	//
+	// The columns and the directions of the where and order clauses only come from the
+	// declared fields, the values are parameters
+	Parser struct {
+		cfg    Config
+		fields map[string]field
+		names  []string
+		order  string
+	}
+
+	field struct {
+		Field
+		typ reflect.Type
+	}
+)
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		DefaultPageSize: 20,
+		MaxPageSize:     100,
+	}
+}
+
+func (m Config) apply(cfg *Config) {
+	if m.DefaultSort != "" {
+		cfg.DefaultSort = m.DefaultSort
+	}
+	if m.DefaultPageSize > 0 {
+		cfg.DefaultPageSize = m.DefaultPageSize
+	}
+	if m.MaxPageSize > 0 {
+		cfg.MaxPageSize = m.MaxPageSize
+	}
+}
+
+// New returns the parser of the queries of T, the types of the values come from the fields
+// of T. It panics when a field is not a column of T or the default sort is invalid, as no
+// query could be parsed
+func New[T any](fields []Field, configs ...Config) *Parser {
+	cfg := DefaultConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	sch, err := schema.Parse(new(T), schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		panic(fmt.Sprintf("query: cannot parse the schema of %T: %v", *new(T), err))
+	}
+
+	p := &Parser{cfg: cfg, fields: map[string]field{}}
+	for _, f := range fields {
+		if f.Column == "" {
+			f.Column = f.Name
+		}
+		sf, ok := sch.FieldsByDBName[f.Column]
+		if !ok {
+			panic(fmt.Sprintf("query: %s has no column %s", sch.Name, f.Column))
+		}
+		p.fields[f.Name] = field{Field: f, typ: sf.FieldType}
+		p.names = append(p.names, f.Name)
+	}
+
+	if cfg.DefaultSort != "" {
+		p.order = p.orderOf(cfg.DefaultSort, func(name string) (string, bool) {
+			if f, ok := p.fields[name]; ok {
+				return f.Column, true
+			}
+			_, ok := sch.FieldsByDBName[name]
+			return name, ok
+		})
+		if p.order == "" {
+			panic(fmt.Sprintf("query: invalid default sort %s, %s has no such columns", cfg.DefaultSort, sch.Name))
+		}
+	}
+
+	return p
+}
+
+// Fields returns the declared fields in order
+func (p *Parser) Fields() []Field {
+	fields := make([]Field, 0, len(p.names))
+	for _, name := range p.names {
+		fields = append(fields, p.fields[name].Field)
+	}
+	return fields
+}
+
+// Type returns the type of the values of a field
+func (p *Parser) Type(name string) reflect.Type {
+	return p.fields[name].typ
+}
+
+// Parse returns the options of the query, the parameters other than filter, sort, fields
+// and page are left to the caller. Every invalid parameter is reported as a field of a
+// payload.001 error
+func (p *Parser) Parse(values url.Values) (*mydatabase.FindOption, *myerrors.AppError) {
+	option := &mydatabase.FindOption{
+		Page:  1,
+		Take:  p.cfg.DefaultPageSize,
+		Order: p.order,
+	}
+
+	aerr := myerrors.PayloadInvalid("query parameters are invalid")
+	invalid := false
+	reject := func(param, code, message string) {
+		aerr = aerr.WithField(param, code, message)
+		invalid = true
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var where []string
+	for _, key := range keys {
+		value := values.Get(key)
+
+		switch {
+		case key == "sort":
+			order, err := p.sort(value)
+			if err != "" {
+				reject(key, "sort", err)
+				continue
+			}
+			option.Order = order
+		case key == "fields":
+			columns, err := p.selection(value)
+			if err != "" {
+				reject(key, "fields", err)
+				continue
+			}
+			option.Select = &columns
+		case key == "page[number]":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				reject(key, "min", "page[number] must be a number of at least 1")
+				continue
+			}
+			option.Page = n
+		case key == "page[size]":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > p.cfg.MaxPageSize {
+				reject(key, "max", fmt.Sprintf("page[size] must be a number between 1 and %d", p.cfg.MaxPageSize))
+				continue
+			}
+			option.Take = n
+		case strings.HasPrefix(key, "page["):
+			reject(key, "unknown", "page takes number and size")
+		case strings.HasPrefix(key, "filter["):
+			cond, params, code, err := p.filter(key, value)
+			if err != "" {
+				reject(key, code, err)
+				continue
+			}
+			where = append(where, cond)
+			option.Params = append(option.Params, params...)
+		}
+	}
+
+	if invalid {
+		return nil, aerr
+	}
+
+	option.Where = strings.Join(where, " AND ")
+	return option, nil
+}
+
+// filter translates filter[name] or filter[name][op], it returns the code and the message
+// of the error when the filter is invalid
+func (p *Parser) filter(key, value string) (string, []any, string, string) {
+	name, rest, ok := strings.Cut(strings.TrimPrefix(key, "filter["), "]")
+	op := Eq
+	switch {
+	case !ok:
+		return "", nil, "syntax", "a filter is filter[field] or filter[field][operator]"
+	case rest == "":
+	case strings.HasPrefix(rest, "[") && strings.HasSuffix(rest, "]") && len(rest) > 2:
+		op = Operator(rest[1 : len(rest)-1])
+	default:
+		return "", nil, "syntax", "a filter is filter[field] or filter[field][operator]"
+	}
+
+	f, ok := p.fields[name]
+	if !ok || len(f.Filter) == 0 {
+		return "", nil, "unknown", fmt.Sprintf("cannot filter by %s, allowed: %s", name, strings.Join(p.allowed(func(f Field) bool { return len(f.Filter) > 0 }), ", "))
+	}
+	if !slices.Contains(f.Filter, op) {
+		return "", nil, "operator", fmt.Sprintf("cannot filter %s with %s, allowed: %s", name, op, joinOperators(f.Filter))
+	}
+
+	column := f.Column
+	switch op {
+	case In, NotIn:
+		var items []any
+		for _, item := range strings.Split(value, ",") {
+			v, err := convert(f.typ, strings.TrimSpace(item))
+			if err != nil {
+				return "", nil, "type", fmt.Sprintf("%s is not a valid %s", item, typeName(f.typ))
+			}
+			items = append(items, v)
+		}
+		if op == In {
+			return column + " IN ?", []any{items}, "", ""
+		}
+		return column + " NOT IN ?", []any{items}, "", ""
+	case Null:
+		isNull, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", nil, "type", fmt.Sprintf("%s is not a valid boolean", value)
+		}
+		if isNull {
+			return column + " IS NULL", nil, "", ""
+		}
+		return column + " IS NOT NULL", nil, "", ""
+	case Contains:
+		return column + ` LIKE ? ESCAPE '\'`, []any{"%" + likeEscaper.Replace(value) + "%"}, "", ""
+	case IContains:
+		return "LOWER(" + column + `) LIKE LOWER(?) ESCAPE '\'`, []any{"%" + likeEscaper.Replace(value) + "%"}, "", ""
+	case Prefix:
+		return column + ` LIKE ? ESCAPE '\'`, []any{likeEscaper.Replace(value) + "%"}, "", ""
+	}
+
+	v, err := convert(f.typ, value)
+	if err != nil {
+		return "", nil, "type", fmt.Sprintf("%s is not a valid %s", value, typeName(f.typ))
+	}
+
+	comparison, ok := comparisons[op]
+	if !ok {
+		return "", nil, "operator", fmt.Sprintf("unknown operator %s", op)
+	}
+	return column + comparison, []any{v}, "", ""
+}
+
+// sort translates -created_at,name to the order clause
+func (p *Parser) sort(value string) (string, string) {
+	if value == "" {
+		return p.order, ""
+	}
+
+	var unknown string
+	order := p.orderOf(value, func(name string) (string, bool) {
+		if f, ok := p.fields[name]; ok && f.Sort {
+			return f.Column, true
+		}
+		unknown = name
+		return "", false
+	})
+	if unknown != "" || order == "" {
+		return "", fmt.Sprintf("cannot sort by %s, allowed: %s", unknown, strings.Join(p.allowed(func(f Field) bool { return f.Sort }), ", "))
+	}
+	return order, ""
+}
+
+func (p *Parser) orderOf(value string, column func(name string) (string, bool)) string {
+	var clauses []string
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+		direction := "ASC"
+		if strings.HasPrefix(key, "-") {
+			key, direction = key[1:], "DESC"
+		}
+		c, ok := column(key)
+		if !ok {
+			return ""
+		}
+		clauses = append(clauses, c+" "+direction)
+	}
+	return strings.Join(clauses, ", ")
+}
+
+// selection translates id,name to the selected columns
+func (p *Parser) selection(value string) ([]string, string) {
+	var columns []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		f, ok := p.fields[name]
+		if !ok || !f.Select {
+			return nil, fmt.Sprintf("cannot select %s, allowed: %s", name, strings.Join(p.allowed(func(f Field) bool { return f.Select }), ", "))
+		}
+		if !slices.Contains(columns, f.Column) {
+			columns = append(columns, f.Column)
+		}
+	}
+	return columns, ""
+}
+
+func (p *Parser) allowed(keep func(f Field) bool) []string {
+	var names []string
+	for _, name := range p.names {
+		if keep(p.fields[name].Field) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func joinOperators(ops []Operator) string {
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = string(op)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,234 @@
+package query
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+)
+
+type widget struct {
+	ID        int
+	Name      string
+	Status    string
+	Price     float64
+	CreatedAt time.Time
+	DeletedAt *time.Time
+}
+
+func newParser() *Parser {
+	return New[widget]([]Field{
+		{Name: "id", Filter: Equality, Sort: true, Select: true},
+		{Name: "name", Filter: Text, Sort: true, Select: true},
+		{Name: "status", Filter: Equality, Select: true},
+		{Name: "price", Filter: Comparison, Sort: true},
+		{Name: "created", Column: "created_at", Filter: Comparison, Sort: true},
+		{Name: "deleted_at", Filter: []Operator{Null}},
+	}, Config{DefaultSort: "-created_at", MaxPageSize: 50})
+}
+
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		where  string
+		params []any
+	}{
+		{
+			name:   "equality without operator",
+			query:  "filter[status]=active",
+			where:  "status = ?",
+			params: []any{"active"},
+		},
+		{
+			name:   "typed comparison",
+			query:  "filter[price][gte]=9.5",
+			where:  "price >= ?",
+			params: []any{9.5},
+		},
+		{
+			name:   "date of a renamed column",
+			query:  "filter[created][lt]=2024-01-02",
+			where:  "created_at < ?",
+			params: []any{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "in converts each item",
+			query:  "filter[id][in]=1, 2,3",
+			where:  "id IN ?",
+			params: []any{[]any{1, 2, 3}},
+		},
+		{
+			name:   "nin",
+			query:  "filter[status][nin]=a,b",
+			where:  "status NOT IN ?",
+			params: []any{[]any{"a", "b"}},
+		},
+		{
+			name:  "null",
+			query: "filter[deleted_at][null]=false",
+			where: "deleted_at IS NOT NULL",
+		},
+		{
+			name:   "contains escapes the wildcards",
+			query:  `filter[name][contains]=50%25_off\`,
+			where:  `name LIKE ? ESCAPE '\'`,
+			params: []any{`%50\%\_off\\%`},
+		},
+		{
+			name:   "icontains",
+			query:  "filter[name][icontains]=Ab",
+			where:  `LOWER(name) LIKE LOWER(?) ESCAPE '\'`,
+			params: []any{"%Ab%"},
+		},
+		{
+			name:   "prefix",
+			query:  "filter[name][prefix]=a_",
+			where:  `name LIKE ? ESCAPE '\'`,
+			params: []any{`a\_%`},
+		},
+		{
+			name:   "filters joined in key order",
+			query:  "filter[status]=on&filter[id]=7",
+			where:  "id = ? AND status = ?",
+			params: []any{7, "on"},
+		},
+	}
+
+	p := newParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			option, aerr := p.Parse(values)
+			if aerr != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.query, aerr)
+			}
+			if option.Where != tt.where {
+				t.Errorf("where = %q, want %q", option.Where, tt.where)
+			}
+			if !reflect.DeepEqual(option.Params, tt.params) {
+				t.Errorf("params = %#v, want %#v", option.Params, tt.params)
+			}
+		})
+	}
+}
+
+func TestParseSortFieldsAndPage(t *testing.T) {
+	p := newParser()
+
+	option, aerr := p.Parse(url.Values{})
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+	if option.Order != "created_at DESC" || option.Page != 1 || option.Take != 20 || option.Select != nil {
+		t.Errorf("defaults = %q page %d take %d select %v", option.Order, option.Page, option.Take, option.Select)
+	}
+
+	option, aerr = p.Parse(url.Values{
+		"sort":         {"-price, name"},
+		"fields":       {"id,name,id"},
+		"page[number]": {"3"},
+		"page[size]":   {"50"},
+	})
+	if aerr != nil {
+		t.Fatal(aerr)
+	}
+	if option.Order != "price DESC, name ASC" {
+		t.Errorf("order = %q", option.Order)
+	}
+	if option.Select == nil || !reflect.DeepEqual(*option.Select, []string{"id", "name"}) {
+		t.Errorf("select = %v", option.Select)
+	}
+	if option.Page != 3 || option.Take != 50 {
+		t.Errorf("page %d take %d", option.Page, option.Take)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		field string
+		code  string
+	}{
+		{"unknown filter field", "filter[secret]=1", "filter[secret]", "unknown"},
+		{"equality of a null filter", "filter[deleted_at][eq]=x", "filter[deleted_at][eq]", "operator"},
+		{"operator not allowed", "filter[status][gt]=a", "filter[status][gt]", "operator"},
+		{"unknown operator", "filter[price][between]=1", "filter[price][between]", "operator"},
+		{"filter syntax", "filter[name]x=1", "filter[name]x", "syntax"},
+		{"value type", "filter[price][gt]=cheap", "filter[price][gt]", "type"},
+		{"in item type", "filter[id][in]=1,two", "filter[id][in]", "type"},
+		{"null type", "filter[deleted_at][null]=maybe", "filter[deleted_at][null]", "type"},
+		{"sort not allowed", "sort=status", "sort", "sort"},
+		{"sort unknown", "sort=-secret", "sort", "sort"},
+		{"select not allowed", "fields=id,price", "fields", "fields"},
+		{"page number zero", "page[number]=0", "page[number]", "min"},
+		{"page number not a number", "page[number]=x", "page[number]", "min"},
+		{"page size too large", "page[size]=51", "page[size]", "max"},
+		{"page size zero", "page[size]=0", "page[size]", "max"},
+		{"unknown page parameter", "page[offset]=1", "page[offset]", "unknown"},
+	}
+
+	p := newParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			option, aerr := p.Parse(values)
+			if aerr == nil {
+				t.Fatalf("Parse(%q) = %+v, want an error", tt.query, option)
+			}
+			if aerr.Code != myerrors.PayloadInvalid("").Code {
+				t.Errorf("code = %s", aerr.Code)
+			}
+			if aerr.Details == nil || len(aerr.Details.Fields) != 1 {
+				t.Fatalf("fields = %+v, want one", aerr.Details)
+			}
+			if fe := aerr.Details.Fields[0]; fe.Field != tt.field || fe.Code != tt.code {
+				t.Errorf("field error = %s %s, want %s %s", fe.Field, fe.Code, tt.field, tt.code)
+			}
+		})
+	}
+}
+
+func TestParseReportsEveryInvalidParameter(t *testing.T) {
+	_, aerr := newParser().Parse(url.Values{
+		"sort":            {"secret"},
+		"filter[name][x]": {"a"},
+		"page[size]":      {"1000"},
+	})
+	if aerr == nil || aerr.Details == nil || len(aerr.Details.Fields) != 3 {
+		t.Fatalf("error = %+v, want three fields", aerr)
+	}
+}
+
+func TestNewPanics(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []Field
+		cfg    Config
+	}{
+		{"unknown column", []Field{{Name: "secret"}}, Config{}},
+		{"invalid default sort", nil, Config{DefaultSort: "secret"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("New did not panic")
+				}
+			}()
+			New[widget](tt.fields, tt.cfg)
+		})
+	}
+}
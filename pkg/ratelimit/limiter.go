@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrLimited = errors.New("rate limit exceeded")
+
+type (
+	// Config holds configuration for a limiter
+	Config struct {
+		Rate  Rate
+		Store Store
+		// FailClosed rejects the requests when the store fails, they are allowed by default
+		FailClosed bool
+	}
+
+	// Limiter applies a rate to keys, like the caller or the route of the requests
+	Limiter struct {
+		name string
+		cfg  Config
+	}
+)
+
+// DefaultConfig returns the default configuration, 100 requests per minute in memory
+func DefaultConfig() Config {
+	return Config{
+		Rate:  PerMinute(100),
+		Store: NewMemoryStore(),
+	}
+}
+
+func (m Config) apply(cfg *Config) {
+	if m.Rate.Limit > 0 && m.Rate.Period > 0 {
+		cfg.Rate = m.Rate
+	}
+	if m.Store != nil {
+		cfg.Store = m.Store
+	}
+	if m.FailClosed {
+		cfg.FailClosed = true
+	}
+}
+
+// New returns a limiter, name labels its metrics and namespaces its keys in the store
+func New(name string, configs ...Config) *Limiter {
+	cfg := DefaultConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	return &Limiter{name: name, cfg: cfg}
+}
+
+func (l *Limiter) Name() string { return l.name }
+
+func (l *Limiter) Rate() Rate { return l.cfg.Rate }
+
+// Allow takes a request of key, the error of the store is returned along an allowing or
+// rejecting result depending on FailClosed
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	res, err := l.cfg.Store.Take(ctx, l.name+":"+l.cfg.Rate.Algorithm.String()+":"+key, l.cfg.Rate)
+	if err != nil {
+		getMetrics().failed(ctx, l.name)
+		return Result{Allowed: !l.cfg.FailClosed, Limit: l.cfg.Rate.Limit}, err
+	}
+
+	if res.Allowed {
+		getMetrics().allowed(ctx, l.name)
+	} else {
+		getMetrics().rejected(ctx, l.name)
+	}
+	return res, nil
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type (
+	state struct {
+		// window is the start of the current window, or the last refill of the bucket
+		window time.Time
+		prev   int
+		count  int
+		tokens float64
+		// expiresAt is when the state is back to its initial value and can be dropped
+		expiresAt time.Time
+	}
+
+	// MemoryStore keeps the limits in the process, each replica of a service then applies
+	// the rate on its own
+	MemoryStore struct {
+		mu        sync.Mutex
+		states    map[string]*state
+		lastSweep time.Time
+		now       func() time.Time
+	}
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states:    map[string]*state{},
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, rate Rate) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	st, ok := s.states[key]
+	if !ok || now.After(st.expiresAt) {
+		st = &state{window: now, tokens: float64(rate.burst())}
+		if rate.Algorithm != TokenBucket {
+			st.window = now.Truncate(rate.Period)
+		}
+		s.states[key] = st
+	}
+
+	var res Result
+	switch rate.Algorithm {
+	case FixedWindow:
+		if window := now.Truncate(rate.Period); window.After(st.window) {
+			st.window, st.count = window, 0
+		}
+		allowed := st.count < rate.Limit
+		if allowed {
+			st.count++
+		}
+		reset := st.window.Add(rate.Period).Sub(now)
+		st.expiresAt = now.Add(reset)
+		res = fixedWindowResult(rate, st.count, allowed, reset)
+
+	case SlidingWindow:
+		if window := now.Truncate(rate.Period); window.After(st.window) {
+			if window.Sub(st.window) == rate.Period {
+				st.prev = st.count
+			} else {
+				st.prev = 0
+			}
+			st.window, st.count = window, 0
+		}
+		elapsed := now.Sub(st.window)
+		used := float64(st.prev)*float64(rate.Period-elapsed)/float64(rate.Period) + float64(st.count)
+		allowed := used+1 <= float64(rate.Limit)
+		if allowed {
+			st.count++
+		}
+		st.expiresAt = st.window.Add(2 * rate.Period)
+		res = slidingWindowResult(rate, st.prev, st.count, elapsed, allowed)
+
+	default:
+		perToken := float64(rate.Period) / float64(rate.Limit)
+		st.tokens = min(float64(rate.burst()), st.tokens+float64(now.Sub(st.window))/perToken)
+		st.window = now
+		allowed := st.tokens >= 1
+		if allowed {
+			st.tokens--
+		}
+		res = tokenBucketResult(rate, st.tokens, allowed)
+		st.expiresAt = now.Add(res.Reset)
+	}
+
+	return res, nil
+}
+
+// sweep drops the states back to their initial value
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, st := range s.states {
+		if now.After(st.expiresAt) {
+			delete(s.states, key)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// clock is the time of a MemoryStore moved by the tests
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestStore(start time.Time) (*MemoryStore, *clock) {
+	c := &clock{now: start}
+	s := NewMemoryStore()
+	s.now = func() time.Time { return c.now }
+	s.lastSweep = start
+	return s, c
+}
+
+func take(t *testing.T, s *MemoryStore, key string, rate Rate) Result {
+	t.Helper()
+	res, err := s.Take(context.Background(), key, rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestFixedWindow(t *testing.T) {
+	s, c := newTestStore(time.Date(2024, 1, 1, 0, 0, 10, 0, time.UTC))
+	rate := Rate{Algorithm: FixedWindow, Limit: 3, Period: time.Minute}
+
+	for i, remaining := range []int{2, 1, 0} {
+		res := take(t, s, "k", rate)
+		if !res.Allowed || res.Remaining != remaining || res.Reset != 50*time.Second {
+			t.Fatalf("request %d = %+v", i, res)
+		}
+	}
+
+	res := take(t, s, "k", rate)
+	if res.Allowed || res.RetryAfter != 50*time.Second {
+		t.Fatalf("over the limit = %+v", res)
+	}
+
+	// The keys are limited apart
+	if res := take(t, s, "other", rate); !res.Allowed {
+		t.Fatalf("other key = %+v", res)
+	}
+
+	c.advance(50 * time.Second)
+	if res := take(t, s, "k", rate); !res.Allowed || res.Remaining != 2 || res.Reset != time.Minute {
+		t.Fatalf("next window = %+v", res)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	s, c := newTestStore(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	rate := Rate{Algorithm: SlidingWindow, Limit: 10, Period: time.Minute}
+
+	for i := 0; i < 10; i++ {
+		if res := take(t, s, "k", rate); !res.Allowed {
+			t.Fatalf("request %d = %+v", i, res)
+		}
+	}
+	if res := take(t, s, "k", rate); res.Allowed {
+		t.Fatalf("over the limit = %+v", res)
+	}
+
+	// Half way through the next window the previous one weighs 5 requests
+	c.advance(90 * time.Second)
+	for i := 0; i < 4; i++ {
+		if res := take(t, s, "k", rate); !res.Allowed {
+			t.Fatalf("request %d of the next window = %+v", i, res)
+		}
+	}
+	res := take(t, s, "k", rate)
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("last request of the next window = %+v", res)
+	}
+
+	res = take(t, s, "k", rate)
+	if res.Allowed || res.RetryAfter != 6*time.Second {
+		t.Fatalf("over the weighted limit = %+v", res)
+	}
+
+	c.advance(res.RetryAfter)
+	if res := take(t, s, "k", rate); !res.Allowed {
+		t.Fatalf("after RetryAfter = %+v", res)
+	}
+
+	// A window without requests in between forgets the previous one
+	c.advance(2 * time.Minute)
+	if res := take(t, s, "k", rate); !res.Allowed || res.Remaining != 9 {
+		t.Fatalf("after an empty window = %+v", res)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	s, c := newTestStore(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	rate := Rate{Algorithm: TokenBucket, Limit: 2, Period: time.Second, Burst: 4}
+
+	for i, remaining := range []int{3, 2, 1, 0} {
+		res := take(t, s, "k", rate)
+		if !res.Allowed || res.Remaining != remaining || res.Limit != 4 {
+			t.Fatalf("request %d = %+v", i, res)
+		}
+	}
+
+	res := take(t, s, "k", rate)
+	if res.Allowed || res.RetryAfter != 500*time.Millisecond || res.Reset != 2*time.Second {
+		t.Fatalf("empty bucket = %+v", res)
+	}
+
+	c.advance(500 * time.Millisecond)
+	if res := take(t, s, "k", rate); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("after a refill = %+v", res)
+	}
+	if res := take(t, s, "k", rate); res.Allowed {
+		t.Fatalf("after the refilled token = %+v", res)
+	}
+
+	// The bucket does not fill over its burst
+	c.advance(time.Minute)
+	if res := take(t, s, "k", rate); !res.Allowed || res.Remaining != 3 {
+		t.Fatalf("after a long pause = %+v", res)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	s, c := newTestStore(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	rate := Rate{Algorithm: FixedWindow, Limit: 1, Period: time.Second}
+
+	take(t, s, "a", rate)
+	take(t, s, "b", rate)
+
+	c.advance(sweepInterval)
+	take(t, s, "c", rate)
+
+	if len(s.states) != 1 {
+		t.Fatalf("states = %d, want the expired ones dropped", len(s.states))
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Rate) (Result, error) {
+	return Result{}, errors.New("store down")
+}
+
+func TestLimiterStoreFailure(t *testing.T) {
+	for _, failClosed := range []bool{false, true} {
+		l := New("test", Config{Store: failingStore{}, FailClosed: failClosed})
+		res, err := l.Allow(context.Background(), "k")
+		if err == nil || res.Allowed == failClosed {
+			t.Errorf("FailClosed %v: %+v, %v", failClosed, res, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/gianglt2198/platforms/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type limiterMetrics struct {
+	// *** counter ***
+	requestsCounter metric.Int64Counter
+	failuresCounter metric.Int64Counter
+}
+
+var (
+	metrics     *limiterMetrics
+	metricsOnce sync.Once
+)
+
+func getMetrics() *limiterMetrics {
+	metricsOnce.Do(func() {
+		metrics = newLimiterMetrics()
+	})
+	return metrics
+}
+
+func newLimiterMetrics() *limiterMetrics {
+	m := observability.Meter("ratelimit")
+	l := &limiterMetrics{}
+
+	var err error
+
+	l.requestsCounter, err = m.Int64Counter(
+		"ratelimit_requests_total",
+		metric.WithDescription("Total number of requests checked by a rate limiter."),
+		metric.WithUnit("{requests}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter rate limit requests counter failed: %v", err)
+	}
+
+	l.failuresCounter, err = m.Int64Counter(
+		"ratelimit_store_failures_total",
+		metric.WithDescription("Total number of rate limit store failures."),
+		metric.WithUnit("{failures}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter rate limit store failures counter failed: %v", err)
+	}
+
+	return l
+}
+
+func (l *limiterMetrics) allowed(ctx context.Context, name string) {
+	l.requestsCounter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("limiter.name", name),
+		attribute.String("limiter.result", "allowed"),
+	))
+}
+
+func (l *limiterMetrics) rejected(ctx context.Context, name string) {
+	l.requestsCounter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("limiter.name", name),
+		attribute.String("limiter.result", "rejected"),
+	))
+}
+
+func (l *limiterMetrics) failed(ctx context.Context, name string) {
+	l.failuresCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("limiter.name", name)))
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+type Algorithm int
+
+const (
+	// TokenBucket refills Limit tokens per Period up to Burst, it smooths the traffic and
+	// tolerates short bursts
+	TokenBucket Algorithm = iota
+	// SlidingWindow weights the count of the previous window by its overlap with the last
+	// Period, it avoids the double bursts at the edges of fixed windows
+	SlidingWindow
+	// FixedWindow counts the requests of each Period, it is the cheapest
+	FixedWindow
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case TokenBucket:
+		return "token_bucket"
+	case SlidingWindow:
+		return "sliding_window"
+	case FixedWindow:
+		return "fixed_window"
+	}
+	return "unknown"
+}
+
+type (
+	// Rate is the number of requests allowed by Period
+	Rate struct {
+		Algorithm Algorithm
+		Limit     int
+		Period    time.Duration
+		// Burst is the capacity of the token bucket, Limit when zero
+		Burst int
+	}
+
+	// Result is the decision for a request
+	Result struct {
+		Allowed   bool
+		Limit     int
+		Remaining int
+		// Reset is the time until the quota is fully available again
+		Reset time.Duration
+		// RetryAfter is the time until a rejected request may be allowed
+		RetryAfter time.Duration
+	}
+
+	// Store keeps the state of the limits, it must apply the rate to a key atomically
+	Store interface {
+		Take(ctx context.Context, key string, rate Rate) (Result, error)
+	}
+)
+
+// PerSecond, PerMinute and PerHour return a token bucket rate
+func PerSecond(limit int) Rate { return Rate{Limit: limit, Period: time.Second} }
+func PerMinute(limit int) Rate { return Rate{Limit: limit, Period: time.Minute} }
+func PerHour(limit int) Rate   { return Rate{Limit: limit, Period: time.Hour} }
+
+func (r Rate) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// Policy formats the rate for the RateLimit-Policy header, e.g. 100;w=60
+func (r Rate) Policy() string {
+	if r.Algorithm == TokenBucket && r.burst() != r.Limit {
+		return fmt.Sprintf("%d;w=%d;burst=%d", r.Limit, int(r.Period.Seconds()), r.burst())
+	}
+	return fmt.Sprintf("%d;w=%d", r.Limit, int(r.Period.Seconds()))
+}
+
+// The algorithms are computed from the state read by the stores so the memory and the
+// Redis stores decide the same
+
+func fixedWindowResult(rate Rate, count int, allowed bool, reset time.Duration) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rate.Limit,
+		Remaining: max(rate.Limit-count, 0),
+		Reset:     reset,
+	}
+	if !allowed {
+		res.RetryAfter = reset
+	}
+	return res
+}
+
+// slidingWindowResult is the decision after prev requests in the previous window and cur
+// requests in the current one, elapsed since its start. The current requests are forgotten
+// at the end of the next window
+func slidingWindowResult(rate Rate, prev, cur int, elapsed time.Duration, allowed bool) Result {
+	period := float64(rate.Period)
+	used := float64(prev)*(period-float64(elapsed))/period + float64(cur)
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rate.Limit,
+		Remaining: max(rate.Limit-int(math.Ceil(used)), 0),
+		Reset:     rate.Period - elapsed,
+	}
+	if cur > 0 {
+		res.Reset += rate.Period
+	}
+	if allowed {
+		return res
+	}
+
+	// Wait for the previous requests to weigh little enough in this window, or for the
+	// current ones to in the next window
+	free := rate.Limit - 1 - cur
+	if free > 0 && prev > 0 {
+		res.RetryAfter = time.Duration(period*(1-float64(free)/float64(prev))) - elapsed
+	} else {
+		res.RetryAfter = rate.Period - elapsed
+		if cur > rate.Limit-1 && cur > 0 {
+			res.RetryAfter += time.Duration(period * (1 - float64(rate.Limit-1)/float64(cur)))
+		}
+	}
+	res.RetryAfter = max(res.RetryAfter, time.Millisecond)
+	return res
+}
+
+// tokenBucketResult is the decision leaving tokens in the bucket
+func tokenBucketResult(rate Rate, tokens float64, allowed bool) Result {
+	perToken := float64(rate.Period) / float64(rate.Limit)
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rate.burst(),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(rate.burst()) - tokens) * perToken),
+	}
+	if !allowed {
+		res.RetryAfter = max(time.Duration((1-tokens)*perToken), time.Millisecond)
+	}
+	return res
+}
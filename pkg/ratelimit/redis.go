@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The scripts read the clock of the server so the replicas agree on the windows, and keep
+// the keys of a limit in the same hash slot for Redis Cluster
+
+var fixedWindowScript = redis.NewScript(`
+local period = tonumber(ARGV[2])
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+if count >= tonumber(ARGV[1]) then
+	return {0, count, redis.call('PTTL', KEYS[1])}
+end
+count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], period)
+end
+return {1, count, redis.call('PTTL', KEYS[1])}
+`)
+
+var slidingWindowScript = redis.NewScript(`
+local period = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = math.floor(now / period)
+local elapsed = now - window * period
+local cur_key = KEYS[1] .. ':' .. window
+local prev = tonumber(redis.call('GET', KEYS[1] .. ':' .. (window - 1)) or '0')
+local cur = tonumber(redis.call('GET', cur_key) or '0')
+if prev * (period - elapsed) / period + cur + 1 > tonumber(ARGV[1]) then
+	return {0, prev, cur, elapsed}
+end
+cur = redis.call('INCR', cur_key)
+if cur == 1 then
+	redis.call('PEXPIRE', cur_key, 2 * period)
+end
+return {1, prev, cur, elapsed}
+`)
+
+var tokenBucketScript = redis.NewScript(`
+local per_token = tonumber(ARGV[2]) / tonumber(ARGV[1])
+local burst = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + tonumber(t[2]) / 1000
+local st = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(st[1]) or burst
+local ts = tonumber(st[2]) or now
+tokens = math.min(burst, tokens + (now - ts) / per_token)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) * per_token) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps the limits in a Redis compatible server (Redis, Valkey, KeyDB or
+// Dragonfly), the replicas of a service then share them
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+var _ Store = (*RedisStore)(nil)
+
+// NewRedisStore returns a store keeping the limits under prefix, ratelimit when empty
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "ratelimit"
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, rate Rate) (Result, error) {
+	// The braces make Redis Cluster hash the key only
+	key = s.prefix + ":{" + key + "}"
+	period := rate.Period.Milliseconds()
+
+	switch rate.Algorithm {
+	case FixedWindow:
+		v, err := fixedWindowScript.Run(ctx, s.client, []string{key}, rate.Limit, period).Int64Slice()
+		if err != nil {
+			return Result{}, err
+		}
+		return fixedWindowResult(rate, int(v[1]), v[0] == 1, time.Duration(max(v[2], 0))*time.Millisecond), nil
+
+	case SlidingWindow:
+		v, err := slidingWindowScript.Run(ctx, s.client, []string{key}, rate.Limit, period).Int64Slice()
+		if err != nil {
+			return Result{}, err
+		}
+		return slidingWindowResult(rate, int(v[1]), int(v[2]), time.Duration(v[3])*time.Millisecond, v[0] == 1), nil
+	}
+
+	v, err := tokenBucketScript.Run(ctx, s.client, []string{key}, rate.Limit, period, rate.burst()).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := v[0].(int64)
+	str, _ := v[1].(string)
+	tokens, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return tokenBucketResult(rate, tokens, allowed == 1), nil
+}
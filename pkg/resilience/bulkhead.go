@@ -0,0 +1,95 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrBulkheadFull = errors.New("bulkhead is full")
+
+type (
+	// BulkheadConfig holds configuration for a bulkhead
+	BulkheadConfig struct {
+		// Name identifies the bulkhead in the metrics
+		Name string
+		// MaxConcurrent is the number of calls running at the same time
+		MaxConcurrent int
+		// MaxWait is how long a call waits for a free slot, it fails right away when zero
+		MaxWait time.Duration
+	}
+
+	// Bulkhead limits the calls running at the same time so a slow dependency
+	// cannot take all the resources of the service
+	Bulkhead struct {
+		cfg   BulkheadConfig
+		slots chan struct{}
+	}
+)
+
+// DefaultBulkheadConfig returns the default configuration
+func DefaultBulkheadConfig() BulkheadConfig {
+	return BulkheadConfig{
+		Name:          "default",
+		MaxConcurrent: 10,
+	}
+}
+
+func (m BulkheadConfig) apply(cfg *BulkheadConfig) {
+	if m.Name != "" {
+		cfg.Name = m.Name
+	}
+	if m.MaxConcurrent > 0 {
+		cfg.MaxConcurrent = m.MaxConcurrent
+	}
+	if m.MaxWait > 0 {
+		cfg.MaxWait = m.MaxWait
+	}
+}
+
+func NewBulkhead(configs ...BulkheadConfig) *Bulkhead {
+	cfg := DefaultBulkheadConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	return &Bulkhead{cfg: cfg, slots: make(chan struct{}, cfg.MaxConcurrent)}
+}
+
+func (b *Bulkhead) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := b.acquire(ctx); err != nil {
+		getMetrics().rejected(ctx, b.cfg.Name)
+		return err
+	}
+	defer func() { <-b.slots }()
+
+	return fn(ctx)
+}
+
+// InFlight returns the number of calls running
+func (b *Bulkhead) InFlight() int {
+	return len(b.slots)
+}
+
+func (b *Bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if b.cfg.MaxWait <= 0 {
+		return ErrBulkheadFull
+	}
+
+	timer := time.NewTimer(b.cfg.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
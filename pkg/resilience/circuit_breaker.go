@@ -0,0 +1,209 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+type (
+	// CircuitBreakerConfig holds configuration for a circuit breaker
+	CircuitBreakerConfig struct {
+		// Name identifies the breaker in the metrics
+		Name string
+		// FailureThreshold is the number of consecutive failures opening the circuit
+		FailureThreshold int
+		// OpenTimeout is how long the circuit stays open before probing
+		OpenTimeout time.Duration
+		// HalfOpenMaxCalls is the number of probes let through at the same time while half open
+		HalfOpenMaxCalls int
+		// SuccessThreshold is the number of successful probes closing the circuit
+		SuccessThreshold int
+		// IsFailure decides which errors count as failures, every error but a cancellation when nil
+		IsFailure func(err error) bool
+		// OnStateChange is called on each transition
+		OnStateChange func(name string, from, to State)
+	}
+
+	// CircuitBreaker stops calling a failing dependency for a while, then lets a few
+	// probes through to find out whether it recovered
+	CircuitBreaker struct {
+		cfg CircuitBreakerConfig
+
+		mu        sync.Mutex
+		state     State
+		failures  int
+		successes int
+		probes    int
+		openedAt  time.Time
+		now       func() time.Time
+	}
+)
+
+// DefaultCircuitBreakerConfig returns the default configuration
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		Name:             "default",
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenMaxCalls: 1,
+		SuccessThreshold: 1,
+		IsFailure: func(err error) bool {
+			return err != nil && !errors.Is(err, context.Canceled)
+		},
+	}
+}
+
+func (m CircuitBreakerConfig) apply(cfg *CircuitBreakerConfig) {
+	if m.Name != "" {
+		cfg.Name = m.Name
+	}
+	if m.FailureThreshold > 0 {
+		cfg.FailureThreshold = m.FailureThreshold
+	}
+	if m.OpenTimeout > 0 {
+		cfg.OpenTimeout = m.OpenTimeout
+	}
+	if m.HalfOpenMaxCalls > 0 {
+		cfg.HalfOpenMaxCalls = m.HalfOpenMaxCalls
+	}
+	if m.SuccessThreshold > 0 {
+		cfg.SuccessThreshold = m.SuccessThreshold
+	}
+	if m.IsFailure != nil {
+		cfg.IsFailure = m.IsFailure
+	}
+	if m.OnStateChange != nil {
+		cfg.OnStateChange = m.OnStateChange
+	}
+}
+
+func NewCircuitBreaker(configs ...CircuitBreakerConfig) *CircuitBreaker {
+	cfg := DefaultCircuitBreakerConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	return &CircuitBreaker{cfg: cfg, now: time.Now}
+}
+
+func (b *CircuitBreaker) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	probe, err := b.allow(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(ctx)
+	b.record(ctx, probe, err)
+	return err
+}
+
+// State returns the current state, an open circuit past its timeout reports half open
+func (b *CircuitBreaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+func (b *CircuitBreaker) allow(ctx context.Context) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return false, ErrCircuitOpen
+		}
+		b.transition(ctx, StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenMaxCalls {
+			return false, ErrCircuitOpen
+		}
+		b.probes++
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (b *CircuitBreaker) record(ctx context.Context, probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := b.cfg.IsFailure(err)
+
+	if probe {
+		b.probes--
+		// The circuit may have moved while the probe was running
+		if b.state != StateHalfOpen {
+			return
+		}
+		if failed {
+			b.transition(ctx, StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.SuccessThreshold {
+			b.transition(ctx, StateClosed)
+		}
+		return
+	}
+
+	if b.state != StateClosed {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.cfg.FailureThreshold {
+		b.transition(ctx, StateOpen)
+	}
+}
+
+func (b *CircuitBreaker) transition(ctx context.Context, to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+
+	b.state = to
+	b.failures = 0
+	b.successes = 0
+	if to == StateOpen {
+		b.openedAt = b.now()
+	}
+
+	getMetrics().stateChanged(ctx, b.cfg.Name, from, to)
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(b.cfg.Name, from, to)
+	}
+}
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	}
+	return "unknown"
+}
@@ -0,0 +1,137 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var errDependency = errors.New("dependency failed")
+
+type transition struct {
+	from, to State
+}
+
+// newTestBreaker returns a breaker on a clock moved by advance, recording its transitions
+func newTestBreaker(cfg CircuitBreakerConfig) (b *CircuitBreaker, advance func(time.Duration), transitions *[]transition) {
+	transitions = &[]transition{}
+	cfg.OnStateChange = func(_ string, from, to State) {
+		*transitions = append(*transitions, transition{from, to})
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b = NewCircuitBreaker(cfg)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }, transitions
+}
+
+func fail(context.Context) error    { return errDependency }
+func succeed(context.Context) error { return nil }
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b, _, transitions := newTestBreaker(CircuitBreakerConfig{FailureThreshold: 3})
+	ctx := context.Background()
+
+	// A success resets the count of the failures
+	_ = b.Execute(ctx, fail)
+	_ = b.Execute(ctx, fail)
+	_ = b.Execute(ctx, succeed)
+	_ = b.Execute(ctx, fail)
+	_ = b.Execute(ctx, fail)
+	if b.State() != StateClosed {
+		t.Fatalf("state = %s after non consecutive failures", b.State())
+	}
+
+	_ = b.Execute(ctx, fail)
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s after 3 consecutive failures", b.State())
+	}
+
+	called := false
+	err := b.Execute(ctx, func(context.Context) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) || called {
+		t.Errorf("open circuit = %v, called %v", err, called)
+	}
+
+	if want := []transition{{StateClosed, StateOpen}}; !reflect.DeepEqual(*transitions, want) {
+		t.Errorf("transitions = %v, want %v", *transitions, want)
+	}
+}
+
+func TestCircuitBreakerIgnoresCancellations(t *testing.T) {
+	b, _, _ := newTestBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+
+	_ = b.Execute(context.Background(), func(context.Context) error { return context.Canceled })
+	if b.State() != StateClosed {
+		t.Errorf("state = %s after a cancellation", b.State())
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b, advance, transitions := newTestBreaker(CircuitBreakerConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+		SuccessThreshold: 2,
+	})
+	ctx := context.Background()
+
+	_ = b.Execute(ctx, fail)
+	advance(time.Minute - time.Second)
+	if b.State() != StateOpen {
+		t.Fatalf("state = %s before the timeout", b.State())
+	}
+
+	advance(time.Second)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state = %s after the timeout", b.State())
+	}
+
+	// A failed probe opens the circuit for another timeout
+	if err := b.Execute(ctx, fail); !errors.Is(err, errDependency) {
+		t.Fatalf("probe = %v", err)
+	}
+	if err := b.Execute(ctx, succeed); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after a failed probe = %v", err)
+	}
+
+	// Only HalfOpenMaxCalls probes run at the same time
+	advance(time.Minute)
+	err := b.Execute(ctx, func(ctx context.Context) error {
+		if err := b.Execute(ctx, succeed); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("concurrent probe = %v", err)
+		}
+		return nil
+	})
+	if err != nil || b.State() != StateHalfOpen {
+		t.Fatalf("first successful probe = %v, state %s", err, b.State())
+	}
+
+	if err := b.Execute(ctx, succeed); err != nil || b.State() != StateClosed {
+		t.Fatalf("second successful probe = %v, state %s", err, b.State())
+	}
+
+	want := []transition{
+		{StateClosed, StateOpen},
+		{StateOpen, StateHalfOpen},
+		{StateHalfOpen, StateOpen},
+		{StateOpen, StateHalfOpen},
+		{StateHalfOpen, StateClosed},
+	}
+	if !reflect.DeepEqual(*transitions, want) {
+		t.Errorf("transitions = %v, want %v", *transitions, want)
+	}
+}
+
+func TestCircuitBreakerCountsPermanentErrors(t *testing.T) {
+	b, _, _ := newTestBreaker(CircuitBreakerConfig{FailureThreshold: 1})
+
+	_ = b.Execute(context.Background(), func(context.Context) error { return Permanent(errDependency) })
+	if b.State() != StateOpen {
+		t.Errorf("state = %s after a permanent error", b.State())
+	}
+}
@@ -0,0 +1,106 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// StatusError is the failure of a call answered with a status worth retrying
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.StatusCode)
+}
+
+// HeaderIdempotencyKey makes a request of a non-idempotent method safe to send again
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+type transport struct {
+	base   http.RoundTripper
+	policy Policy
+}
+
+// Transport runs the outbound requests of base under policy, 429 and 5xx answers count as
+// failures. Only the idempotent requests are sent again, the ones of an idempotent method or
+// carrying an Idempotency-Key, the failures of the others are permanent since the server may
+// have processed them. A request with a body is only sent again when it can be rewound with
+// GetBody. Once the policy gives up on a status the last response is returned as is
+func Transport(base http.RoundTripper, policy Policy) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, policy: policy}
+}
+
+// NewHTTPClient returns a client sending its requests under policy
+func NewHTTPClient(policy Policy) *http.Client {
+	return &http.Client{Transport: Transport(nil, policy)}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		last     *http.Response
+		attempts int
+	)
+	fail := func(err error) error {
+		if !idempotent(req) {
+			return Permanent(err)
+		}
+		return err
+	}
+
+	err := t.policy.Execute(req.Context(), func(ctx context.Context) error {
+		if last != nil {
+			_, _ = io.Copy(io.Discard, last.Body)
+			_ = last.Body.Close()
+			last = nil
+		}
+
+		r := req.Clone(ctx)
+		if attempts > 0 && req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return Permanent(errors.New("request body cannot be sent again"))
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return Permanent(err)
+			}
+			r.Body = body
+		}
+		attempts++
+
+		resp, err := t.base.RoundTrip(r)
+		if err != nil {
+			return fail(err)
+		}
+
+		last = resp
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			return fail(&StatusError{StatusCode: resp.StatusCode})
+		}
+		return nil
+	})
+
+	var statusErr *StatusError
+	if last != nil && (err == nil || errors.As(err, &statusErr)) {
+		return last, nil
+	}
+	if last != nil {
+		_ = last.Body.Close()
+	}
+	return nil, err
+}
+
+// idempotent reports whether req may be sent again
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(HeaderIdempotencyKey) != ""
+}
@@ -0,0 +1,96 @@
+package resilience
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/gianglt2198/platforms/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type policyMetrics struct {
+	// *** counter ***
+	stateChangesCounter metric.Int64Counter
+	retriesCounter      metric.Int64Counter
+	rejectionsCounter   metric.Int64Counter
+	timeoutsCounter     metric.Int64Counter
+}
+
+var (
+	metrics     *policyMetrics
+	metricsOnce sync.Once
+)
+
+func getMetrics() *policyMetrics {
+	metricsOnce.Do(func() {
+		metrics = newPolicyMetrics()
+	})
+	return metrics
+}
+
+func newPolicyMetrics() *policyMetrics {
+	m := observability.Meter("resilience")
+	p := &policyMetrics{}
+
+	var err error
+
+	p.stateChangesCounter, err = m.Int64Counter(
+		"resilience_circuit_state_changes_total",
+		metric.WithDescription("Total number of circuit breaker state changes."),
+		metric.WithUnit("{changes}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter circuit state changes counter failed: %v", err)
+	}
+
+	p.retriesCounter, err = m.Int64Counter(
+		"resilience_retries_total",
+		metric.WithDescription("Total number of retried calls."),
+		metric.WithUnit("{calls}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter retries counter failed: %v", err)
+	}
+
+	p.rejectionsCounter, err = m.Int64Counter(
+		"resilience_bulkhead_rejections_total",
+		metric.WithDescription("Total number of calls rejected by a full bulkhead."),
+		metric.WithUnit("{calls}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter bulkhead rejections counter failed: %v", err)
+	}
+
+	p.timeoutsCounter, err = m.Int64Counter(
+		"resilience_timeouts_total",
+		metric.WithDescription("Total number of calls that timed out."),
+		metric.WithUnit("{calls}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter timeouts counter failed: %v", err)
+	}
+
+	return p
+}
+
+func (p *policyMetrics) stateChanged(ctx context.Context, name string, from, to State) {
+	p.stateChangesCounter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("policy.name", name),
+		attribute.String("circuit.from", from.String()),
+		attribute.String("circuit.to", to.String()),
+	))
+}
+
+func (p *policyMetrics) retried(ctx context.Context, name string) {
+	p.retriesCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("policy.name", name)))
+}
+
+func (p *policyMetrics) rejected(ctx context.Context, name string) {
+	p.rejectionsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("policy.name", name)))
+}
+
+func (p *policyMetrics) timedOut(ctx context.Context, name string) {
+	p.timeoutsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("policy.name", name)))
+}
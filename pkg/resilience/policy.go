@@ -0,0 +1,64 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var errPermanent = errors.New("permanent error")
+
+type (
+	// Policy runs fn under a resilience strategy
+	Policy interface {
+		Execute(ctx context.Context, fn func(ctx context.Context) error) error
+	}
+
+	// PolicyFunc adapts a function to a Policy
+	PolicyFunc func(ctx context.Context, fn func(ctx context.Context) error) error
+
+	chain []Policy
+)
+
+func (f PolicyFunc) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	return f(ctx, fn)
+}
+
+// Wrap composes policies, the first one is the outermost, e.g. Wrap(retry, breaker, timeout)
+// retries calls going through the breaker, each bounded by the timeout
+func Wrap(policies ...Policy) Policy {
+	return chain(policies)
+}
+
+func (c chain) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	for i := len(c) - 1; i >= 0; i-- {
+		p, next := c[i], fn
+		fn = func(ctx context.Context) error {
+			return p.Execute(ctx, next)
+		}
+	}
+	return fn(ctx)
+}
+
+// Do runs fn under p and returns its result
+func Do[T any](ctx context.Context, p Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	var res T
+	err := p.Execute(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = fn(ctx)
+		return err
+	})
+	return res, err
+}
+
+// Permanent marks err as not worth retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", errPermanent, err)
+}
+
+func IsPermanent(err error) bool {
+	return errors.Is(err, errPermanent)
+}
@@ -0,0 +1,140 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+type (
+	// Classifier reports whether a failed call is worth retrying
+	Classifier func(err error) bool
+
+	// RetryConfig holds configuration for a retry policy
+	RetryConfig struct {
+		// Name identifies the policy in the metrics
+		Name string
+		// MaxAttempts is the number of calls including the first one
+		MaxAttempts int
+		// BaseDelay is the delay before the first retry
+		BaseDelay time.Duration
+		// MaxDelay caps the delay between two calls
+		MaxDelay time.Duration
+		// Multiplier grows the delay after each retry
+		Multiplier float64
+		// Jitter is the part of the delay randomized, between 0 and 1
+		Jitter float64
+		// Classifier decides which errors are retried, DefaultClassifier when nil
+		Classifier Classifier
+		// OnRetry is called before waiting for the next attempt
+		OnRetry func(attempt int, err error, delay time.Duration)
+	}
+
+	// Retry calls again a failing function with an exponential backoff
+	Retry struct {
+		cfg RetryConfig
+	}
+)
+
+// DefaultClassifier retries every error but the permanent ones, the cancellations and an open circuit
+func DefaultClassifier(err error) bool {
+	return err != nil &&
+		!IsPermanent(err) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, ErrCircuitOpen)
+}
+
+// DefaultRetryConfig returns the default configuration
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		Name:        "default",
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+		Classifier:  DefaultClassifier,
+	}
+}
+
+func (m RetryConfig) apply(cfg *RetryConfig) {
+	if m.Name != "" {
+		cfg.Name = m.Name
+	}
+	if m.MaxAttempts > 0 {
+		cfg.MaxAttempts = m.MaxAttempts
+	}
+	if m.BaseDelay > 0 {
+		cfg.BaseDelay = m.BaseDelay
+	}
+	if m.MaxDelay > 0 {
+		cfg.MaxDelay = m.MaxDelay
+	}
+	if m.Multiplier >= 1 {
+		cfg.Multiplier = m.Multiplier
+	}
+	if m.Jitter > 0 && m.Jitter <= 1 {
+		cfg.Jitter = m.Jitter
+	}
+	if m.Classifier != nil {
+		cfg.Classifier = m.Classifier
+	}
+	if m.OnRetry != nil {
+		cfg.OnRetry = m.OnRetry
+	}
+}
+
+func NewRetry(configs ...RetryConfig) *Retry {
+	cfg := DefaultRetryConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	return &Retry{cfg: cfg}
+}
+
+func (r *Retry) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+
+		if attempt >= r.cfg.MaxAttempts || !r.cfg.Classifier(err) || ctx.Err() != nil {
+			break
+		}
+
+		delay := r.Delay(attempt)
+		if r.cfg.OnRetry != nil {
+			r.cfg.OnRetry(attempt, err, delay)
+		}
+		getMetrics().retried(ctx, r.cfg.Name)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	if IsPermanent(err) {
+		return err
+	}
+	return fmt.Errorf("retry %s gave up: %w", r.cfg.Name, err)
+}
+
+// Delay returns the wait after the given attempt, randomized by the jitter
+func (r *Retry) Delay(attempt int) time.Duration {
+	delay := float64(r.cfg.BaseDelay) * math.Pow(r.cfg.Multiplier, float64(attempt-1))
+	if delay > float64(r.cfg.MaxDelay) {
+		delay = float64(r.cfg.MaxDelay)
+	}
+
+	spread := delay * r.cfg.Jitter
+	return time.Duration(delay - spread + rand.Float64()*2*spread)
+}
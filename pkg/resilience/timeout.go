@@ -0,0 +1,33 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrTimeout = errors.New("call timed out")
+
+// Timeout bounds each call with a deadline, the function must honour the cancellation of its context
+type Timeout struct {
+	name     string
+	duration time.Duration
+}
+
+func NewTimeout(name string, duration time.Duration) *Timeout {
+	return &Timeout{name: name, duration: duration}
+}
+
+func (t *Timeout) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	callCtx, cancel := context.WithTimeout(ctx, t.duration)
+	defer cancel()
+
+	err := fn(callCtx)
+
+	// Only our own deadline is reported as a timeout, the caller's is passed through
+	if errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		getMetrics().timedOut(ctx, t.name)
+		return errors.Join(ErrTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package mysubcriber
+
+import (
+	"context"
+	"fmt"
+)
+
+// Future is the pending result of a task submitted with Go
+type Future[R any] struct {
+	done   chan struct{}
+	result R
+	err    error
+}
+
+// Go submits fn to the pool and returns a future resolved with its result, a panic
+// of fn resolves the future with an error
+func Go[R any](ctx context.Context, pool *WorkerSubscriber, fn func(context.Context) (R, error)) (*Future[R], error) {
+	f := &Future[R]{done: make(chan struct{})}
+
+	err := pool.Submit(ctx, func() {
+		defer close(f.done)
+		defer func() {
+			if r := recover(); r != nil {
+				f.err = fmt.Errorf("task panicked: %v", r)
+				panic(r)
+			}
+		}()
+
+		f.result, f.err = fn(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Done is closed once the result is available
+func (f *Future[R]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get waits for the result until ctx is done
+func (f *Future[R]) Get(ctx context.Context) (R, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		var zero R
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,138 @@
+package mysubcriber
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gianglt2198/platforms/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type poolMetrics struct {
+	pool *WorkerSubscriber
+
+	// *** counter ***
+	tasksPanickedCounter metric.Int64Counter
+	tasksRejectedCounter metric.Int64Counter
+	activeTasksCounter   metric.Int64UpDownCounter
+
+	// *** gauge ***
+	queueDepthObservableGauge metric.Int64ObservableGauge
+	registration              metric.Registration
+
+	// *** histogram ***
+	taskWaitHistogram     metric.Int64Histogram
+	taskDurationHistogram metric.Int64Histogram
+}
+
+func newPoolMetrics(pool *WorkerSubscriber) *poolMetrics {
+	m := observability.Meter("worker-pool")
+	p := &poolMetrics{pool: pool}
+
+	var err error
+
+	p.tasksPanickedCounter, err = m.Int64Counter(
+		"worker_pool_tasks_panicked_total",
+		metric.WithDescription("Total number of tasks that panicked."),
+		metric.WithUnit("{tasks}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter worker pool panic counter failed: %v", err)
+	}
+
+	p.tasksRejectedCounter, err = m.Int64Counter(
+		"worker_pool_tasks_rejected_total",
+		metric.WithDescription("Total number of tasks not queued because the queue was full."),
+		metric.WithUnit("{tasks}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter worker pool rejected counter failed: %v", err)
+	}
+
+	p.activeTasksCounter, err = m.Int64UpDownCounter(
+		"worker_pool_active_tasks_total",
+		metric.WithDescription("Number of tasks being run."),
+		metric.WithUnit("{tasks}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter worker pool active counter failed: %v", err)
+	}
+
+	p.queueDepthObservableGauge, err = m.Int64ObservableGauge(
+		"worker_pool_queue_depth",
+		metric.WithDescription("Number of tasks waiting in the queues."),
+		metric.WithUnit("{tasks}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter worker pool queue depth failed: %v", err)
+	}
+
+	p.registration, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for priority, q := range pool.queues {
+			o.ObserveInt64(p.queueDepthObservableGauge, int64(len(q)),
+				metric.WithAttributes(
+					attribute.String("pool.name", pool.cfg.Name),
+					attribute.String("task.priority", Priority(priority).String()),
+				))
+		}
+		return nil
+	}, p.queueDepthObservableGauge)
+	if err != nil {
+		log.Fatalf("registering worker pool queue depth callback failed: %v", err)
+	}
+
+	p.taskWaitHistogram, err = m.Int64Histogram(
+		"worker_pool_task_wait_milliseconds",
+		metric.WithDescription("Time a task spent in the queue."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter worker pool task wait failed: %v", err)
+	}
+
+	p.taskDurationHistogram, err = m.Int64Histogram(
+		"worker_pool_task_duration_milliseconds",
+		metric.WithDescription("Time a task took to run."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter worker pool task duration failed: %v", err)
+	}
+
+	return p
+}
+
+func (p *poolMetrics) attributes(priority Priority) metric.MeasurementOption {
+	return metric.WithAttributes(
+		attribute.String("pool.name", p.pool.cfg.Name),
+		attribute.String("task.priority", priority.String()),
+	)
+}
+
+func (p *poolMetrics) started(t task, start time.Time) {
+	ctx := context.Background()
+	p.activeTasksCounter.Add(ctx, 1, p.attributes(t.priority))
+	p.taskWaitHistogram.Record(ctx, start.Sub(t.enqueuedAt).Milliseconds(), p.attributes(t.priority))
+}
+
+func (p *poolMetrics) finished(t task, start time.Time) {
+	ctx := context.Background()
+	p.activeTasksCounter.Add(ctx, -1, p.attributes(t.priority))
+	p.taskDurationHistogram.Record(ctx, time.Since(start).Milliseconds(), p.attributes(t.priority))
+}
+
+func (p *poolMetrics) panicked(t task) {
+	p.tasksPanickedCounter.Add(context.Background(), 1, p.attributes(t.priority))
+}
+
+func (p *poolMetrics) rejected(priority Priority) {
+	p.tasksRejectedCounter.Add(context.Background(), 1, p.attributes(priority))
+}
+
+func (p *poolMetrics) unregister() {
+	if p.registration != nil {
+		_ = p.registration.Unregister()
+	}
+}
@@ -1,41 +1,332 @@
 package mysubcriber
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"runtime"
+	"runtime/debug"
 	"sync"
+	"time"
+
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	"go.uber.org/zap"
 )
 
-type WorkerSubscriber struct {
-	numOfWorkers int
-	chanExec     chan func()
-}
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+var (
+	ErrPoolClosed = errors.New("worker pool is shut down")
+	ErrQueueFull  = errors.New("worker pool queue is full")
+)
+
+type (
+	// Config holds configuration for a worker pool
+	Config struct {
+		// Name identifies the pool in the metrics
+		Name string
+		// Workers is the number of goroutines running the tasks
+		Workers int
+		// QueueSize is the capacity of each priority queue
+		QueueSize int
+		// Logger logs the panics of the tasks when PanicHandler is nil
+		Logger oblogger.ObLogger
+		// PanicHandler is called with the recovered value when a task panics, the panics are
+		// only counted in the metrics when it and Logger are nil
+		PanicHandler func(recovered any, stack []byte)
+	}
+
+	task struct {
+		fn         func()
+		enqueuedAt time.Time
+		priority   Priority
+	}
+
+	WorkerSubscriber struct {
+		cfg     Config
+		queues  [3]chan task
+		quit    chan struct{}
+		wg      sync.WaitGroup
+		mu      sync.RWMutex
+		closed  bool
+		metrics *poolMetrics
+	}
+)
 
 var (
 	w    *WorkerSubscriber
 	once sync.Once
 )
 
-func init() {
-	once.Do(func() {
-		numOfWorkers := runtime.NumCPU()*2 + 1
-		w = &WorkerSubscriber{
-			numOfWorkers: numOfWorkers,
-			chanExec:     make(chan func(), numOfWorkers),
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	workers := runtime.NumCPU()*2 + 1
+	return Config{
+		Name:      "default",
+		Workers:   workers,
+		QueueSize: workers,
+	}
+}
+
+func (m Config) apply(cfg *Config) {
+	if m.Name != "" {
+		cfg.Name = m.Name
+	}
+	if m.Workers > 0 {
+		cfg.Workers = m.Workers
+		if m.QueueSize <= 0 {
+			cfg.QueueSize = m.Workers
+		}
+	}
+	if m.QueueSize > 0 {
+		cfg.QueueSize = m.QueueSize
+	}
+	if m.Logger != nil {
+		cfg.Logger = m.Logger
+	}
+	if m.PanicHandler != nil {
+		cfg.PanicHandler = m.PanicHandler
+	}
+}
+
+// NewWorkerSubscriber starts a pool with its own workers and queues
+func NewWorkerSubscriber(configs ...Config) *WorkerSubscriber {
+	cfg := DefaultConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	if cfg.PanicHandler == nil && cfg.Logger != nil {
+		logger, name := cfg.Logger, cfg.Name
+		cfg.PanicHandler = func(recovered any, stack []byte) {
+			logger.GetLogger().Error("Worker pool task panicked",
+				zap.String("pool", name),
+				zap.Any("recovered", recovered),
+				zap.ByteString("stack", stack),
+			)
 		}
-		w.Run()
+	}
+
+	c := &WorkerSubscriber{
+		cfg:  cfg,
+		quit: make(chan struct{}),
+	}
+	for i := range c.queues {
+		c.queues[i] = make(chan task, cfg.QueueSize)
+	}
+	c.metrics = newPoolMetrics(c)
+
+	for i := 0; i < cfg.Workers; i++ {
+		c.wg.Add(1)
+		go c.work()
+	}
+
+	return c
+}
+
+// Default returns the shared pool, it is started on first use. It has no logger, the panics
+// of its tasks are only counted in the metrics
+func Default() *WorkerSubscriber {
+	once.Do(func() {
+		w = NewWorkerSubscriber()
 	})
+	return w
+}
+
+// Submit queues fn with normal priority, it blocks while the queue is full until ctx is done
+func (c *WorkerSubscriber) Submit(ctx context.Context, fn func()) error {
+	return c.SubmitPriority(ctx, PriorityNormal, fn)
+}
+
+// SubmitWithTimeout queues fn, giving up when the queue stays full for timeout
+func (c *WorkerSubscriber) SubmitWithTimeout(fn func(), timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := c.Submit(ctx, fn); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrQueueFull
+		}
+		return err
+	}
+	return nil
+}
+
+// TrySubmit queues fn only if there is room right away
+func (c *WorkerSubscriber) TrySubmit(fn func()) bool {
+	return c.TrySubmitPriority(PriorityNormal, fn) == nil
 }
 
-func (c *WorkerSubscriber) Run() {
-	for i := 0; i < c.numOfWorkers; i++ {
-		go func() {
-			for fn := range c.chanExec {
-				fn()
+// SubmitPriority queues fn in the queue of the given priority
+func (c *WorkerSubscriber) SubmitPriority(ctx context.Context, priority Priority, fn func()) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case c.queue(priority) <- task{fn: fn, enqueuedAt: time.Now(), priority: priority}:
+		return nil
+	case <-ctx.Done():
+		c.metrics.rejected(priority)
+		return ctx.Err()
+	}
+}
+
+// TrySubmitPriority queues fn in the queue of the given priority if there is room right away
+func (c *WorkerSubscriber) TrySubmitPriority(priority Priority, fn func()) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case c.queue(priority) <- task{fn: fn, enqueuedAt: time.Now(), priority: priority}:
+		return nil
+	default:
+		c.metrics.rejected(priority)
+		return ErrQueueFull
+	}
+}
+
+// AddTask queues fn and blocks until there is room, the task is dropped once the pool is shut down
+func (c *WorkerSubscriber) AddTask(fn func()) {
+	_ = c.Submit(context.Background(), fn)
+}
+
+// QueueDepth returns the number of tasks waiting in all the queues
+func (c *WorkerSubscriber) QueueDepth() int {
+	depth := 0
+	for _, q := range c.queues {
+		depth += len(q)
+	}
+	return depth
+}
+
+// Shutdown stops accepting tasks and waits for the queued ones to run until ctx is done
+func (c *WorkerSubscriber) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.quit)
+	}
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		c.metrics.unregister()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *WorkerSubscriber) queue(priority Priority) chan task {
+	switch {
+	case priority >= PriorityHigh:
+		return c.queues[PriorityHigh]
+	case priority <= PriorityLow:
+		return c.queues[PriorityLow]
+	default:
+		return c.queues[PriorityNormal]
+	}
+}
+
+func (c *WorkerSubscriber) work() {
+	defer c.wg.Done()
+
+	high, normal, low := c.queues[PriorityHigh], c.queues[PriorityNormal], c.queues[PriorityLow]
+
+	for {
+		// Higher priorities are always looked at first
+		select {
+		case t := <-high:
+			c.run(t)
+			continue
+		default:
+		}
+
+		select {
+		case t := <-high:
+			c.run(t)
+			continue
+		case t := <-normal:
+			c.run(t)
+			continue
+		default:
+		}
+
+		select {
+		case t := <-high:
+			c.run(t)
+		case t := <-normal:
+			c.run(t)
+		case t := <-low:
+			c.run(t)
+		case <-c.quit:
+			// Drain what is left before leaving
+			if c.QueueDepth() == 0 {
+				return
 			}
-		}()
+		}
 	}
 }
 
+func (c *WorkerSubscriber) run(t task) {
+	start := time.Now()
+	c.metrics.started(t, start)
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.metrics.panicked(t)
+			if c.cfg.PanicHandler != nil {
+				c.cfg.PanicHandler(r, debug.Stack())
+			}
+		}
+		c.metrics.finished(t, start)
+	}()
+
+	t.fn()
+}
+
+// AddTask queues fn on the shared pool
 func AddTask(fn func()) {
-	w.chanExec <- fn
+	Default().AddTask(fn)
+}
+
+// Submit queues fn on the shared pool
+func Submit(ctx context.Context, fn func()) error {
+	return Default().Submit(ctx, fn)
+}
+
+// TrySubmit queues fn on the shared pool if there is room right away
+func TrySubmit(fn func()) bool {
+	return Default().TrySubmit(fn)
+}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
 }
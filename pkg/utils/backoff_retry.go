@@ -5,6 +5,8 @@ import (
 	"time"
 )
 
+// Deprecated: BackoffRetryMechanism retries every error without jitter nor context, use
+// resilience.Do with a resilience.Retry policy instead
 func BackoffRetryMechanism[T any](numOfRetry int, fn func() (T, error)) (T, error) {
 	if numOfRetry < 1 {
 		numOfRetry = 5
@@ -0,0 +1,156 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	validate     *validator.Validate
+	validateOnce sync.Once
+
+	phonePattern = regexp.MustCompile(`^\+?[1-9][0-9]{6,14}$`)
+
+	// nameTags name the fields, the first one set wins
+	nameTags = []string{"json", "path", "params", "query", "header"}
+)
+
+type (
+	// Enum is implemented by the types checked by the enum rule
+	Enum interface {
+		IsValid() bool
+	}
+
+	// RequestValidator is implemented by the requests needing checks across their fields
+	// or against the request, it runs after the validate tags with the context of the request
+	RequestValidator interface {
+		Validate(ctx context.Context) error
+	}
+)
+
+// Validator returns the validator shared by the inputs of every transport, REST, NATS,
+// gRPC and GraphQL. The fields are named after their json, path, params, query or header
+// tag, and it knows the rules:
+//   - phone: a phone number in international format, spaces, dashes and dots allowed
+//   - enum: a value whose type implements Enum
+func Validator() *validator.Validate {
+	validateOnce.Do(func() {
+		validate = validator.New(validator.WithRequiredStructEnabled())
+		validate.RegisterTagNameFunc(fieldName)
+
+		_ = validate.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+			phone := strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "").Replace(fl.Field().String())
+			return phonePattern.MatchString(phone)
+		})
+		_ = validate.RegisterValidation("enum", func(fl validator.FieldLevel) bool {
+			field := fl.Field()
+			if field.CanInterface() {
+				if e, ok := field.Interface().(Enum); ok {
+					return e.IsValid()
+				}
+			}
+			if field.CanAddr() {
+				if e, ok := field.Addr().Interface().(Enum); ok {
+					return e.IsValid()
+				}
+			}
+			return false
+		})
+	})
+	return validate
+}
+
+// RegisterRule adds a validation rule to the shared validator, it must be called at
+// startup before serving requests
+func RegisterRule(tag string, fn validator.Func) error {
+	return Validator().RegisterValidation(tag, fn)
+}
+
+// RegisterStructRule adds a check across the fields of types to the shared validator, it
+// must be called at startup before serving requests
+func RegisterStructRule(fn validator.StructLevelFunc, types ...any) {
+	Validator().RegisterStructValidation(fn, types...)
+}
+
+// Struct checks the validate tags of data
+func Struct(data any) error {
+	return Validator().Struct(data)
+}
+
+// Request checks the validate tags of data, then its RequestValidator hook
+func Request(ctx context.Context, data any) error {
+	if err := Struct(data); err != nil {
+		return err
+	}
+	if v, ok := data.(RequestValidator); ok {
+		return v.Validate(ctx)
+	}
+	return nil
+}
+
+// Validate checks input like Request when T is a struct or a pointer to one, an input like
+// an id is passed as is as the validate tags only apply to structs. A nil pointer is a
+// missing payload
+func Validate[T any](ctx context.Context, input T) error {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Pointer {
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		return Request(ctx, &input)
+	}
+
+	if t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	if reflect.ValueOf(&input).Elem().IsNil() {
+		return myerrors.PayloadInvalid("the payload is missing")
+	}
+	return Request(ctx, input)
+}
+
+// FieldPath returns the path of a failed field without the name of the root struct,
+// e.g. items[0].name
+func FieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if _, path, ok := strings.Cut(ns, "."); ok {
+		return path
+	}
+	return fe.Field()
+}
+
+// AppError converts the validation errors to a payload error listing the failed fields,
+// the other errors are converted by myerrors.From
+func AppError(err error) *myerrors.AppError {
+	var validErrs validator.ValidationErrors
+	if !errors.As(err, &validErrs) {
+		return myerrors.From(err)
+	}
+
+	aerr := myerrors.New("payload.001", "").WithCause(err)
+	for _, fe := range validErrs {
+		aerr.WithField(FieldPath(fe), fe.Tag(), "")
+	}
+	return aerr
+}
+
+// fieldName names a field after the tag of its source
+func fieldName(field reflect.StructField) string {
+	for _, tag := range nameTags {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,212 @@
+package mycore
+
+import (
+	"context"
+	"maps"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type (
+	// Runner serves the requests of a listener until it is shut down, like a fiber app or a
+	// grpc.Server
+	Runner interface {
+		// Serve blocks until the runner is shut down, it then returns nil
+		Serve(listener net.Listener) error
+		// Shutdown waits for the requests in flight until ctx is done
+		Shutdown(ctx context.Context) error
+	}
+
+	// LifecycleConfig holds configuration for Lifecycle
+	LifecycleConfig struct {
+		// Name is the name of the server in the ServerRegistry
+		Name string
+		// Protocol is the protocol served, e.g. "grpc"
+		Protocol string
+		// Version is the version of the service
+		Version string
+		// Address is the address listened on
+		Address string
+		// Logger logs the starts and the stops
+		Logger *zap.Logger
+		// Build returns the runner of a start, the servers do not serve again once shut down
+		// so each start builds one
+		Build func() Runner
+		// Metadata adds to the metadata of the health status, e.g. the services served
+		Metadata func() map[string]any
+	}
+
+	// Lifecycle implements the lifecycle, the information and the health of a Server
+	// listening on a TCP address, the protocol servers embed it with the Runner they build
+	Lifecycle struct {
+		cfg LifecycleConfig
+
+		mu        sync.Mutex
+		status    ServerStatus
+		runner    Runner
+		listener  net.Listener
+		startTime time.Time
+		handlers  []ErrorHandler
+	}
+)
+
+// NewLifecycle returns a stopped lifecycle
+func NewLifecycle(cfg LifecycleConfig) *Lifecycle {
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+	return &Lifecycle{cfg: cfg, status: ServerStatusStopped}
+}
+
+// Start listens on the address and serves the requests until Stop, it blocks like the
+// fiber servers
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	if l.runner != nil {
+		l.mu.Unlock()
+		return nil
+	}
+	l.status = ServerStatusStarting
+
+	listener, err := net.Listen("tcp", l.cfg.Address)
+	if err != nil {
+		l.status = ServerStatusFailed
+		l.mu.Unlock()
+		l.fail(err)
+		return err
+	}
+
+	runner := l.cfg.Build()
+	l.runner, l.listener = runner, listener
+	l.startTime = time.Now()
+	l.status = ServerStatusRunning
+	l.mu.Unlock()
+
+	l.cfg.Logger.Info("Server started",
+		zap.String("server", l.cfg.Name),
+		zap.String("protocol", l.cfg.Protocol),
+		zap.String("address", listener.Addr().String()),
+	)
+
+	if err := runner.Serve(listener); err != nil {
+		l.mu.Lock()
+		if l.runner == runner {
+			l.runner, l.listener = nil, nil
+			l.status = ServerStatusFailed
+		}
+		l.mu.Unlock()
+		l.fail(err)
+		return err
+	}
+	return nil
+}
+
+// Stop shuts the runner down, waiting for the requests in flight until ctx is done
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	runner := l.runner
+	if runner == nil {
+		l.mu.Unlock()
+		return nil
+	}
+	l.status = ServerStatusStopping
+	l.mu.Unlock()
+
+	err := runner.Shutdown(ctx)
+
+	l.mu.Lock()
+	if l.runner == runner {
+		l.runner, l.listener = nil, nil
+		l.status = ServerStatusStopped
+	}
+	l.mu.Unlock()
+
+	l.cfg.Logger.Info("Server stopped",
+		zap.String("server", l.cfg.Name),
+		zap.String("protocol", l.cfg.Protocol),
+	)
+	return err
+}
+
+// Restart stops the server and starts it in the background, Start blocks until the next stop
+func (l *Lifecycle) Restart(ctx context.Context) error {
+	if err := l.Stop(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		_ = l.Start(context.WithoutCancel(ctx))
+	}()
+	return nil
+}
+
+func (l *Lifecycle) Name() string {
+	return l.cfg.Name
+}
+
+func (l *Lifecycle) Status() ServerStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.status
+}
+
+func (l *Lifecycle) Protocol() string {
+	return l.cfg.Protocol
+}
+
+func (l *Lifecycle) Version() string {
+	return l.cfg.Version
+}
+
+// Addr returns the address listened on, nil when the server is stopped
+func (l *Lifecycle) Addr() net.Addr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.listener == nil {
+		return nil
+	}
+	return l.listener.Addr()
+}
+
+func (l *Lifecycle) Health() (*HealthStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status := &HealthStatus{
+		Status:   l.status,
+		Protocol: l.cfg.Protocol,
+		Version:  l.cfg.Version,
+		Metadata: map[string]any{"address": l.cfg.Address},
+	}
+	if l.cfg.Metadata != nil {
+		maps.Copy(status.Metadata, l.cfg.Metadata())
+	}
+	if l.status == ServerStatusRunning {
+		status.StartTime = l.startTime
+		status.Uptime = time.Since(l.startTime)
+		status.Metadata["address"] = l.listener.Addr().String()
+	}
+	return status, nil
+}
+
+func (l *Lifecycle) OnError(handler ErrorHandler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.handlers = append(l.handlers, handler)
+}
+
+func (l *Lifecycle) fail(err error) {
+	l.mu.Lock()
+	handlers := append([]ErrorHandler(nil), l.handlers...)
+	l.mu.Unlock()
+
+	for _, handler := range handlers {
+		_ = handler(err)
+	}
+}
@@ -13,6 +13,8 @@ const (
 	ServerStatusStopping ServerStatus = "STOPPING"
 	ServerStatusStopped  ServerStatus = "STOPPED"
 	ServerStatusFailed   ServerStatus = "FAILED"
+	// ServerStatusStandby is a server waiting to be elected leader
+	ServerStatusStandby ServerStatus = "STANDBY"
 )
 
 // HealthStatus represents the health check status
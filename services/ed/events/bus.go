@@ -0,0 +1,174 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	core "github.com/gianglt2198/platforms/services/ed"
+	"github.com/nats-io/nats.go"
+)
+
+// Publish encodes evt with the codec of its type and publishes it on the event subject,
+// tokens fill the wildcards of the subject in order
+func Publish[E Event, T any](ctx context.Context, b *core.MqBroker[T], evt E, tokens ...string) error {
+	r := DefaultRegistry()
+
+	d, err := Register[E](r)
+	if err != nil {
+		return err
+	}
+
+	subject, err := Expand(d.Subject, tokens...)
+	if err != nil {
+		return err
+	}
+
+	codec, err := r.codecFor(d.Codec)
+	if err != nil {
+		return err
+	}
+
+	data, err := codec.Encode(evt)
+	if err != nil {
+		return err
+	}
+
+	header := nats.Header{}
+	header.Set(HeaderEventName, d.Name)
+	header.Set(HeaderEventVersion, strconv.Itoa(d.Version))
+	header.Set(HeaderContentType, d.Codec)
+
+	return b.PublishMsg(ctx, &nats.Msg{
+		Subject: subject,
+		Header:  header,
+		Data:    []byte(data),
+	})
+}
+
+// On subscribes handler to the subject of E, wildcard tokens of the received subject
+// are passed in Meta.Tokens
+func On[E Event, T any](
+	ctx context.Context,
+	b *core.MqBroker[T],
+	handler Handler[E],
+	configs ...core.SubscribeConfig,
+) (*core.Subscription, error) {
+	r := DefaultRegistry()
+
+	d, err := Register[E](r)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := b.Subscribe(ctx, d.Subject, func(ctx context.Context, m *nats.Msg) error {
+		meta, err := newMeta(d.Subject, m)
+		if err != nil {
+			return err
+		}
+
+		if meta.Version > d.Version {
+			return fmt.Errorf("%w: %s v%d, handler supports v%d", ErrVersionMismatch, d.Name, meta.Version, d.Version)
+		}
+
+		contentType := m.Header.Get(HeaderContentType)
+		if contentType == "" {
+			contentType = d.Codec
+		}
+
+		codec, err := r.codecFor(contentType)
+		if err != nil {
+			return err
+		}
+
+		var evt E
+		if err := codec.Decode(string(m.Data), &evt); err != nil {
+			return err
+		}
+
+		return handler(ctx, evt, meta)
+	}, configs...)
+	if err != nil {
+		return nil, err
+	}
+
+	r.addHandler(d, handlerInfo(d.Subject, handler, configs))
+
+	return sub, nil
+}
+
+// OnAny subscribes handler to a wildcard pattern spanning several event types,
+// the payload is decoded on demand with Envelope.Decode
+func OnAny[T any](
+	ctx context.Context,
+	b *core.MqBroker[T],
+	pattern string,
+	handler func(ctx context.Context, env Envelope) error,
+	configs ...core.SubscribeConfig,
+) (*core.Subscription, error) {
+	r := DefaultRegistry()
+
+	if err := ValidatePattern(pattern); err != nil {
+		return nil, err
+	}
+
+	sub, err := b.Subscribe(ctx, pattern, func(ctx context.Context, m *nats.Msg) error {
+		meta, err := newMeta(pattern, m)
+		if err != nil {
+			return err
+		}
+
+		if meta.Name == "" {
+			if d, _, ok := r.Resolve(m.Subject); ok {
+				meta.Name = d.Name
+			}
+		}
+
+		return handler(ctx, Envelope{Meta: meta, Data: m.Data, registry: r})
+	}, configs...)
+	if err != nil {
+		return nil, err
+	}
+
+	r.addHandler(nil, handlerInfo(pattern, handler, configs))
+
+	return sub, nil
+}
+
+func newMeta(pattern string, m *nats.Msg) (Meta, error) {
+	tokens, _ := Match(pattern, m.Subject)
+
+	meta := Meta{
+		Subject:       m.Subject,
+		Pattern:       pattern,
+		Tokens:        tokens,
+		Name:          m.Header.Get(HeaderEventName),
+		CorrelationID: m.Header.Get(HeaderCorrelationID),
+		Header:        m.Header,
+	}
+
+	if v := m.Header.Get(HeaderEventVersion); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return meta, fmt.Errorf("%w: %q", ErrVersionMismatch, v)
+		}
+		meta.Version = version
+	}
+
+	return meta, nil
+}
+
+func handlerInfo(subject string, handler any, configs []core.SubscribeConfig) HandlerInfo {
+	queue := core.DefaultQueueGroup
+	for _, c := range configs {
+		if c.Queue != "" {
+			queue = c.Queue
+		}
+	}
+
+	return HandlerInfo{
+		Subject: subject,
+		Queue:   queue,
+		Func:    funcName(handler),
+	}
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	HeaderCorrelationID = "correlation-id"
+	HeaderEventName     = "event-name"
+	HeaderEventVersion  = "event-version"
+	HeaderContentType   = "content-type"
+)
+
+type (
+	// Event is implemented by the payload types published on the bus, the methods
+	// are called on the zero value so they must not depend on the content
+	Event interface {
+		// EventSubject is the subject the event is published on, "*" and ">" are filled
+		// with the tokens given to Publish
+		EventSubject() string
+		// EventVersion is the schema version of the payload
+		EventVersion() int
+	}
+
+	// CodecEvent lets an event choose a codec other than json
+	CodecEvent interface {
+		EventCodec() string
+	}
+
+	// Codec encodes the payload of an event, it is satisfied by the encoders of pkg/tools/encoder
+	Codec interface {
+		Encode(input interface{}) (string, error)
+		Decode(input string, output interface{}) error
+	}
+
+	// Meta describes the message an event was decoded from
+	Meta struct {
+		Subject       string
+		Pattern       string
+		Tokens        []string
+		Name          string
+		Version       int
+		CorrelationID string
+		Header        nats.Header
+	}
+
+	// Handler processes a decoded event
+	Handler[E Event] func(ctx context.Context, evt E, meta Meta) error
+
+	// Envelope is a message received by a wildcard handler, Decode resolves the payload
+	// with the codec of the event registered for the subject
+	Envelope struct {
+		Meta
+		Data     []byte
+		registry *Registry
+	}
+)
+
+// Decode unmarshals the payload into out using the codec of the message
+func (e Envelope) Decode(out any) error {
+	codec, err := e.registry.codecFor(e.Header.Get(HeaderContentType))
+	if err != nil {
+		return err
+	}
+
+	return codec.Decode(string(e.Data), out)
+}
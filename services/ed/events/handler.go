@@ -0,0 +1,29 @@
+package events
+
+import (
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/gofiber/fiber/v2"
+)
+
+// IntrospectionHandler exposes the registered events and handlers over REST
+type IntrospectionHandler struct {
+	registry *Registry
+}
+
+func NewIntrospectionHandler(registry *Registry) *IntrospectionHandler {
+	if registry == nil {
+		registry = DefaultRegistry()
+	}
+	return &IntrospectionHandler{registry: registry}
+}
+
+func (h *IntrospectionHandler) Register(router fiber.Router) {
+	router.Get("/events", h.list)
+}
+
+func (h *IntrospectionHandler) list(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(routes.SuccessResponse(map[string]interface{}{
+		"events":            h.registry.Events(),
+		"wildcard_handlers": h.registry.WildcardHandlers(),
+	}))
+}
@@ -0,0 +1,257 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gianglt2198/platforms/pkg/tools/encoder"
+)
+
+const (
+	CodecJSON    = "json"
+	CodecMsgpack = "msgpack"
+	CodecGzip    = "gzip"
+)
+
+var (
+	ErrUnknownCodec    = errors.New("events: unknown codec")
+	ErrDuplicateEvent  = errors.New("events: subject already registered by another event")
+	ErrVersionMismatch = errors.New("events: unsupported event version")
+)
+
+type (
+	// Descriptor is the registered definition of an event type
+	Descriptor struct {
+		Name     string        `json:"name"`
+		Subject  string        `json:"subject"`
+		Version  int           `json:"version"`
+		Codec    string        `json:"codec"`
+		Handlers []HandlerInfo `json:"handlers"`
+		typ      reflect.Type
+	}
+
+	// HandlerInfo describes a handler subscribed through the bus
+	HandlerInfo struct {
+		Subject string `json:"subject"`
+		Queue   string `json:"queue"`
+		Func    string `json:"func"`
+	}
+
+	Registry struct {
+		mu          sync.RWMutex
+		codecs      map[string]Codec
+		events      map[reflect.Type]*Descriptor
+		subjects    map[string]*Descriptor
+		anyHandlers []HandlerInfo
+	}
+)
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryOnce sync.Once
+)
+
+// DefaultRegistry returns the registry used by Publish and On
+func DefaultRegistry() *Registry {
+	defaultRegistryOnce.Do(func() {
+		defaultRegistry = NewRegistry()
+	})
+	return defaultRegistry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		codecs: map[string]Codec{
+			CodecJSON:    encoder.NewJsonEncoder(),
+			CodecMsgpack: encoder.NewMsgpackEncoder(),
+			CodecGzip:    encoder.NewGzipEncoder(),
+		},
+		events:   make(map[reflect.Type]*Descriptor),
+		subjects: make(map[string]*Descriptor),
+	}
+}
+
+// RegisterCodec adds or replaces the codec stored under name
+func (r *Registry) RegisterCodec(name string, codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.codecs[name] = codec
+}
+
+// Register records the event type E, it is called implicitly by Publish and On
+func Register[E Event](r *Registry) (*Descriptor, error) {
+	typ := reflect.TypeFor[E]()
+
+	r.mu.RLock()
+	d, ok := r.events[typ]
+	r.mu.RUnlock()
+	if ok {
+		return d, nil
+	}
+
+	var zero E
+	d = &Descriptor{
+		Name:    typeName(typ),
+		Subject: zero.EventSubject(),
+		Version: zero.EventVersion(),
+		Codec:   CodecJSON,
+		typ:     typ,
+	}
+	if c, ok := any(zero).(CodecEvent); ok && c.EventCodec() != "" {
+		d.Codec = c.EventCodec()
+	}
+
+	if err := ValidatePattern(d.Subject); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.codecs[d.Codec]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, d.Codec)
+	}
+
+	if existing, ok := r.events[typ]; ok {
+		return existing, nil
+	}
+
+	if other, ok := r.subjects[d.Subject]; ok {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrDuplicateEvent, d.Subject, other.Name)
+	}
+
+	r.events[typ] = d
+	r.subjects[d.Subject] = d
+
+	return d, nil
+}
+
+// Events lists the registered events sorted by subject
+func (r *Registry) Events() []Descriptor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Descriptor, 0, len(r.events))
+	for _, d := range r.events {
+		c := *d
+		c.Handlers = append([]HandlerInfo{}, d.Handlers...)
+		list = append(list, c)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Subject < list[j].Subject
+	})
+
+	return list
+}
+
+// WildcardHandlers lists the handlers subscribed with OnAny
+func (r *Registry) WildcardHandlers() []HandlerInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]HandlerInfo{}, r.anyHandlers...)
+}
+
+// Resolve finds the event registered for a concrete subject. When several patterns match,
+// the most specific one wins, see moreSpecific
+func (r *Registry) Resolve(subject string) (*Descriptor, []string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if d, ok := r.subjects[subject]; ok {
+		return d, nil, true
+	}
+
+	var (
+		found    *Descriptor
+		captured []string
+	)
+	for pattern, d := range r.subjects {
+		tokens, ok := Match(pattern, subject)
+		if !ok || (found != nil && !moreSpecific(pattern, found.Subject)) {
+			continue
+		}
+		found, captured = d, tokens
+	}
+
+	return found, captured, found != nil
+}
+
+// moreSpecific reports whether pattern a is preferred to b. The tokens are compared from
+// the left, a literal beats "*" which beats ">", then the longer pattern wins and the
+// patterns equally specific are ordered lexically so the result does not depend on the
+// order of the map
+func moreSpecific(a, b string) bool {
+	aTokens, bTokens := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aTokens) && i < len(bTokens); i++ {
+		if ra, rb := tokenRank(aTokens[i]), tokenRank(bTokens[i]); ra != rb {
+			return ra < rb
+		}
+	}
+	if len(aTokens) != len(bTokens) {
+		return len(aTokens) > len(bTokens)
+	}
+	return a < b
+}
+
+// tokenRank orders the tokens of a pattern from the most specific
+func tokenRank(token string) int {
+	switch token {
+	case fullWildcard:
+		return 2
+	case tokenWildcard:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (r *Registry) codecFor(name string) (Codec, error) {
+	if name == "" {
+		name = CodecJSON
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	codec, ok := r.codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+	}
+	return codec, nil
+}
+
+func (r *Registry) addHandler(d *Descriptor, info HandlerInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if d == nil {
+		r.anyHandlers = append(r.anyHandlers, info)
+		return
+	}
+	d.Handlers = append(d.Handlers, info)
+}
+
+func typeName(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.PkgPath() == "" {
+		return typ.String()
+	}
+	return typ.PkgPath() + "." + typ.Name()
+}
+
+func funcName(fn any) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	tokenWildcard = "*"
+	fullWildcard  = ">"
+)
+
+var (
+	ErrInvalidSubject = errors.New("events: invalid subject")
+	ErrSubjectTokens  = errors.New("events: wrong number of subject tokens")
+)
+
+// ValidatePattern checks a subject made of dot separated tokens where
+// "*" matches one token and a trailing ">" matches the remaining ones
+func ValidatePattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("%w: empty subject", ErrInvalidSubject)
+	}
+
+	tokens := strings.Split(pattern, ".")
+	for i, t := range tokens {
+		switch {
+		case t == "":
+			return fmt.Errorf("%w: empty token in %q", ErrInvalidSubject, pattern)
+		case t == fullWildcard && i != len(tokens)-1:
+			return fmt.Errorf("%w: %q must be the last token in %q", ErrInvalidSubject, fullWildcard, pattern)
+		case t != tokenWildcard && t != fullWildcard && strings.ContainsAny(t, "*> \t"):
+			return fmt.Errorf("%w: token %q in %q", ErrInvalidSubject, t, pattern)
+		}
+	}
+
+	return nil
+}
+
+// IsWildcard reports whether the pattern contains a wildcard token
+func IsWildcard(pattern string) bool {
+	for _, t := range strings.Split(pattern, ".") {
+		if t == tokenWildcard || t == fullWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether subject matches pattern and returns the tokens captured by
+// the wildcards, a ">" captures the remaining tokens joined by dots
+func Match(pattern, subject string) ([]string, bool) {
+	pTokens := strings.Split(pattern, ".")
+	sTokens := strings.Split(subject, ".")
+
+	var captured []string
+	for i, p := range pTokens {
+		if p == fullWildcard {
+			if i >= len(sTokens) {
+				return nil, false
+			}
+			return append(captured, strings.Join(sTokens[i:], ".")), true
+		}
+
+		if i >= len(sTokens) {
+			return nil, false
+		}
+
+		switch p {
+		case tokenWildcard:
+			captured = append(captured, sTokens[i])
+		case sTokens[i]:
+		default:
+			return nil, false
+		}
+	}
+
+	if len(pTokens) != len(sTokens) {
+		return nil, false
+	}
+
+	return captured, true
+}
+
+// Expand fills the wildcards of pattern with tokens in order
+func Expand(pattern string, tokens ...string) (string, error) {
+	pTokens := strings.Split(pattern, ".")
+
+	n := 0
+	for i, p := range pTokens {
+		if p != tokenWildcard && p != fullWildcard {
+			continue
+		}
+
+		if n >= len(tokens) {
+			return "", fmt.Errorf("%w: %q expects more than %d", ErrSubjectTokens, pattern, len(tokens))
+		}
+
+		if err := ValidatePattern(tokens[n]); err != nil || IsWildcard(tokens[n]) {
+			return "", fmt.Errorf("%w: token %q", ErrInvalidSubject, tokens[n])
+		}
+
+		pTokens[i] = tokens[n]
+		n++
+	}
+
+	if n != len(tokens) {
+		return "", fmt.Errorf("%w: %q expects %d, got %d", ErrSubjectTokens, pattern, n, len(tokens))
+	}
+
+	return strings.Join(pTokens, "."), nil
+}
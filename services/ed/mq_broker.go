@@ -2,24 +2,41 @@ package core
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
 	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	mynats "github.com/gianglt2198/platforms/pkg/nats"
+	"github.com/gianglt2198/platforms/pkg/resilience"
 	"github.com/gianglt2198/platforms/pkg/utils"
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
 type (
 	NatsConfig struct {
 		Connection string `json:"connection"`
+		// PrincipalKey signs the principals carried by the messages, the services of the
+		// cluster share it. The principals are neither sent nor accepted without it
+		PrincipalKey string `json:"principal_key"`
+		// PrincipalMaxSkew bounds the age of the signatures of the principals, 30 seconds
+		// by default
+		PrincipalMaxSkew time.Duration `json:"principal_max_skew"`
 	}
 
 	MqBroker[T any] struct {
 		natsCon *nats.Conn
 		logger  oblogger.ObLogger
+		subs    sync.Map
+
+		middlewares   []mynats.Middleware
+		requestPolicy resilience.Policy
+		signer        *mynats.PrincipalSigner
 	}
+
+	idempotentKey struct{}
 )
 
 var (
@@ -29,26 +46,73 @@ var (
 func ProvideMqBroker[T any](logger oblogger.ObLogger, config *NatsConfig) *MqBroker[T] {
 	var mqBroker *MqBroker[T]
 	mqBrokerOnce.Do(func() {
-		var err error
-		var natsCon *nats.Conn
-		for range 5 {
-			natsCon, err = nats.Connect(config.Connection)
-
-			if err == nil {
-				break
-			}
+		broker := &MqBroker[T]{logger: logger}
+		if config.PrincipalKey != "" {
+			broker.signer = mynats.NewPrincipalSigner([]byte(config.PrincipalKey), mynats.PrincipalSignerConfig{
+				MaxSkew: config.PrincipalMaxSkew,
+			})
 		}
 
+		retry := resilience.NewRetry(resilience.RetryConfig{
+			Name:        "nats-connect",
+			MaxAttempts: 5,
+			BaseDelay:   time.Second,
+		})
+
+		natsCon, err := resilience.Do(context.Background(), retry, func(context.Context) (*nats.Conn, error) {
+			return nats.Connect(config.Connection,
+				nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+					broker.handleAsyncError(sub, err)
+				}),
+			)
+		})
+
 		if err != nil {
 			panic(err)
 		}
 
-		mqBroker = &MqBroker[T]{natsCon, logger}
+		broker.natsCon = natsCon
+		mqBroker = broker
 	})
 
 	return mqBroker
 }
 
+// Use registers middlewares wrapping the handlers of the subscriptions made afterwards
+func (b *MqBroker[T]) Use(middlewares ...mynats.Middleware) {
+	b.middlewares = append(b.middlewares, middlewares...)
+}
+
+// UseRequestPolicy runs the requests of RequestOperation under policy, e.g. a retry
+// around a circuit breaker. The timeout of the request bounds each attempt. Only the requests
+// made with an Idempotent context are sent again, the failures of the others are permanent
+// since the operation may have run, except when no subscriber received the request
+func (b *MqBroker[T]) UseRequestPolicy(policy resilience.Policy) {
+	b.requestPolicy = policy
+}
+
+// Idempotent marks the requests made with ctx as safe to send again, e.g. the queries or the
+// operations deduplicating their payload, see UseRequestPolicy
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+// PrincipalSigner returns the signer of the principals, nil without PrincipalKey
+func (b *MqBroker[T]) PrincipalSigner() *mynats.PrincipalSigner {
+	return b.signer
+}
+
+// JetStream returns the JetStream context of the connection, e.g. to replay the events
+// of a stream
+func (b *MqBroker[T]) JetStream(opts ...jetstream.JetStreamOpt) (jetstream.JetStream, error) {
+	return jetstream.New(b.natsCon, opts...)
+}
+
 func (m *MqBroker[T]) CloseMQ() {
 	if m.natsCon != nil {
 		m.natsCon.Close()
@@ -88,10 +152,31 @@ func (b *MqBroker[T]) requestOperation(
 	}
 
 	// wait for 2 seconds
-	msg, err := b.natsCon.RequestMsg(&nats.Msg{
-		Subject: eventName,
-		Data:    toBytes,
-	}, timeout)
+	headers := nats.Header{}
+	mynats.InjectTrace(ctx, headers)
+	b.signer.Inject(ctx, eventName, headers)
+
+	request := func(ctx context.Context) (*nats.Msg, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		msg, err := b.natsCon.RequestMsgWithContext(ctx, &nats.Msg{
+			Subject: eventName,
+			Header:  headers,
+			Data:    toBytes,
+		})
+		if err != nil && !errors.Is(err, nats.ErrNoResponders) && !isIdempotent(ctx) {
+			return nil, resilience.Permanent(err)
+		}
+		return msg, err
+	}
+
+	var msg *nats.Msg
+	if b.requestPolicy != nil {
+		msg, err = resilience.Do(ctx, b.requestPolicy, request)
+	} else {
+		msg, err = request(ctx)
+	}
 
 	if err != nil {
 		b.logger.Error(ctx, "[MqBroker]RequestOperation", err)
@@ -101,45 +186,42 @@ func (b *MqBroker[T]) requestOperation(
 	return msg.Data, nil
 }
 
-func (b *MqBroker[T]) ListenIndexOperation(ctx context.Context, event string, opFunc func(context.Context, []byte) error) {
-	_, err := b.natsCon.QueueSubscribe(event, "query-worker", func(m *nats.Msg) {
+func (b *MqBroker[T]) ListenIndexOperation(
+	ctx context.Context,
+	event string,
+	opFunc func(context.Context, []byte) error,
+	configs ...SubscribeConfig,
+) (*Subscription, error) {
+	configs = append([]SubscribeConfig{{Queue: "query-worker"}}, configs...)
+
+	return b.subscribe(ctx, "[MqBroker]SubscribeEvent", event, func(ctx context.Context, m *nats.Msg) error {
 		correlationId := m.Header.Get("correlation-id")
 		b.logger.Info(ctx, "[MqBroker]SubscribeEvent", event, correlationId)
-		err := opFunc(ctx, m.Data)
-		if err != nil {
-			b.logger.Error(ctx, "[MqBroker]SubscribeEvent: fail to execute subcribe logic", err)
-			return
-		}
-	})
-	if err != nil {
-		b.logger.Error(ctx, "[MqBroker]SubscribeEvent: fail to subscribe event", err)
-	}
+		return opFunc(ctx, m.Data)
+	}, configs...)
 }
 
-func (b *MqBroker[T]) SubscribeOperation() func(context.Context, string, func(ctx context.Context, payload []byte) ([]byte, error)) {
-	return func(ctx context.Context, eventName string, opFunc func(ctx context.Context, payload []byte) ([]byte, error)) {
-		_, err := b.natsCon.QueueSubscribe(eventName, "gw-worker", func(m *nats.Msg) {
+func (b *MqBroker[T]) SubscribeOperation() func(context.Context, string, func(ctx context.Context, payload []byte) ([]byte, error), ...SubscribeConfig) (*Subscription, error) {
+	return func(
+		ctx context.Context,
+		eventName string,
+		opFunc func(ctx context.Context, payload []byte) ([]byte, error),
+		configs ...SubscribeConfig,
+	) (*Subscription, error) {
+		return b.subscribe(ctx, "[MqBroker]SubscribeOperation", eventName, func(ctx context.Context, m *nats.Msg) error {
 			b.logger.Info(ctx, "[MqBroker]SubscribeOperation", eventName)
 
-			ReplyFunc := func(replyPayload []byte) {
-				err := m.Respond(replyPayload)
-				if err != nil {
-					b.logger.Error(ctx, "[MqBroker]SubscribeOperation", err)
-				}
-			}
-
 			replyPayload, err := opFunc(ctx, m.Data)
-
 			if err != nil {
+				return err
+			}
+
+			if err := m.Respond(replyPayload); err != nil {
 				b.logger.Error(ctx, "[MqBroker]SubscribeOperation", err)
-			} else {
-				ReplyFunc(replyPayload)
 			}
-		})
 
-		if err != nil {
-			b.logger.Error(ctx, "[MqBroker]SubscribeOperation", err)
-		}
+			return nil
+		}, configs...)
 	}
 }
 
@@ -150,6 +232,8 @@ func (b *MqBroker[T]) PublishEvent(ctx context.Context, eventName string, payloa
 
 	headers := nats.Header{}
 	headers.Set("correlation-id", correlationId)
+	mynats.InjectTrace(ctx, headers)
+	b.signer.Inject(ctx, eventName, headers)
 
 	sendBytes, err := utils.TransformToByteArray(payload)
 	if err != nil {
@@ -168,22 +252,104 @@ func (b *MqBroker[T]) PublishEvent(ctx context.Context, eventName string, payloa
 	return nil
 }
 
-func (b *MqBroker[T]) SubscribeEvent() func(context.Context, string, func(ctx context.Context, payload []byte) error) {
-	return func(ctx context.Context, eventName string, opFunc func(ctx context.Context, payload []byte) error) {
-		_, err := b.natsCon.QueueSubscribe(eventName, "gw-worker", func(m *nats.Msg) {
+// PublishMsg publishes a prepared message, a correlation id is set when the header has none
+func (b *MqBroker[T]) PublishMsg(ctx context.Context, msg *nats.Msg) error {
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+
+	correlationId := msg.Header.Get("correlation-id")
+	if correlationId == "" {
+		correlationId = uuid.NewString()
+		msg.Header.Set("correlation-id", correlationId)
+	}
+
+	mynats.InjectTrace(ctx, msg.Header)
+	// The principal of ctx is signed for the subject, a forwarded principal is kept without
+	// one but it only verifies on the subject it was signed for
+	b.signer.Inject(ctx, msg.Subject, msg.Header)
+
+	b.logger.Info(ctx, "[MqBroker]PublishMsg: ", msg.Subject, correlationId)
+
+	if err := b.natsCon.PublishMsg(msg); err != nil {
+		b.logger.Error(ctx, "[MqBroker]PublishMsg: fail to publish message", err)
+		return err
+	}
+
+	return nil
+}
+
+func (b *MqBroker[T]) SubscribeEvent() func(context.Context, string, func(ctx context.Context, payload []byte) error, ...SubscribeConfig) (*Subscription, error) {
+	return func(
+		ctx context.Context,
+		eventName string,
+		opFunc func(ctx context.Context, payload []byte) error,
+		configs ...SubscribeConfig,
+	) (*Subscription, error) {
+		return b.subscribe(ctx, "[MqBroker]SubscribeEvent", eventName, func(ctx context.Context, m *nats.Msg) error {
 			correlationId := m.Header.Get("correlation-id")
 			b.logger.Info(ctx, "[MqBroker]SubscribeEvent", eventName, correlationId)
 
-			err := opFunc(ctx, m.Data)
+			return opFunc(ctx, m.Data)
+		}, configs...)
+	}
+}
 
-			if err != nil {
-				b.logger.Error(ctx, "[MqBroker]SubscribeEvent: fail to execute subcribe logic", err)
-				return
-			}
-		})
+// Subscribe registers a raw message handler on subject and returns a handle to stop it
+func (b *MqBroker[T]) Subscribe(
+	ctx context.Context,
+	subject string,
+	handler mynats.MsgHandler,
+	configs ...SubscribeConfig,
+) (*Subscription, error) {
+	return b.subscribe(ctx, "[MqBroker]Subscribe", subject, handler, configs...)
+}
 
-		if err != nil {
-			b.logger.Error(ctx, "[MqBroker]SubscribeEvent", err)
-		}
+func (b *MqBroker[T]) subscribe(
+	ctx context.Context,
+	name, subject string,
+	handler mynats.MsgHandler,
+	configs ...SubscribeConfig,
+) (*Subscription, error) {
+	s := newSubscription(ctx, name, subject, handler, b.logger, configs...)
+
+	// A panicking handler must not take the process down, the principal of the caller
+	// reaches the handlers and the guards once its signature is verified
+	middlewares := append([]mynats.Middleware{mynats.RecoverMiddleware(b.logger), mynats.PrincipalMiddleware(b.signer)}, b.middlewares...)
+	s.handler = mynats.Chain(middlewares...)(s.handler)
+
+	queue := s.cfg.Queue
+	if s.cfg.Broadcast {
+		queue = ""
+	}
+
+	sub, err := b.natsCon.QueueSubscribe(subject, queue, s.dispatch)
+	if err != nil {
+		b.logger.Error(ctx, name+": fail to subscribe event", err)
+		s.close()
+		return nil, err
+	}
+
+	if err := sub.SetPendingLimits(s.cfg.PendingMsgsLimit, s.cfg.PendingBytesLimit); err != nil {
+		b.logger.Error(ctx, name+": fail to set pending limits", err)
+		_ = sub.Unsubscribe()
+		s.close()
+		return nil, err
+	}
+
+	s.sub = sub
+	s.release = func() { b.subs.Delete(sub) }
+	b.subs.Store(sub, s)
+
+	return s, nil
+}
+
+func (b *MqBroker[T]) handleAsyncError(sub *nats.Subscription, err error) {
+	if sub == nil {
+		return
+	}
+
+	if s, ok := b.subs.Load(sub); ok {
+		s.(*Subscription).onAsyncError(err)
 	}
 }
@@ -0,0 +1,122 @@
+package saga
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gianglt2198/platforms/pkg/utils"
+)
+
+type Status string
+
+const (
+	StatusRunning      Status = "RUNNING"
+	StatusCompensating Status = "COMPENSATING"
+	StatusCompleted    Status = "COMPLETED"
+	StatusCompensated  Status = "COMPENSATED"
+	StatusFailed       Status = "FAILED"
+)
+
+type Phase string
+
+const (
+	PhaseExecute    Phase = "EXECUTE"
+	PhaseCompensate Phase = "COMPENSATE"
+)
+
+type LogStatus string
+
+const (
+	LogStarted   LogStatus = "STARTED"
+	LogSucceeded LogStatus = "SUCCEEDED"
+	LogFailed    LogStatus = "FAILED"
+)
+
+type (
+	// Data is the state shared by the steps of a saga, persisted as jsonb
+	Data map[string]any
+
+	// Instance is a running or finished execution of a saga definition
+	Instance struct {
+		ID          uint       `gorm:"primaryKey" json:"id"`
+		SagaID      string     `gorm:"uniqueIndex;size:36" json:"saga_id"`
+		Name        string     `gorm:"index" json:"name"`
+		Status      Status     `gorm:"index;size:16" json:"status"`
+		CurrentStep int        `json:"current_step"`
+		Data        Data       `gorm:"type:jsonb" json:"data"`
+		Error       string     `json:"error,omitempty"`
+		Owner       string     `gorm:"size:36" json:"owner"`
+		LeaseUntil  *time.Time `gorm:"index" json:"lease_until"`
+		DeadlineAt  *time.Time `json:"deadline_at"`
+		CreatedAt   time.Time  `json:"created_at"`
+		UpdatedAt   *time.Time `json:"updated_at"`
+	}
+
+	// LogEntry is one record of the execution log of an instance
+	LogEntry struct {
+		ID        uint      `gorm:"primaryKey" json:"id"`
+		SagaID    string    `gorm:"index;size:36" json:"saga_id"`
+		Step      int       `json:"step"`
+		StepName  string    `json:"step_name"`
+		Phase     Phase     `gorm:"size:16" json:"phase"`
+		Status    LogStatus `gorm:"size:16" json:"status"`
+		Attempt   int       `json:"attempt"`
+		Error     string    `json:"error,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+)
+
+func (Instance) TableName() string { return "saga_instances" }
+func (LogEntry) TableName() string { return "saga_logs" }
+
+func (i *Instance) IsFinished() bool {
+	switch i.Status {
+	case StatusCompleted, StatusCompensated, StatusFailed:
+		return true
+	}
+	return false
+}
+
+func (d Data) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+	return json.Marshal(d)
+}
+
+func (d *Data) Scan(value any) error {
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*d = Data{}
+		return nil
+	default:
+		return errors.New("saga: unsupported data type")
+	}
+	return json.Unmarshal(b, d)
+}
+
+// Set stores v under key, v must be JSON serializable to survive a resume
+func (d Data) Set(key string, v any) {
+	d[key] = v
+}
+
+// Get decodes the value stored under key into T
+func Get[T any](d Data, key string) (*T, error) {
+	v, ok := d[key]
+	if !ok {
+		return nil, errors.New("saga: missing data key " + key)
+	}
+
+	if t, ok := v.(T); ok {
+		return &t, nil
+	}
+
+	return utils.StructToStruct[T](v)
+}
@@ -0,0 +1,446 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	mydatabase "github.com/gianglt2198/platforms/database"
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownSaga   = errors.New("saga: unknown definition")
+	ErrSagaTimeout   = errors.New("saga: deadline exceeded")
+	ErrLeaseNotOwned = errors.New("saga: instance is owned by another orchestrator")
+	ErrStepBudget    = errors.New("saga: step may outlive the lease")
+)
+
+type (
+	// StepFunc runs an action or a compensation against the saga data
+	StepFunc func(ctx context.Context, data Data) error
+
+	// Step is one action of a saga and the compensation that undoes it
+	Step struct {
+		Name       string
+		Action     StepFunc
+		Compensate StepFunc
+		// Timeout bounds a single attempt of the action or the compensation, it is required
+		// and all the attempts with their delays must fit in the lease of the orchestrator
+		Timeout time.Duration
+		// Retries is the number of extra attempts after a failure
+		Retries int
+		// RetryDelay is multiplied by the attempt number between attempts
+		RetryDelay time.Duration
+	}
+
+	// Definition declares the ordered steps of a saga
+	Definition struct {
+		Name  string
+		Steps []Step
+		// Timeout bounds the whole execution, compensation starts once it is exceeded
+		Timeout time.Duration
+	}
+
+	// OrchestratorConfig holds configuration for the orchestrator
+	OrchestratorConfig struct {
+		// LeaseDuration is how long an instance stays claimed without progress
+		LeaseDuration time.Duration
+	}
+
+	Orchestrator struct {
+		cfg       OrchestratorConfig
+		id        string
+		instances *mydatabase.Repository[Instance]
+		logs      *mydatabase.Repository[LogEntry]
+		logger    oblogger.ObLogger
+
+		mu          sync.RWMutex
+		definitions map[string]Definition
+	}
+)
+
+// DefaultOrchestratorConfig returns the default configuration
+func DefaultOrchestratorConfig() OrchestratorConfig {
+	return OrchestratorConfig{
+		LeaseDuration: 30 * time.Second,
+	}
+}
+
+func (m OrchestratorConfig) apply(cfg *OrchestratorConfig) {
+	if m.LeaseDuration > 0 {
+		cfg.LeaseDuration = m.LeaseDuration
+	}
+}
+
+func NewOrchestrator(db *gorm.DB, logger oblogger.ObLogger, configs ...OrchestratorConfig) *Orchestrator {
+	cfg := DefaultOrchestratorConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return &Orchestrator{
+		cfg:         cfg,
+		id:          uuid.NewString(),
+		instances:   mydatabase.NewRepository[Instance](db),
+		logs:        mydatabase.NewRepository[LogEntry](db),
+		logger:      logger,
+		definitions: make(map[string]Definition),
+	}
+}
+
+// Migrate creates the tables used to persist saga state
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Instance{}, &LogEntry{})
+}
+
+// Register adds the definitions, none is added when a step has no timeout or may run for
+// the lease duration, another orchestrator would take its instance over while it runs
+func (o *Orchestrator) Register(definitions ...Definition) error {
+	for _, d := range definitions {
+		for _, step := range d.Steps {
+			if budget := step.budget(); step.Timeout <= 0 || budget >= o.cfg.LeaseDuration {
+				return fmt.Errorf("%w: step %s of %s may run %s, the lease is %s",
+					ErrStepBudget, step.Name, d.Name, budget, o.cfg.LeaseDuration)
+			}
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, d := range definitions {
+		o.definitions[d.Name] = d
+	}
+	return nil
+}
+
+// budget is the longest time the attempts of the step and the delays between them may run
+func (s Step) budget() time.Duration {
+	retries := time.Duration(s.Retries)
+	return s.Timeout*(retries+1) + s.RetryDelay*retries*(retries+1)/2
+}
+
+func (o *Orchestrator) definition(name string) (Definition, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	d, ok := o.definitions[name]
+	if !ok {
+		return Definition{}, fmt.Errorf("%w: %s", ErrUnknownSaga, name)
+	}
+	return d, nil
+}
+
+// Start persists a new instance of the named saga and executes it until it finishes,
+// the returned instance carries the final status
+func (o *Orchestrator) Start(ctx context.Context, name string, data Data) (*Instance, error) {
+	def, err := o.definition(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		data = Data{}
+	}
+
+	now := time.Now().UTC()
+	leaseUntil := now.Add(o.cfg.LeaseDuration)
+	instance := &Instance{
+		SagaID:     uuid.NewString(),
+		Name:       name,
+		Status:     StatusRunning,
+		Data:       data,
+		Owner:      o.id,
+		LeaseUntil: &leaseUntil,
+		CreatedAt:  now,
+	}
+	if def.Timeout > 0 {
+		deadline := now.Add(def.Timeout)
+		instance.DeadlineAt = &deadline
+	}
+
+	if _, aerr := o.instances.CreateOne(ctx, instance); aerr != nil {
+		o.logger.Error(ctx, "[Saga]Start: fail to persist instance", aerr)
+		return nil, aerr
+	}
+
+	return instance, o.run(ctx, def, instance)
+}
+
+// Resume continues the unfinished instances whose lease expired, e.g. after a crash
+func (o *Orchestrator) Resume(ctx context.Context) error {
+	instances, aerr := o.instances.FindBy(ctx, &mydatabase.FindOption{
+		Where:  "status IN ? AND (lease_until IS NULL OR lease_until < ?)",
+		Params: []interface{}{[]Status{StatusRunning, StatusCompensating}, time.Now().UTC()},
+		Order:  "id ASC",
+	})
+	if aerr != nil {
+		return aerr
+	}
+
+	var errs error
+	for i := range *instances {
+		instance := &(*instances)[i]
+
+		def, err := o.definition(instance.Name)
+		if err != nil {
+			o.logger.Warn(ctx, "[Saga]Resume: skip instance", instance.SagaID, err.Error())
+			continue
+		}
+
+		if err := o.claim(ctx, instance); err != nil {
+			if !errors.Is(err, ErrLeaseNotOwned) {
+				errs = errors.Join(errs, err)
+			}
+			continue
+		}
+
+		o.logger.Info(ctx, "[Saga]Resume", instance.Name, instance.SagaID, instance.Status, instance.CurrentStep)
+		if err := o.run(ctx, def, instance); err != nil && !errors.Is(err, ErrLeaseNotOwned) {
+			errs = errors.Join(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// Find returns the instance and its execution log
+func (o *Orchestrator) Find(ctx context.Context, sagaID string) (*Instance, *[]LogEntry, error) {
+	instance, aerr := o.instances.FindOneBy(ctx, &mydatabase.FindOption{
+		Where:  "saga_id = ?",
+		Params: []interface{}{sagaID},
+	})
+	if aerr != nil {
+		return nil, nil, aerr
+	}
+
+	logs, aerr := o.logs.FindBy(ctx, &mydatabase.FindOption{
+		Where:  "saga_id = ?",
+		Params: []interface{}{sagaID},
+		Order:  "id ASC",
+	})
+	if aerr != nil {
+		return nil, nil, aerr
+	}
+
+	return instance, logs, nil
+}
+
+func (o *Orchestrator) run(ctx context.Context, def Definition, instance *Instance) error {
+	for instance.Status == StatusRunning && instance.CurrentStep < len(def.Steps) {
+		if instance.DeadlineAt != nil && time.Now().UTC().After(*instance.DeadlineAt) {
+			if err := o.startCompensation(ctx, instance, ErrSagaTimeout); err != nil {
+				return err
+			}
+			break
+		}
+
+		step := def.Steps[instance.CurrentStep]
+		if err := o.attempt(ctx, instance, step, PhaseExecute, step.Action); err != nil {
+			if errors.Is(err, ErrLeaseNotOwned) {
+				return err
+			}
+			if err := o.startCompensation(ctx, instance, err); err != nil {
+				return err
+			}
+			break
+		}
+
+		instance.CurrentStep++
+		if err := o.save(ctx, instance, map[string]interface{}{
+			"current_step": instance.CurrentStep,
+			"data":         instance.Data,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if instance.Status == StatusRunning {
+		instance.Status = StatusCompleted
+		return o.finish(ctx, instance)
+	}
+
+	for instance.Status == StatusCompensating && instance.CurrentStep > 0 {
+		step := def.Steps[instance.CurrentStep-1]
+		if step.Compensate != nil {
+			if err := o.attempt(ctx, instance, step, PhaseCompensate, step.Compensate); err != nil {
+				if errors.Is(err, ErrLeaseNotOwned) {
+					return err
+				}
+				instance.Status = StatusFailed
+				instance.Error = errors.Join(errors.New(instance.Error), err).Error()
+				o.logger.Error(ctx, "[Saga]Compensate: manual intervention required "+instance.SagaID, err)
+				return o.finish(ctx, instance)
+			}
+		}
+
+		instance.CurrentStep--
+		if err := o.save(ctx, instance, map[string]interface{}{
+			"current_step": instance.CurrentStep,
+			"data":         instance.Data,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if instance.Status == StatusCompensating {
+		instance.Status = StatusCompensated
+		return o.finish(ctx, instance)
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) attempt(ctx context.Context, instance *Instance, step Step, phase Phase, fn StepFunc) error {
+	var err error
+	for attempt := 1; attempt <= step.Retries+1; attempt++ {
+		if attempt > 1 && step.RetryDelay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(step.RetryDelay * time.Duration(attempt-1)):
+			}
+		}
+
+		// The lease is renewed before each attempt, a slow step must not let it expire
+		if err := o.save(ctx, instance, map[string]interface{}{}); err != nil {
+			return err
+		}
+
+		o.record(ctx, instance, step, phase, LogStarted, attempt, nil)
+
+		err = o.call(ctx, step, instance.Data, fn)
+		if err == nil {
+			o.record(ctx, instance, step, phase, LogSucceeded, attempt, nil)
+			return nil
+		}
+
+		o.record(ctx, instance, step, phase, LogFailed, attempt, err)
+		o.logger.Warn(ctx, "[Saga]Step failed", instance.SagaID, step.Name, string(phase), attempt, err.Error())
+	}
+
+	return err
+}
+
+func (o *Orchestrator) call(ctx context.Context, step Step, data Data, fn StepFunc) (err error) {
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("saga: step %s panicked: %v", step.Name, r)
+		}
+	}()
+
+	return fn(ctx, data)
+}
+
+func (o *Orchestrator) startCompensation(ctx context.Context, instance *Instance, cause error) error {
+	instance.Status = StatusCompensating
+	instance.Error = cause.Error()
+
+	return o.save(ctx, instance, map[string]interface{}{
+		"status": instance.Status,
+		"error":  instance.Error,
+		"data":   instance.Data,
+	})
+}
+
+func (o *Orchestrator) finish(ctx context.Context, instance *Instance) error {
+	o.logger.Info(ctx, "[Saga]Finished", instance.Name, instance.SagaID, instance.Status)
+
+	return o.save(ctx, instance, map[string]interface{}{
+		"status":      instance.Status,
+		"error":       instance.Error,
+		"lease_until": nil,
+	})
+}
+
+// save persists the values and renews the lease of the instance, ErrLeaseNotOwned stops the
+// execution when another orchestrator owns it
+func (o *Orchestrator) save(ctx context.Context, instance *Instance, values map[string]interface{}) error {
+	if _, ok := values["lease_until"]; !ok {
+		leaseUntil := time.Now().UTC().Add(o.cfg.LeaseDuration)
+		values["lease_until"] = &leaseUntil
+		instance.LeaseUntil = &leaseUntil
+	}
+
+	// The lease of an instance taken over by another orchestrator is lost, its steps must
+	// not run twice
+	result := o.instances.QueryBuilder(ctx).
+		Where("saga_id = ? AND owner = ?", instance.SagaID, o.id).
+		Updates(values)
+	if result.Error != nil {
+		o.logger.Error(ctx, "[Saga]Save: fail to persist instance", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		o.logger.Warn(ctx, "[Saga]Save: lease lost", instance.SagaID)
+		return ErrLeaseNotOwned
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) claim(ctx context.Context, instance *Instance) error {
+	now := time.Now().UTC()
+	leaseUntil := now.Add(o.cfg.LeaseDuration)
+
+	result := o.instances.QueryBuilder(ctx).
+		Where("saga_id = ? AND (lease_until IS NULL OR lease_until < ?)", instance.SagaID, now).
+		Updates(map[string]interface{}{
+			"owner":       o.id,
+			"lease_until": &leaseUntil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLeaseNotOwned
+	}
+
+	instance.Owner = o.id
+	instance.LeaseUntil = &leaseUntil
+
+	return nil
+}
+
+func (o *Orchestrator) record(
+	ctx context.Context,
+	instance *Instance,
+	step Step,
+	phase Phase,
+	status LogStatus,
+	attempt int,
+	err error,
+) {
+	index := instance.CurrentStep
+	if phase == PhaseCompensate {
+		index--
+	}
+
+	entry := &LogEntry{
+		SagaID:    instance.SagaID,
+		Step:      index,
+		StepName:  step.Name,
+		Phase:     phase,
+		Status:    status,
+		Attempt:   attempt,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if _, aerr := o.logs.CreateOne(ctx, entry); aerr != nil {
+		o.logger.Error(ctx, "[Saga]Log: fail to persist log entry", aerr)
+	}
+}
@@ -0,0 +1,101 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+)
+
+type (
+	// Requester is the part of MqBroker used to call an operation of another service
+	Requester interface {
+		RequestOperationWithTimeout(ctx context.Context, eventName string, payload any, timeout time.Duration) ([]byte, error)
+	}
+
+	// Publisher is the part of MqBroker used to emit an event
+	Publisher interface {
+		PublishEvent(ctx context.Context, eventName string, payload interface{}) error
+	}
+)
+
+// Request builds a step function calling subject over the broker, the request is
+// built from the saga data and the reply is handed to onReply to update it
+func Request(
+	broker Requester,
+	subject string,
+	timeout time.Duration,
+	payload func(Data) (any, error),
+	onReply func(Data, []byte) error,
+) StepFunc {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	return func(ctx context.Context, data Data) error {
+		req, err := payload(data)
+		if err != nil {
+			return err
+		}
+
+		reply, err := broker.RequestOperationWithTimeout(ctx, subject, req, timeout)
+		if err != nil {
+			return err
+		}
+
+		if aerr := replyError(reply); aerr != nil {
+			return aerr
+		}
+
+		if onReply != nil {
+			return onReply(data, reply)
+		}
+		return nil
+	}
+}
+
+// Publish builds a step function emitting an event built from the saga data
+func Publish(broker Publisher, subject string, payload func(Data) (any, error)) StepFunc {
+	return func(ctx context.Context, data Data) error {
+		evt, err := payload(data)
+		if err != nil {
+			return err
+		}
+
+		return broker.PublishEvent(ctx, subject, evt)
+	}
+}
+
+// Field builds a payload function sending the value stored under key
+func Field(key string) func(Data) (any, error) {
+	return func(data Data) (any, error) {
+		return data[key], nil
+	}
+}
+
+// StoreReply builds a reply function saving the decoded reply under key
+func StoreReply(key string) func(Data, []byte) error {
+	return func(data Data, reply []byte) error {
+		var v any
+		if err := json.Unmarshal(reply, &v); err != nil {
+			return err
+		}
+
+		data.Set(key, v)
+		return nil
+	}
+}
+
+// replyError detects the AppError that mynats.UseCase sends back in place of the output
+func replyError(reply []byte) *myerrors.AppError {
+	var aerr myerrors.AppError
+	if err := json.Unmarshal(reply, &aerr); err != nil {
+		return nil
+	}
+
+	if aerr.IsZero() || aerr.Code == "" {
+		return nil
+	}
+	return &aerr
+}
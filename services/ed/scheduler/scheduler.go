@@ -0,0 +1,434 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"sync/atomic"
+	"time"
+
+	mydatabase "github.com/gianglt2198/platforms/database"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/observability"
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	"github.com/gianglt2198/platforms/pkg/utils"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusPublished Status = "PUBLISHED"
+	StatusCancelled Status = "CANCELLED"
+	StatusFailed    Status = "FAILED"
+)
+
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+type (
+	// Publisher is the part of MqBroker used to deliver the due messages
+	Publisher interface {
+		PublishEvent(ctx context.Context, eventName string, payload interface{}) error
+	}
+
+	// ScheduledMessage is a message waiting to be published at RunAt, a message with a
+	// cron spec is moved to its next occurrence after each delivery
+	ScheduledMessage struct {
+		ID          uint       `gorm:"primaryKey" json:"id"`
+		Subject     string     `json:"subject"`
+		Payload     string     `gorm:"type:jsonb" json:"payload"`
+		RunAt       time.Time  `gorm:"index" json:"run_at"`
+		Cron        string     `json:"cron,omitempty"`
+		Status      Status     `gorm:"index;size:16" json:"status"`
+		Attempts    int        `json:"attempts"`
+		LastError   string     `json:"last_error,omitempty"`
+		PublishedAt *time.Time `json:"published_at"`
+		CreatedAt   time.Time  `json:"created_at"`
+		UpdatedAt   *time.Time `json:"updated_at"`
+	}
+
+	// Config holds configuration for the scheduler
+	Config struct {
+		// ServiceName is the name used for the metrics
+		ServiceName string
+		// PollInterval is the delay between two lookups of due messages
+		PollInterval time.Duration
+		// BatchSize is the maximum number of messages published per lookup
+		BatchSize int
+		// LockKey is the advisory lock the replicas compete for
+		LockKey int64
+		// MaxAttempts is the number of failed deliveries before a message is given up
+		MaxAttempts int
+		// RetryDelay is doubled after each failed delivery
+		RetryDelay time.Duration
+		// ClaimTimeout is how long the messages of a batch are reserved for the replica
+		// publishing them, a message whose delivery was not recorded, e.g. after a crash, is
+		// published again after it
+		ClaimTimeout time.Duration
+
+		lagHistogram     metric.Int64Histogram
+		publishedCounter metric.Int64Counter
+		failedCounter    metric.Int64Counter
+		leaderGauge      metric.Int64ObservableGauge
+	}
+
+	Scheduler struct {
+		cfg       Config
+		repo      *mydatabase.Repository[ScheduledMessage]
+		tran      *mydatabase.Transaction[[]ScheduledMessage]
+		publisher Publisher
+		logger    oblogger.ObLogger
+		leader    atomic.Bool
+	}
+)
+
+func (ScheduledMessage) TableName() string { return "scheduled_messages" }
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		ServiceName:  "platform-app",
+		PollInterval: time.Second,
+		BatchSize:    100,
+		LockKey:      7_031_001,
+		MaxAttempts:  5,
+		RetryDelay:   5 * time.Second,
+		ClaimTimeout: time.Minute,
+	}
+}
+
+func (m Config) apply(cfg *Config) {
+	if m.ServiceName != "" {
+		cfg.ServiceName = m.ServiceName
+	}
+	if m.PollInterval > 0 {
+		cfg.PollInterval = m.PollInterval
+	}
+	if m.BatchSize > 0 {
+		cfg.BatchSize = m.BatchSize
+	}
+	if m.LockKey != 0 {
+		cfg.LockKey = m.LockKey
+	}
+	if m.MaxAttempts > 0 {
+		cfg.MaxAttempts = m.MaxAttempts
+	}
+	if m.RetryDelay > 0 {
+		cfg.RetryDelay = m.RetryDelay
+	}
+	if m.ClaimTimeout > 0 {
+		cfg.ClaimTimeout = m.ClaimTimeout
+	}
+}
+
+func New(db *gorm.DB, publisher Publisher, logger oblogger.ObLogger, configs ...Config) *Scheduler {
+	cfg := DefaultConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	s := &Scheduler{
+		cfg:       cfg,
+		repo:      mydatabase.NewRepository[ScheduledMessage](db),
+		tran:      mydatabase.NewTransaction[[]ScheduledMessage](db),
+		publisher: publisher,
+		logger:    logger,
+	}
+	s.newMetrics()
+
+	return s
+}
+
+// Migrate creates the table of the scheduled messages
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&ScheduledMessage{})
+}
+
+// Schedule stores payload to be published on subject at runAt
+func (s *Scheduler) Schedule(ctx context.Context, subject string, payload any, runAt time.Time) (*ScheduledMessage, error) {
+	return s.create(ctx, subject, payload, runAt.UTC(), "")
+}
+
+// ScheduleAfter stores payload to be published on subject once delay elapsed
+func (s *Scheduler) ScheduleAfter(ctx context.Context, subject string, payload any, delay time.Duration) (*ScheduledMessage, error) {
+	return s.create(ctx, subject, payload, time.Now().UTC().Add(delay), "")
+}
+
+// ScheduleCron stores payload to be published on subject at every occurrence of spec,
+// a standard five fields expression with optional seconds or a descriptor like @hourly
+func (s *Scheduler) ScheduleCron(ctx context.Context, subject string, payload any, spec string) (*ScheduledMessage, error) {
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return nil, myerrors.PayloadInvalid("invalid cron spec: " + err.Error())
+	}
+
+	return s.create(ctx, subject, payload, schedule.Next(time.Now().UTC()), spec)
+}
+
+// Cancel stops a pending message from being published
+func (s *Scheduler) Cancel(ctx context.Context, id uint) error {
+	return s.updatePending(ctx, id, map[string]interface{}{
+		"status": StatusCancelled,
+	})
+}
+
+// Reschedule moves a pending message to runAt
+func (s *Scheduler) Reschedule(ctx context.Context, id uint, runAt time.Time) error {
+	return s.updatePending(ctx, id, map[string]interface{}{
+		"run_at":   runAt.UTC(),
+		"attempts": 0,
+	})
+}
+
+// Run publishes the due messages every poll interval until ctx is done, only the
+// replica holding the advisory lock during a poll publishes
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	// The logger reads the request id of the context, ctx has none
+	logCtx := context.WithValue(ctx, "requestId", "scheduler-"+s.cfg.ServiceName)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.leader.Store(false)
+			return
+		case <-ticker.C:
+			if _, err := s.Tick(ctx); err != nil {
+				s.logger.Error(logCtx, "[Scheduler]Tick", err)
+			}
+		}
+	}
+}
+
+// Tick publishes one batch of due messages and returns how many were handled, each
+// delivery is recorded on its own so a failure does not publish the others again
+func (s *Scheduler) Tick(ctx context.Context) (int, error) {
+	due, err := s.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	handled := 0
+
+	var errs error
+	for i := range due {
+		// A delivery not recorded is retried once the claim expires
+		if aerr := s.deliver(ctx, &due[i], now); aerr != nil {
+			errs = errors.Join(errs, aerr)
+			continue
+		}
+		handled++
+	}
+
+	return handled, errs
+}
+
+// claim reserves a batch of due messages while holding the advisory lock, their run_at is
+// moved past the claim timeout so no replica takes them while they are published
+func (s *Scheduler) claim(ctx context.Context) ([]ScheduledMessage, error) {
+	due, aerr := s.tran.Execute(ctx, func(ctx context.Context) (*[]ScheduledMessage, *myerrors.AppError) {
+		var locked bool
+		if err := s.repo.QueryBuilder(ctx).
+			Raw("SELECT pg_try_advisory_xact_lock(?)", s.cfg.LockKey).
+			Scan(&locked).Error; err != nil {
+			return nil, myerrors.QueryInvalid(err.Error())
+		}
+
+		s.leader.Store(locked)
+		if !locked {
+			return &[]ScheduledMessage{}, nil
+		}
+
+		now := time.Now().UTC()
+
+		var due []ScheduledMessage
+		if err := s.repo.QueryBuilder(ctx).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ?", StatusPending, now).
+			Order("run_at ASC").
+			Limit(s.cfg.BatchSize).
+			Find(&due).Error; err != nil {
+			return nil, myerrors.QueryInvalid(err.Error())
+		}
+		if len(due) == 0 {
+			return &due, nil
+		}
+
+		ids := make([]uint, 0, len(due))
+		for _, msg := range due {
+			ids = append(ids, msg.ID)
+		}
+		if err := s.repo.QueryBuilder(ctx).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"run_at":     now.Add(s.cfg.ClaimTimeout),
+				"updated_at": now,
+			}).Error; err != nil {
+			return nil, myerrors.QueryInvalid(err.Error())
+		}
+
+		return &due, nil
+	})
+
+	if aerr != nil {
+		return nil, aerr
+	}
+	if due == nil {
+		return nil, errors.New("scheduler: claim rolled back")
+	}
+
+	return *due, nil
+}
+
+// IsLeader reports whether the last poll of this replica held the advisory lock
+func (s *Scheduler) IsLeader() bool {
+	return s.leader.Load()
+}
+
+func (s *Scheduler) deliver(ctx context.Context, msg *ScheduledMessage, now time.Time) *myerrors.AppError {
+	attrs := metric.WithAttributes(
+		attribute.String("messaging.destination", msg.Subject),
+		attribute.String("service.name", s.cfg.ServiceName),
+	)
+
+	s.cfg.lagHistogram.Record(ctx, now.Sub(msg.RunAt).Milliseconds(), attrs)
+
+	values := map[string]interface{}{}
+
+	if err := s.publisher.PublishEvent(ctx, msg.Subject, json.RawMessage(msg.Payload)); err != nil {
+		s.cfg.failedCounter.Add(ctx, 1, attrs)
+
+		attempts := msg.Attempts + 1
+		values["attempts"] = attempts
+		values["last_error"] = err.Error()
+
+		if attempts >= s.cfg.MaxAttempts {
+			values["status"] = StatusFailed
+		} else {
+			backoff := time.Duration(float64(s.cfg.RetryDelay) * math.Pow(2, float64(attempts-1)))
+			values["run_at"] = now.Add(backoff)
+		}
+	} else {
+		s.cfg.publishedCounter.Add(ctx, 1, attrs)
+
+		values["published_at"] = now
+		values["attempts"] = 0
+		values["last_error"] = ""
+
+		if msg.Cron != "" {
+			schedule, err := cronParser.Parse(msg.Cron)
+			if err != nil {
+				values["status"] = StatusFailed
+				values["last_error"] = err.Error()
+			} else {
+				values["run_at"] = schedule.Next(now)
+			}
+		} else {
+			values["status"] = StatusPublished
+		}
+	}
+
+	return s.repo.UpdateByMap(ctx, values, mydatabase.WhereOption{
+		Where:  "id = ?",
+		Params: []interface{}{msg.ID},
+	})
+}
+
+func (s *Scheduler) create(ctx context.Context, subject string, payload any, runAt time.Time, spec string) (*ScheduledMessage, error) {
+	data, err := utils.TransformToByteArray(payload)
+	if err != nil {
+		return nil, myerrors.PayloadInvalid(err.Error())
+	}
+
+	msg := &ScheduledMessage{
+		Subject:   subject,
+		Payload:   string(data),
+		RunAt:     runAt,
+		Cron:      spec,
+		Status:    StatusPending,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if _, aerr := s.repo.CreateOne(ctx, msg); aerr != nil {
+		return nil, aerr
+	}
+
+	return msg, nil
+}
+
+func (s *Scheduler) updatePending(ctx context.Context, id uint, values map[string]interface{}) error {
+	values["updated_at"] = time.Now().UTC()
+
+	result := s.repo.QueryBuilder(ctx).
+		Where("id = ? AND status = ?", id, StatusPending).
+		Updates(values)
+	if result.Error != nil {
+		return myerrors.QueryInvalid(result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return myerrors.QueryNotFound("no pending scheduled message with this id")
+	}
+
+	return nil
+}
+
+func (s *Scheduler) newMetrics() {
+	m := observability.Meter(s.cfg.ServiceName)
+
+	var err error
+
+	s.cfg.lagHistogram, err = m.Int64Histogram(
+		"scheduler_lag_milliseconds",
+		metric.WithDescription("Delay between the planned and the actual publication of a scheduled message."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter scheduler lag failed: %v", err)
+	}
+
+	s.cfg.publishedCounter, err = m.Int64Counter(
+		"scheduler_published_total",
+		metric.WithDescription("Total number of scheduled messages published."),
+		metric.WithUnit("{messages}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter scheduler published counter failed: %v", err)
+	}
+
+	s.cfg.failedCounter, err = m.Int64Counter(
+		"scheduler_failed_total",
+		metric.WithDescription("Total number of failed publications of scheduled messages."),
+		metric.WithUnit("{messages}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter scheduler failed counter failed: %v", err)
+	}
+
+	s.cfg.leaderGauge, err = m.Int64ObservableGauge(
+		"scheduler_leader",
+		metric.WithDescription("1 when this replica holds the scheduler lock."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			if s.leader.Load() {
+				o.Observe(1)
+			} else {
+				o.Observe(0)
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		log.Fatalf("creating meter scheduler leader gauge failed: %v", err)
+	}
+}
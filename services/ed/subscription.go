@@ -0,0 +1,303 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	mynats "github.com/gianglt2198/platforms/pkg/nats"
+	mysubcriber "github.com/gianglt2198/platforms/pkg/tools/subcriber"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	DefaultQueueGroup = "gw-worker"
+)
+
+type (
+	// SubscribeConfig holds the execution settings of a subscription
+	SubscribeConfig struct {
+		// Queue is the queue group the subscription joins
+		Queue string
+		// Broadcast joins no queue group, every subscription gets every message, e.g. to push
+		// the events to the clients connected to each replica
+		Broadcast bool
+		// Workers starts a dedicated pool of this size for the subscription
+		Workers int
+		// Pool runs the handlers on an existing pool, e.g. mysubcriber.Default()
+		Pool *mysubcriber.WorkerSubscriber
+		// MaxInFlight caps the messages of the subject being handled at the same time
+		MaxInFlight int
+		// PendingMsgsLimit bounds the messages buffered by the client, negative means unlimited
+		PendingMsgsLimit int
+		// PendingBytesLimit bounds the bytes buffered by the client, negative means unlimited
+		PendingBytesLimit int
+		// OnSlowConsumer is called when messages are dropped because the pending buffer is full
+		OnSlowConsumer func(subject string, dropped int)
+		// Middlewares wrap the handler after the ones registered with MqBroker.Use
+		Middlewares []mynats.Middleware
+	}
+
+	// Subscription is the handle returned by the subscribe calls of MqBroker
+	Subscription struct {
+		name     string
+		subject  string
+		ctx      context.Context
+		cfg      SubscribeConfig
+		sub      *nats.Subscription
+		handler  mynats.MsgHandler
+		logger   oblogger.ObLogger
+		inFlight chan struct{}
+		pool     *mysubcriber.WorkerSubscriber
+		ownsPool bool
+		wg       sync.WaitGroup
+		release  func()
+		// mu guards closed, no handler is added to wg once closed so done waits for all
+		mu        sync.Mutex
+		closed    bool
+		closeOnce sync.Once
+		done      chan struct{}
+		// handlerCtx is the context of the handlers, it carries the subscription so Drain
+		// knows it is called from one of them
+		handlerCtx context.Context
+	}
+
+	subscriptionKey struct{}
+)
+
+// DefaultSubscribeConfig returns the default configuration, handlers run inline in the NATS callback
+func DefaultSubscribeConfig() SubscribeConfig {
+	return SubscribeConfig{
+		Queue:             DefaultQueueGroup,
+		PendingMsgsLimit:  nats.DefaultSubPendingMsgsLimit,
+		PendingBytesLimit: nats.DefaultSubPendingBytesLimit,
+	}
+}
+
+func (m SubscribeConfig) apply(cfg *SubscribeConfig) {
+	if m.Queue != "" {
+		cfg.Queue = m.Queue
+	}
+	if m.Broadcast {
+		cfg.Broadcast = true
+	}
+	if m.Workers > 0 {
+		cfg.Workers = m.Workers
+	}
+	if m.Pool != nil {
+		cfg.Pool = m.Pool
+	}
+	if m.MaxInFlight > 0 {
+		cfg.MaxInFlight = m.MaxInFlight
+	}
+	if m.PendingMsgsLimit != 0 {
+		cfg.PendingMsgsLimit = m.PendingMsgsLimit
+	}
+	if m.PendingBytesLimit != 0 {
+		cfg.PendingBytesLimit = m.PendingBytesLimit
+	}
+	if m.OnSlowConsumer != nil {
+		cfg.OnSlowConsumer = m.OnSlowConsumer
+	}
+	if len(m.Middlewares) > 0 {
+		cfg.Middlewares = append(cfg.Middlewares, m.Middlewares...)
+	}
+}
+
+func newSubscription(
+	ctx context.Context,
+	name, subject string,
+	handler mynats.MsgHandler,
+	logger oblogger.ObLogger,
+	configs ...SubscribeConfig,
+) *Subscription {
+	cfg := DefaultSubscribeConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	s := &Subscription{
+		name:    name,
+		subject: subject,
+		ctx:     ctx,
+		cfg:     cfg,
+		handler: mynats.Chain(cfg.Middlewares...)(handler),
+		logger:  logger,
+		done:    make(chan struct{}),
+	}
+	s.handlerCtx = context.WithValue(ctx, subscriptionKey{}, s)
+
+	if cfg.MaxInFlight > 0 {
+		s.inFlight = make(chan struct{}, cfg.MaxInFlight)
+	}
+
+	switch {
+	case cfg.Workers > 0:
+		s.pool = mysubcriber.NewWorkerSubscriber(mysubcriber.Config{
+			Name:    subject,
+			Workers: cfg.Workers,
+			Logger:  logger,
+		})
+		s.ownsPool = true
+	case cfg.Pool != nil:
+		s.pool = cfg.Pool
+	}
+
+	return s
+}
+
+// Subject returns the subject the subscription listens on
+func (s *Subscription) Subject() string {
+	return s.subject
+}
+
+// Pending returns the number of messages buffered by the client and not yet dispatched
+func (s *Subscription) Pending() (int, error) {
+	msgs, _, err := s.sub.Pending()
+	return msgs, err
+}
+
+// Dropped returns the number of messages dropped because the pending limits were reached
+func (s *Subscription) Dropped() (int, error) {
+	return s.sub.Dropped()
+}
+
+// Unsubscribe stops the delivery right away, buffered messages are discarded. It returns
+// without waiting for the handlers already dispatched so a handler may call it, Done is
+// closed once they returned
+func (s *Subscription) Unsubscribe() error {
+	err := s.sub.Unsubscribe()
+	s.close()
+	return err
+}
+
+// Drain stops the delivery after the buffered messages are handled, it waits until
+// every handler returns or ctx is done. A handler calling it with its own context does not
+// wait for itself, Drain returns right away and Done is closed once the subscription drained
+func (s *Subscription) Drain(ctx context.Context) error {
+	if err := s.sub.Drain(); err != nil {
+		return err
+	}
+
+	if ctx.Value(subscriptionKey{}) == s {
+		go func() {
+			_ = s.drained(context.Background())
+			s.close()
+		}()
+		return nil
+	}
+
+	if err := s.drained(ctx); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.close():
+		return nil
+	}
+}
+
+// Done is closed once the subscription stopped and its handlers returned
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// drained waits until NATS delivered the buffered messages of a draining subscription
+func (s *Subscription) drained(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for s.sub.IsValid() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// close stops the dispatch of the messages, the handlers already dispatched are awaited in
+// the background so a handler closing its own subscription does not wait for itself
+func (s *Subscription) close() <-chan struct{} {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+
+		if s.release != nil {
+			s.release()
+		}
+
+		go func() {
+			s.wg.Wait()
+			if s.ownsPool {
+				_ = s.pool.Shutdown(context.Background())
+			}
+			close(s.done)
+		}()
+	})
+	return s.done
+}
+
+func (s *Subscription) dispatch(m *nats.Msg) {
+	// Blocking here holds the NATS callback, messages pile up in the pending buffer
+	if s.inFlight != nil {
+		s.inFlight <- struct{}{}
+	}
+
+	// A callback running while the subscription closes drops its message, close is waiting
+	// for the handlers already added
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		if s.inFlight != nil {
+			<-s.inFlight
+		}
+		return
+	}
+	s.wg.Add(1)
+	s.mu.Unlock()
+	run := func() {
+		defer s.wg.Done()
+		if s.inFlight != nil {
+			defer func() { <-s.inFlight }()
+		}
+
+		if err := s.handler(s.handlerCtx, m); err != nil {
+			s.logger.Error(s.ctx, s.name+": fail to execute subcribe logic", err)
+		}
+	}
+
+	if s.pool != nil {
+		if err := s.pool.Submit(s.ctx, run); err != nil {
+			// run never executes, undo what it would have released
+			s.wg.Done()
+			if s.inFlight != nil {
+				<-s.inFlight
+			}
+			s.logger.Error(s.ctx, s.name+": fail to dispatch message", err)
+		}
+		return
+	}
+
+	run()
+}
+
+func (s *Subscription) onAsyncError(err error) {
+	if !errors.Is(err, nats.ErrSlowConsumer) {
+		s.logger.Error(s.ctx, s.name, err)
+		return
+	}
+
+	dropped, _ := s.sub.Dropped()
+	s.logger.Warn(s.ctx, s.name+": slow consumer", s.subject, dropped)
+
+	if s.cfg.OnSlowConsumer != nil {
+		s.cfg.OnSlowConsumer(s.subject, dropped)
+	}
+}
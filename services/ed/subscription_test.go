@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	mynats "github.com/gianglt2198/platforms/pkg/nats"
+	"github.com/nats-io/nats.go"
+)
+
+// newTestSubscription returns a subscription whose handler unsubscribes it, the NATS
+// subscription is not connected so only the dispatch runs
+func newTestSubscription(t *testing.T, configs ...SubscribeConfig) (s *Subscription, handled chan struct{}) {
+	t.Helper()
+
+	handled = make(chan struct{})
+	ctx := context.WithValue(context.Background(), "requestId", "test")
+	s = newSubscription(ctx, "test", "orders.created", func(ctx context.Context, _ *nats.Msg) error {
+		_ = s.Unsubscribe()
+		close(handled)
+		return nil
+	}, oblogger.NewLogger(false), configs...)
+	s.sub = &nats.Subscription{}
+	return s, handled
+}
+
+// waitClosed fails the test when the subscription or its handler never finish
+func waitClosed(t *testing.T, s *Subscription, handled chan struct{}) {
+	t.Helper()
+
+	for _, ch := range []<-chan struct{}{handled, s.Done()} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("a handler unsubscribing its own subscription deadlocked")
+		}
+	}
+}
+
+func TestUnsubscribeFromAnInlineHandler(t *testing.T) {
+	s, handled := newTestSubscription(t)
+
+	dispatched := make(chan struct{})
+	go func() {
+		s.dispatch(&nats.Msg{Subject: s.subject})
+		close(dispatched)
+	}()
+	waitClosed(t, s, handled)
+	<-dispatched
+
+	// The messages still delivered by NATS are dropped
+	called := false
+	s.handler = func(context.Context, *nats.Msg) error {
+		called = true
+		return nil
+	}
+	s.dispatch(&nats.Msg{Subject: s.subject})
+	if called {
+		t.Error("a message was handled after Unsubscribe")
+	}
+}
+
+func TestUnsubscribeFromAPoolHandler(t *testing.T) {
+	s, handled := newTestSubscription(t, SubscribeConfig{Workers: 2, MaxInFlight: 1})
+
+	s.dispatch(&nats.Msg{Subject: s.subject})
+	waitClosed(t, s, handled)
+
+	if len(s.inFlight) != 0 {
+		t.Errorf("in flight = %d after the handler returned", len(s.inFlight))
+	}
+}
+
+func TestHandlerContextCarriesTheSubscription(t *testing.T) {
+	var got any
+	s := newSubscription(context.Background(), "test", "orders.created", func(ctx context.Context, _ *nats.Msg) error {
+		got = ctx.Value(subscriptionKey{})
+		return nil
+	}, oblogger.NewLogger(false), SubscribeConfig{Middlewares: []mynats.Middleware{mynats.TimeoutMiddleware(time.Second)}})
+
+	s.dispatch(&nats.Msg{Subject: s.subject})
+	if got != s {
+		t.Errorf("handler context subscription = %v, want the subscription", got)
+	}
+}
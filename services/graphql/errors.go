@@ -0,0 +1,134 @@
+package mygraphql
+
+import (
+	"errors"
+	"net/http"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/problem"
+	"github.com/go-playground/validator/v10"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/location"
+	"go.uber.org/zap"
+)
+
+const (
+	codeInvalid = "graphql.001"
+
+	// messagePersistedNotFound is the message the Apollo clients expect to send the query
+	// along its hash
+	messagePersistedNotFound = "PersistedQueryNotFound"
+)
+
+// Error is an error of a GraphQL response, the extensions carry the code, the status and
+// the category of the AppError and its field errors like the problems of the REST errors
+type Error struct {
+	Message    string                    `json:"message"`
+	Locations  []location.SourceLocation `json:"locations,omitempty"`
+	Path       []any                     `json:"path,omitempty"`
+	Extensions map[string]any            `json:"extensions,omitempty"`
+}
+
+// requestError returns err as an error of the execution, before it started
+func requestError(err *myerrors.AppError) gqlerrors.FormattedError {
+	return gqlerrors.FormatError(&gqlerrors.Error{Message: err.Error(), OriginalError: err})
+}
+
+// errors converts the errors of a result in locale. The syntax and validation errors keep
+// their message under graphql.001, the errors of the resolvers are converted like the REST
+// errors, the detail of the server errors hidden in production
+func (h *Handler) errors(errs []gqlerrors.FormattedError, locale, requestID string) []*Error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	problemCfg := problem.Config{
+		IsProdEnv: h.cfg.IsProdEnv,
+		Catalog:   h.cfg.Catalog,
+	}
+
+	out := make([]*Error, 0, len(errs))
+	for _, fe := range errs {
+		e := &Error{
+			Message:   fe.Message,
+			Locations: fe.Locations,
+			Path:      fe.Path,
+		}
+
+		err := cause(fe)
+		if err == nil {
+			e.Extensions = map[string]any{
+				"code":     codeInvalid,
+				"status":   http.StatusBadRequest,
+				"category": myerrors.CategoryValidation,
+			}
+		} else {
+			e.Extensions = h.extensions(e, err, locale, problemCfg)
+		}
+		if requestID != "" {
+			e.Extensions["request_id"] = requestID
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func (h *Handler) extensions(e *Error, err error, locale string, cfg problem.Config) map[string]any {
+	// The validation errors are the payload.001 of the problems, any other error is
+	// converted like the AppErrors of the replies, e.g. the Postgres errors
+	var validErrs validator.ValidationErrors
+	if !errors.As(err, &validErrs) {
+		err = myerrors.From(err)
+	}
+
+	p := problem.New(err, locale, cfg)
+	if p.Status >= http.StatusInternalServerError {
+		h.logger.GetLogger().Error("GraphQL field failed",
+			zap.Any("path", e.Path),
+			zap.Error(err),
+		)
+	}
+
+	category := myerrors.CategoryOf(p.Status)
+	var appErr *myerrors.AppError
+	if errors.As(err, &appErr) && appErr.Category != "" {
+		category = appErr.Category
+	}
+
+	e.Message = p.Detail
+	if e.Message == "" {
+		e.Message = p.Title
+	}
+	if p.Code == codePersistedNotFound {
+		e.Message = messagePersistedNotFound
+	}
+
+	extensions := map[string]any{
+		"code":     p.Code,
+		"status":   p.Status,
+		"category": category,
+	}
+	if len(p.Errors) > 0 {
+		extensions["fields"] = p.Errors
+	}
+	if len(p.Metadata) > 0 {
+		extensions["metadata"] = p.Metadata
+	}
+	return extensions
+}
+
+// cause returns the error returned by a resolver, nil for the syntax and validation errors
+func cause(fe gqlerrors.FormattedError) error {
+	err := fe.OriginalError()
+	for err != nil {
+		switch e := err.(type) {
+		case *gqlerrors.Error:
+			err = e.OriginalError
+		case gqlerrors.FormattedError:
+			err = e.OriginalError()
+		default:
+			return err
+		}
+	}
+	return nil
+}
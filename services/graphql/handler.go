@@ -0,0 +1,354 @@
+package mygraphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gianglt2198/platforms/cache"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/observability"
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	"github.com/gianglt2198/platforms/services/rest/app"
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const MIMEApplicationGraphQL = "application/graphql"
+
+type (
+	// HandlerConfig holds configuration for Handler
+	HandlerConfig struct {
+		// Path is the path of the endpoint, "/graphql" by default
+		Path string
+		// ServiceName labels the traces
+		ServiceName string
+		// ServiceVersion labels the traces
+		ServiceVersion string
+		// IsProdEnv hides the detail of the server errors
+		IsProdEnv bool
+		// Catalog localizes the errors in the locale of the Accept-Language of the request,
+		// myerrors.DefaultCatalog by default
+		Catalog *myerrors.Catalog
+		// MaxQueryLength is the maximum length in bytes of a query, checked before it is
+		// parsed, 16 KiB by default
+		MaxQueryLength int
+		// MaxDepth is the maximum nesting of the fields of a query, 10 by default
+		MaxDepth int
+		// MaxComplexity is the maximum cost of a query, each field costs 1 plus its
+		// selection unless its FieldConfig has a Complexity, 500 by default
+		MaxComplexity int
+		// PersistedQueries are the queries known in advance by their SHA-256, e.g. the
+		// manifest generated with the clients
+		PersistedQueries map[string]string
+		// PersistedCache stores the queries registered by the clients with the automatic
+		// persisted queries, only the PersistedQueries are known when nil
+		PersistedCache cache.Cache
+		// PersistedTTL is the time a registered query is kept, 24 hours by default
+		PersistedTTL time.Duration
+		// PersistedOnly rejects the queries that are not in PersistedQueries
+		PersistedOnly bool
+	}
+
+	// Handler serves a Schema over HTTP, the queries are accepted with GET and POST, the
+	// mutations with POST only. It is registered on the fiber App like the REST handlers or
+	// served by a Server of its own
+	Handler struct {
+		cfg    HandlerConfig
+		schema *Schema
+		built  graphql.Schema
+		logger oblogger.ObLogger
+	}
+
+	// Response is a GraphQL response, the data is absent when the execution did not start
+	Response struct {
+		Data   json.RawMessage `json:"data,omitempty"`
+		Errors []*Error        `json:"errors,omitempty"`
+	}
+)
+
+var _ app.Handler = (*Handler)(nil)
+
+// DefaultHandlerConfig returns the default configuration
+func DefaultHandlerConfig() HandlerConfig {
+	return HandlerConfig{
+		Path:           "/graphql",
+		ServiceName:    "platform-app",
+		ServiceVersion: "1.0.0",
+		Catalog:        myerrors.DefaultCatalog(),
+		MaxQueryLength: 16 << 10,
+		MaxDepth:       10,
+		MaxComplexity:  500,
+		PersistedTTL:   24 * time.Hour,
+	}
+}
+
+func (m HandlerConfig) apply(cfg *HandlerConfig) {
+	if m.Path != "" {
+		cfg.Path = m.Path
+	}
+	if m.ServiceName != "" {
+		cfg.ServiceName = m.ServiceName
+	}
+	if m.ServiceVersion != "" {
+		cfg.ServiceVersion = m.ServiceVersion
+	}
+	if m.IsProdEnv {
+		cfg.IsProdEnv = true
+	}
+	if m.Catalog != nil {
+		cfg.Catalog = m.Catalog
+	}
+	if m.MaxQueryLength > 0 {
+		cfg.MaxQueryLength = m.MaxQueryLength
+	}
+	if m.MaxDepth > 0 {
+		cfg.MaxDepth = m.MaxDepth
+	}
+	if m.MaxComplexity > 0 {
+		cfg.MaxComplexity = m.MaxComplexity
+	}
+	if m.PersistedQueries != nil {
+		cfg.PersistedQueries = m.PersistedQueries
+	}
+	if m.PersistedCache != nil {
+		cfg.PersistedCache = m.PersistedCache
+	}
+	if m.PersistedTTL > 0 {
+		cfg.PersistedTTL = m.PersistedTTL
+	}
+	if m.PersistedOnly {
+		cfg.PersistedOnly = true
+	}
+}
+
+// NewHandler builds schema, the types and the fields must be registered before
+func NewHandler(schema *Schema, logger oblogger.ObLogger, configs ...HandlerConfig) (*Handler, error) {
+	cfg := DefaultHandlerConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	built, err := schema.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		cfg:    cfg,
+		schema: schema,
+		built:  built,
+		logger: logger,
+	}, nil
+}
+
+func (h *Handler) Register(router fiber.Router) {
+	router.Get(h.cfg.Path, h.Handle)
+	router.Post(h.cfg.Path, h.Handle)
+}
+
+// Handle executes the request of c, the errors are answered in the errors of the response
+// with a 200 like the other GraphQL servers, only an unreadable request reaches the error
+// handler of fiber
+func (h *Handler) Handle(c *fiber.Ctx) error {
+	req, err := parseRequest(c)
+	if err != nil {
+		return myerrors.PayloadInvalid(err.Error()).WithCause(err)
+	}
+
+	locale := h.cfg.Catalog.MatchLocale(c.Get(fiber.HeaderAcceptLanguage))
+	requestID, _ := c.Locals(routes.KEY_REQUEST_ID).(string)
+
+	// The use cases get the context of the request like the REST ones, with the span of
+	// the tracing middleware as parent
+	ctx := context.Context(c.Context())
+	if spanCtx, ok := c.Locals("spanCtx").(context.Context); ok {
+		ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(spanCtx))
+	}
+	ctx, span := observability.Tracer("graphql").Start(WithLoaders(ctx), "graphql",
+		trace.WithAttributes(
+			attribute.String("graphql.request_id", requestID),
+			attribute.String("service.name", h.cfg.ServiceName),
+			attribute.String("service.version", h.cfg.ServiceVersion),
+		),
+	)
+	defer span.End()
+
+	result, executed := h.execute(ctx, c.Method(), req, span)
+
+	res := Response{Errors: h.errors(result.Errors, locale, requestID)}
+	if executed {
+		if res.Data, err = json.Marshal(result.Data); err != nil {
+			return err
+		}
+	}
+
+	if len(res.Errors) > 0 {
+		span.SetStatus(codes.Error, res.Errors[0].Message)
+	} else {
+		span.SetStatus(codes.Ok, "query executed")
+	}
+
+	c.Set(fiber.HeaderContentLanguage, locale)
+	return c.JSON(res)
+}
+
+// execute runs req and reports whether the execution started, the errors of the query, its
+// limits and the persisted queries stop it before. The limits are checked before the
+// validation, whose rules are costly on the documents they reject
+func (h *Handler) execute(ctx context.Context, method string, req *Request, span trace.Span) (*graphql.Result, bool) {
+	fail := func(err gqlerrors.FormattedError) (*graphql.Result, bool) {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{err}}, false
+	}
+	tooLong := func(length int) (*graphql.Result, bool) {
+		return fail(requestError(myerrors.New("graphql.007", "").
+			WithMetadata("length", length).
+			WithMetadata("max_length", h.cfg.MaxQueryLength)))
+	}
+
+	if len(req.Query) > h.cfg.MaxQueryLength {
+		return tooLong(len(req.Query))
+	}
+	query, aerr := h.persistedQuery(ctx, req)
+	if aerr != nil {
+		return fail(requestError(aerr))
+	}
+	if query == "" {
+		return fail(invalidError("Must provide a query"))
+	}
+	if len(query) > h.cfg.MaxQueryLength {
+		return tooLong(len(query))
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return fail(gqlerrors.FormatError(err))
+	}
+
+	op, message := operation(doc, req.OperationName)
+	if op == nil {
+		return fail(invalidError(message))
+	}
+	name := ""
+	if op.Name != nil {
+		name = op.Name.Value
+	}
+	span.SetName(strings.TrimSpace("graphql " + op.Operation + " " + name))
+	span.SetAttributes(
+		attribute.String("graphql.operation.type", op.Operation),
+		attribute.String("graphql.operation.name", name),
+	)
+
+	switch {
+	case op.Operation == ast.OperationTypeSubscription:
+		return fail(invalidError("Subscriptions are not supported"))
+	case op.Operation == ast.OperationTypeMutation && method != fiber.MethodPost:
+		return fail(requestError(myerrors.NewAppError(codeInvalid, "mutations are only accepted with POST", http.StatusMethodNotAllowed)))
+	}
+
+	depth, complexity := h.measure(doc, op, req.Variables)
+	span.SetAttributes(
+		attribute.Int("graphql.depth", depth),
+		attribute.Int("graphql.complexity", complexity),
+	)
+	if depth > h.cfg.MaxDepth {
+		return fail(requestError(myerrors.New("graphql.002", "").
+			WithMetadata("depth", depth).
+			WithMetadata("max_depth", h.cfg.MaxDepth)))
+	}
+	if complexity > h.cfg.MaxComplexity {
+		return fail(requestError(myerrors.New("graphql.003", "").
+			WithMetadata("complexity", complexity).
+			WithMetadata("max_complexity", h.cfg.MaxComplexity)))
+	}
+
+	if validation := graphql.ValidateDocument(&h.built, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}, false
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.built,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	}), true
+}
+
+// operation returns the operation of doc named name, the only one when name is empty
+func operation(doc *ast.Document, name string) (*ast.OperationDefinition, string) {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if found != nil {
+				return nil, "Must provide operation name if query contains multiple operations"
+			}
+			found = op
+			continue
+		}
+		if op.Name != nil && op.Name.Value == name {
+			return op, ""
+		}
+	}
+
+	if found == nil {
+		if name != "" {
+			return nil, fmt.Sprintf(`Unknown operation named "%s"`, name)
+		}
+		return nil, "Must provide an operation"
+	}
+	return found, ""
+}
+
+// invalidError returns an error of the query, reported under graphql.001 with message
+func invalidError(message string) gqlerrors.FormattedError {
+	return gqlerrors.FormatError(&gqlerrors.Error{Message: message})
+}
+
+// parseRequest reads the request from the parameters of a GET, the JSON body of a POST or
+// its body when it is an application/graphql query
+func parseRequest(c *fiber.Ctx) (*Request, error) {
+	req := &Request{}
+
+	if c.Method() == fiber.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return nil, fmt.Errorf("variables: %w", err)
+			}
+		}
+		if extensions := c.Query("extensions"); extensions != "" {
+			if err := json.Unmarshal([]byte(extensions), &req.Extensions); err != nil {
+				return nil, fmt.Errorf("extensions: %w", err)
+			}
+		}
+		return req, nil
+	}
+
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), MIMEApplicationGraphQL) {
+		req.Query = string(c.Body())
+		return req, nil
+	}
+	if err := json.Unmarshal(c.Body(), req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
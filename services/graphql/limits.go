@@ -0,0 +1,154 @@
+package mygraphql
+
+import (
+	"math"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// measure returns the depth and the complexity of op, the fragments expanded. It runs
+// before the validation, on any document: the unknown fields cost 1 and the sums saturate.
+// The introspection fields are free so the tools can load the schema
+func (h *Handler) measure(doc *ast.Document, op *ast.OperationDefinition, vars map[string]any) (int, int) {
+	m := &measurer{
+		complexity: h.schema.complexity,
+		fragments:  map[string]*ast.FragmentDefinition{},
+		measured:   map[fragmentKey]measure{},
+		vars:       vars,
+	}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			m.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	root := h.built.QueryType()
+	switch op.Operation {
+	case ast.OperationTypeMutation:
+		root = h.built.MutationType()
+	case ast.OperationTypeSubscription:
+		root = h.built.SubscriptionType()
+	}
+
+	var parent graphql.Named
+	if root != nil {
+		parent = root
+	}
+	return m.selection(op.SelectionSet, parent, 1, map[string]bool{})
+}
+
+type (
+	measurer struct {
+		complexity map[string]ComplexityFunc
+		fragments  map[string]*ast.FragmentDefinition
+		// measured holds the fragments already expanded, a fragment spread many times is
+		// measured once so nested spreads do not expand exponentially
+		measured map[fragmentKey]measure
+		vars     map[string]any
+	}
+
+	fragmentKey struct {
+		name   string
+		parent string
+	}
+
+	// measure is the depth of a fragment below the field spreading it and its complexity
+	measure struct {
+		depth      int
+		complexity int
+	}
+)
+
+// selection returns the depth and the complexity of the fields of set at depth, visited
+// guards against the fragment cycles
+func (m *measurer) selection(set *ast.SelectionSet, parent graphql.Named, depth int, visited map[string]bool) (int, int) {
+	if set == nil {
+		return depth - 1, 0
+	}
+
+	maxDepth, complexity := depth-1, 0
+	add := func(d, c int) {
+		maxDepth = max(maxDepth, d)
+		complexity = saturatingAdd(complexity, c)
+	}
+
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			name := s.Name.Value
+			if strings.HasPrefix(name, "__") {
+				continue
+			}
+
+			var (
+				child graphql.Named
+				typ   string
+			)
+			if o, ok := parent.(*graphql.Object); ok {
+				typ = o.Name()
+				if def := o.Fields()[name]; def != nil {
+					child = graphql.GetNamed(def.Type)
+				}
+			}
+
+			childDepth, childComplexity := m.selection(s.SelectionSet, child, depth+1, visited)
+			cost := saturatingAdd(1, childComplexity)
+			if f := m.complexity[typ+"."+name]; f != nil {
+				if cost = f(childComplexity, m.arguments(s.Arguments)); cost < 0 {
+					cost = math.MaxInt
+				}
+			}
+			add(max(depth, childDepth), cost)
+		case *ast.InlineFragment:
+			// The schema has no interfaces nor unions, a fragment applies to its parent
+			add(m.selection(s.SelectionSet, parent, depth, visited))
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			fragment, ok := m.fragments[name]
+			if !ok || visited[name] {
+				continue
+			}
+
+			key := fragmentKey{name: name}
+			if parent != nil {
+				key.parent = parent.String()
+			}
+			f, ok := m.measured[key]
+			if !ok {
+				visited[name] = true
+				d, c := m.selection(fragment.SelectionSet, parent, 1, visited)
+				delete(visited, name)
+				f = measure{depth: d, complexity: c}
+				m.measured[key] = f
+			}
+			add(depth-1+f.depth, f.complexity)
+		}
+	}
+	return maxDepth, complexity
+}
+
+// saturatingAdd adds the non-negative a and b, math.MaxInt when it overflows
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+// saturatingMul multiplies the non-negative a and b, math.MaxInt when it overflows
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
+
+func (m *measurer) arguments(arguments []*ast.Argument) map[string]any {
+	args := make(map[string]any, len(arguments))
+	for _, arg := range arguments {
+		args[arg.Name.Value] = astValue(arg.Value, m.vars)
+	}
+	return args
+}
@@ -0,0 +1,219 @@
+package mygraphql
+
+import (
+	"context"
+	"sync"
+
+	mydatabase "github.com/gianglt2198/platforms/database"
+	myerrors "github.com/gianglt2198/platforms/errors"
+)
+
+type (
+	// BatchFunc loads the values of keys in one call, a key missing from the map has no value
+	BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+	// LoaderConfig holds configuration for Loader
+	LoaderConfig struct {
+		// MaxBatch is the maximum number of keys of a batch, 100 by default
+		MaxBatch int
+	}
+
+	// Loader batches and caches the loads of a request, the keys loaded while resolving a
+	// level of the query are fetched with one call of the batch function. The loads made
+	// outside a request of the Handler are not batched, see WithLoaders
+	Loader[K comparable, V any] struct {
+		batch BatchFunc[K, V]
+		cfg   LoaderConfig
+	}
+
+	// Finder is the part of mydatabase.RepositoryIf used by the repository loaders
+	Finder[T any] interface {
+		FindBy(ctx context.Context, option *mydatabase.FindOption) (*[]T, *myerrors.AppError)
+	}
+
+	// loaders holds the state of the loaders for a request
+	loaders struct {
+		mu    sync.Mutex
+		state map[any]any
+	}
+
+	// requestLoader is the state of a loader for a request
+	requestLoader[K comparable, V any] struct {
+		mu      sync.Mutex
+		entries map[K]*entry[V]
+		pending []K
+	}
+
+	entry[V any] struct {
+		value V
+		err   error
+		done  bool
+	}
+
+	loadersKey struct{}
+)
+
+// DefaultLoaderConfig returns the default configuration
+func DefaultLoaderConfig() LoaderConfig {
+	return LoaderConfig{
+		MaxBatch: 100,
+	}
+}
+
+func (m LoaderConfig) apply(cfg *LoaderConfig) {
+	if m.MaxBatch > 0 {
+		cfg.MaxBatch = m.MaxBatch
+	}
+}
+
+func NewLoader[K comparable, V any](batch BatchFunc[K, V], configs ...LoaderConfig) *Loader[K, V] {
+	cfg := DefaultLoaderConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return &Loader[K, V]{batch: batch, cfg: cfg}
+}
+
+// RepositoryLoader loads the entities of repo by the value of column with one
+// "column IN ?" query per batch, key returns the value of the column of an entity
+func RepositoryLoader[T any, K comparable](repo Finder[T], column string, key func(entity *T) K, configs ...LoaderConfig) *Loader[K, *T] {
+	return NewLoader(func(ctx context.Context, keys []K) (map[K]*T, error) {
+		entities, err := findIn(ctx, repo, column, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[K]*T, len(entities))
+		for i := range entities {
+			values[key(&entities[i])] = &entities[i]
+		}
+		return values, nil
+	}, configs...)
+}
+
+// RepositoryGroupLoader loads the entities of repo sharing the value of column, like the
+// children of a one-to-many relation, with one "column IN ?" query per batch
+func RepositoryGroupLoader[T any, K comparable](repo Finder[T], column string, key func(entity *T) K, configs ...LoaderConfig) *Loader[K, []T] {
+	return NewLoader(func(ctx context.Context, keys []K) (map[K][]T, error) {
+		entities, err := findIn(ctx, repo, column, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[K][]T, len(keys))
+		for _, k := range keys {
+			values[k] = []T{}
+		}
+		for i := range entities {
+			k := key(&entities[i])
+			values[k] = append(values[k], entities[i])
+		}
+		return values, nil
+	}, configs...)
+}
+
+func findIn[T any, K comparable](ctx context.Context, repo Finder[T], column string, keys []K) ([]T, error) {
+	entities, aerr := repo.FindBy(ctx, &mydatabase.FindOption{
+		Where:  column + " IN ?",
+		Params: []interface{}{keys},
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+	return *entities, nil
+}
+
+// WithLoaders returns ctx in which the loads of the loaders are batched and cached until
+// the end of the request, the Handler calls it for every request
+func WithLoaders(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{state: map[any]any{}})
+}
+
+// Load returns the thunk of the value of key, the keys loaded before a thunk is called are
+// fetched together. The value of a missing key is the zero value
+func (l *Loader[K, V]) Load(ctx context.Context, key K) func() (V, error) {
+	r := l.request(ctx)
+	e := r.load(key)
+
+	return func() (V, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if !e.done {
+			l.dispatch(ctx, r)
+		}
+		return e.value, e.err
+	}
+}
+
+// LoadMany returns the thunk of the values of keys, in the order of keys
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) func() ([]V, error) {
+	thunks := make([]func() (V, error), 0, len(keys))
+	for _, key := range keys {
+		thunks = append(thunks, l.Load(ctx, key))
+	}
+
+	return func() ([]V, error) {
+		values := make([]V, 0, len(thunks))
+		for _, thunk := range thunks {
+			value, err := thunk()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+}
+
+// request returns the state of l for the request of ctx, a fresh one outside a request
+func (l *Loader[K, V]) request(ctx context.Context) *requestLoader[K, V] {
+	ls, ok := ctx.Value(loadersKey{}).(*loaders)
+	if !ok {
+		return &requestLoader[K, V]{entries: map[K]*entry[V]{}}
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	r, ok := ls.state[l].(*requestLoader[K, V])
+	if !ok {
+		r = &requestLoader[K, V]{entries: map[K]*entry[V]{}}
+		ls.state[l] = r
+	}
+	return r
+}
+
+func (r *requestLoader[K, V]) load(key K) *entry[V] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.entries[key]; ok {
+		return e
+	}
+	e := &entry[V]{}
+	r.entries[key] = e
+	r.pending = append(r.pending, key)
+	return e
+}
+
+// dispatch fetches the pending keys of r, r.mu is held
+func (l *Loader[K, V]) dispatch(ctx context.Context, r *requestLoader[K, V]) {
+	pending := r.pending
+	r.pending = nil
+
+	for start := 0; start < len(pending); start += l.cfg.MaxBatch {
+		keys := pending[start:min(start+l.cfg.MaxBatch, len(pending))]
+		values, err := l.batch(ctx, keys)
+		for _, key := range keys {
+			e := r.entries[key]
+			e.done = true
+			if err != nil {
+				e.err = err
+				continue
+			}
+			e.value = values[key]
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package mygraphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/gianglt2198/platforms/cache"
+	myerrors "github.com/gianglt2198/platforms/errors"
+)
+
+const (
+	// persistedPrefix is the prefix of the cache keys of the persisted queries
+	persistedPrefix = "graphql:pq:"
+
+	codePersistedNotFound = "graphql.004"
+)
+
+type (
+	// Request is a GraphQL request, the persisted query extension follows the automatic
+	// persisted queries of Apollo
+	Request struct {
+		Query         string            `json:"query"`
+		OperationName string            `json:"operationName"`
+		Variables     map[string]any    `json:"variables"`
+		Extensions    RequestExtensions `json:"extensions"`
+	}
+
+	RequestExtensions struct {
+		PersistedQuery *PersistedQuery `json:"persistedQuery,omitempty"`
+	}
+
+	// PersistedQuery identifies a query by the SHA-256 of its text
+	PersistedQuery struct {
+		Version int    `json:"version"`
+		Hash    string `json:"sha256Hash"`
+	}
+)
+
+// persistedQuery returns the query of req. A request sending only the hash of a query gets
+// the query registered with it, by the configuration or by a previous request sending both
+func (h *Handler) persistedQuery(ctx context.Context, req *Request) (string, *myerrors.AppError) {
+	pq := req.Extensions.PersistedQuery
+	if pq == nil || pq.Hash == "" {
+		if h.cfg.PersistedOnly {
+			return "", myerrors.New("graphql.006", "")
+		}
+		return req.Query, nil
+	}
+
+	if req.Query == "" {
+		if query, ok := h.cfg.PersistedQueries[pq.Hash]; ok {
+			return query, nil
+		}
+		if h.cfg.PersistedCache == nil {
+			return "", myerrors.New(codePersistedNotFound, "")
+		}
+
+		query, err := h.cfg.PersistedCache.Get(ctx, persistedPrefix+pq.Hash)
+		if errors.Is(err, cache.ErrNotFound) {
+			return "", myerrors.New(codePersistedNotFound, "")
+		}
+		if err != nil {
+			return "", myerrors.From(err)
+		}
+		return string(query), nil
+	}
+
+	sum := sha256.Sum256([]byte(req.Query))
+	if hex.EncodeToString(sum[:]) != pq.Hash {
+		return "", myerrors.New("graphql.005", "")
+	}
+
+	if _, ok := h.cfg.PersistedQueries[pq.Hash]; ok {
+		return req.Query, nil
+	}
+	// The clients cannot register queries when only the known ones are accepted
+	if h.cfg.PersistedOnly {
+		return "", myerrors.New("graphql.006", "")
+	}
+	if h.cfg.PersistedCache != nil {
+		if err := h.cfg.PersistedCache.Set(ctx, persistedPrefix+pq.Hash, []byte(req.Query), h.cfg.PersistedTTL); err != nil {
+			return "", myerrors.From(err)
+		}
+	}
+	return req.Query, nil
+}
@@ -0,0 +1,505 @@
+package mygraphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/gianglt2198/platforms/common"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/observability"
+	"github.com/gianglt2198/platforms/pkg/validation"
+	"github.com/graphql-go/graphql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	typeQuery    = "Query"
+	typeMutation = "Mutation"
+
+	// argInput is the argument of the use cases whose input is not a struct
+	argInput = "input"
+)
+
+type (
+	// ComplexityFunc returns the cost of a field from the cost of its selection and its
+	// arguments, see ListComplexity
+	ComplexityFunc func(childComplexity int, args map[string]any) int
+
+	// FieldConfig holds configuration for a field
+	FieldConfig struct {
+		Description string
+		// DeprecationReason marks the field deprecated
+		DeprecationReason string
+		// Complexity is the cost of the field in the complexity limit, 1 plus its selection
+		// by default
+		Complexity ComplexityFunc
+	}
+
+	// Schema builds a GraphQL schema from the use cases, the types are derived from the Go
+	// types like the OpenAPI documents: the fields are named after the json tags, the
+	// pointers, slices and maps are nullable, time.Time is a DateTime and the maps a JSON
+	Schema struct {
+		mu         sync.Mutex
+		query      graphql.Fields
+		mutation   graphql.Fields
+		fields     map[reflect.Type]graphql.Fields
+		complexity map[string]ComplexityFunc
+		outputs    map[reflect.Type]*graphql.Object
+		inputs     map[reflect.Type]*graphql.InputObject
+		names      map[string]reflect.Type
+	}
+)
+
+func (m FieldConfig) apply(cfg *FieldConfig) {
+	if m.Description != "" {
+		cfg.Description = m.Description
+	}
+	if m.DeprecationReason != "" {
+		cfg.DeprecationReason = m.DeprecationReason
+	}
+	if m.Complexity != nil {
+		cfg.Complexity = m.Complexity
+	}
+}
+
+func NewSchema() *Schema {
+	return &Schema{
+		query:      graphql.Fields{},
+		mutation:   graphql.Fields{},
+		fields:     map[reflect.Type]graphql.Fields{},
+		complexity: map[string]ComplexityFunc{},
+		outputs:    map[reflect.Type]*graphql.Object{},
+		inputs:     map[reflect.Type]*graphql.InputObject{},
+		names:      map[string]reflect.Type{},
+	}
+}
+
+// Query adds a field to the Query type resolved by the use case of a common.Handler, the
+// one served over REST by routes.Usecase. The fields of T are the arguments, validated
+// like the REST payloads:
+//
+//	mygraphql.Query(schema, "widgets", usecase.ListWidgets, mygraphql.FieldConfig{
+//		Complexity: mygraphql.ListComplexity("take", 20),
+//	})
+func Query[T any, R any](s *Schema, name string, f common.Handler[T, R], configs ...FieldConfig) {
+	s.root(s.query, typeQuery, name, usecaseField(s, typeQuery, f), configs)
+}
+
+// Mutation adds a field to the Mutation type resolved by the use case of a common.Handler,
+// see Query
+func Mutation[T any, R any](s *Schema, name string, f common.Handler[T, R], configs ...FieldConfig) {
+	s.root(s.mutation, typeMutation, name, usecaseField(s, typeMutation, f), configs)
+}
+
+// Field adds a field resolved from its parent to the object of the struct P, e.g. a value
+// computed from the other fields. The relations are loaded in batches with LoaderField
+func Field[P any, R any](s *Schema, name string, f func(ctx context.Context, parent P) (R, error), configs ...FieldConfig) {
+	parentType := reflect.TypeFor[P]()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	field := &graphql.Field{
+		Type: s.output(reflect.TypeFor[R]()),
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			parent, ok := parentOf[P](p.Source)
+			if !ok {
+				return nil, nil
+			}
+
+			ctx, span := startResolve(p)
+			defer span.End()
+
+			result, err := f(ctx, parent)
+			endResolve(span, err)
+			return result, err
+		},
+	}
+	s.add(parentType, name, field, configs)
+}
+
+// LoaderField adds a field to the object of the struct P loaded by loader with the key of
+// the parent, the keys of the parents of a level of the query are loaded in one batch:
+//
+//	owners := mygraphql.RepositoryLoader(userRepo, "id", func(u *User) int { return u.ID })
+//	mygraphql.LoaderField(schema, "owner", owners, func(w Widget) int { return w.OwnerID })
+func LoaderField[P any, K comparable, V any](s *Schema, name string, loader *Loader[K, V], key func(parent P) K, configs ...FieldConfig) {
+	parentType := reflect.TypeFor[P]()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	field := &graphql.Field{
+		Type: s.output(reflect.TypeFor[V]()),
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			parent, ok := parentOf[P](p.Source)
+			if !ok {
+				return nil, nil
+			}
+
+			thunk := loader.Load(p.Context, key(parent))
+			return func() (any, error) {
+				return thunk()
+			}, nil
+		},
+	}
+	s.add(parentType, name, field, configs)
+}
+
+// ListComplexity costs a list field its selection times the number of items requested by
+// the argument arg, e.g. "take", or size when the argument is missing
+func ListComplexity(arg string, size int) ComplexityFunc {
+	return func(childComplexity int, args map[string]any) int {
+		n := size
+		switch v := args[arg].(type) {
+		case int:
+			n = v
+		case float64:
+			n = int(v)
+		case string:
+			if i, err := strconv.Atoi(v); err == nil {
+				n = i
+			}
+		}
+		if n < 1 {
+			n = 1
+		}
+		return saturatingAdd(1, saturatingMul(childComplexity, n))
+	}
+}
+
+// Build returns the GraphQL schema, the fields are added before
+func (s *Schema) Build() (graphql.Schema, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.query) == 0 {
+		return graphql.Schema{}, errors.New("graphql: the schema has no query")
+	}
+
+	cfg := graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: typeQuery, Fields: s.query}),
+	}
+	if len(s.mutation) > 0 {
+		cfg.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: typeMutation, Fields: s.mutation})
+	}
+	return graphql.NewSchema(cfg)
+}
+
+func (s *Schema) root(fields graphql.Fields, typ, name string, field func() *graphql.Field, configs []FieldConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := field()
+	cfg := newFieldConfig(configs)
+	f.Description = cfg.Description
+	f.DeprecationReason = cfg.DeprecationReason
+	fields[name] = f
+	if cfg.Complexity != nil {
+		s.complexity[typ+"."+name] = cfg.Complexity
+	}
+}
+
+// add adds a resolved field to the object of t
+func (s *Schema) add(t reflect.Type, name string, field *graphql.Field, configs []FieldConfig) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic("graphql: the parent of the field " + name + " must be a struct")
+	}
+
+	cfg := newFieldConfig(configs)
+	field.Description = cfg.Description
+	field.DeprecationReason = cfg.DeprecationReason
+
+	if s.fields[t] == nil {
+		s.fields[t] = graphql.Fields{}
+	}
+	s.fields[t][name] = field
+	if cfg.Complexity != nil {
+		s.complexity[s.object(t).Name()+"."+name] = cfg.Complexity
+	}
+}
+
+func newFieldConfig(configs []FieldConfig) FieldConfig {
+	var cfg FieldConfig
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	return cfg
+}
+
+// usecaseField returns the field of a use case, built once the schema is locked
+func usecaseField[T any, R any](s *Schema, typ string, f common.Handler[T, R]) func() *graphql.Field {
+	return func() *graphql.Field {
+		inputType := reflect.TypeFor[T]()
+		args, decode := s.arguments(inputType)
+
+		return &graphql.Field{
+			Type: s.output(reflect.TypeFor[R]()),
+			Args: args,
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				var input T
+				if err := decode(p.Args, &input); err != nil {
+					return nil, myerrors.PayloadInvalid(err.Error()).WithCause(err)
+				}
+
+				if err := validation.Validate(p.Context, input); err != nil {
+					return nil, err
+				}
+
+				ctx, span := startResolve(p)
+				defer span.End()
+
+				result, err := f(ctx, input)
+				endResolve(span, err)
+				return result, err
+			},
+		}
+	}
+}
+
+// arguments returns the arguments of the input type t and the function decoding them, the
+// fields of a struct or a single input argument
+func (s *Schema) arguments(t reflect.Type) (graphql.FieldConfigArgument, func(args map[string]any, dst any) error) {
+	st := t
+	if st.Kind() == reflect.Pointer {
+		st = st.Elem()
+	}
+
+	decode := func(args map[string]any, dst any) error {
+		if len(args) == 0 {
+			return nil
+		}
+		data, err := json.Marshal(args)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, dst)
+	}
+
+	switch {
+	case st.Kind() == reflect.Struct && st != timeType:
+		args := graphql.FieldConfigArgument{}
+		for _, f := range structFields(st) {
+			if typ := s.input(f.typ); typ != nil {
+				args[f.name] = &graphql.ArgumentConfig{Type: typ}
+			}
+		}
+		return args, decode
+	case st.Kind() == reflect.Interface:
+		return graphql.FieldConfigArgument{}, func(map[string]any, any) error { return nil }
+	}
+
+	return graphql.FieldConfigArgument{
+		argInput: &graphql.ArgumentConfig{Type: s.input(t)},
+	}, func(args map[string]any, dst any) error {
+		value, ok := args[argInput]
+		if !ok {
+			return nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, dst)
+	}
+}
+
+// output returns the output type of t, nil when it has none like the functions
+func (s *Schema) output(t reflect.Type) graphql.Output {
+	nonNull := true
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nonNull = false
+	}
+
+	var typ graphql.Output
+	switch {
+	case t == timeType:
+		typ = DateTime
+	case t == rawType, t.Kind() == reflect.Map, t.Kind() == reflect.Interface:
+		typ, nonNull = JSON, false
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		typ, nonNull = JSON, false
+	case t.Kind() == reflect.Slice, t.Kind() == reflect.Array:
+		elem := s.output(t.Elem())
+		if elem == nil {
+			return nil
+		}
+		typ, nonNull = graphql.NewList(elem), false
+	case t.Kind() == reflect.Struct:
+		if t.Name() == "" {
+			typ = JSON
+		} else {
+			typ = s.object(t)
+		}
+	default:
+		typ = scalar(t)
+	}
+
+	if typ == nil {
+		return nil
+	}
+	if nonNull {
+		return graphql.NewNonNull(typ)
+	}
+	return typ
+}
+
+// input returns the input type of t, the inputs are nullable and checked by the validate tags
+func (s *Schema) input(t reflect.Type) graphql.Input {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return DateTime
+	case t == rawType, t.Kind() == reflect.Map, t.Kind() == reflect.Interface:
+		return JSON
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return JSON
+	case t.Kind() == reflect.Slice, t.Kind() == reflect.Array:
+		elem := s.input(t.Elem())
+		if elem == nil {
+			return nil
+		}
+		return graphql.NewList(elem)
+	case t.Kind() == reflect.Struct:
+		if t.Name() == "" {
+			return JSON
+		}
+		return s.inputObject(t)
+	}
+
+	if typ := scalar(t); typ != nil {
+		return typ
+	}
+	return nil
+}
+
+// object returns the object of the struct t, its fields are resolved once the schema is
+// built so the types may refer to each other
+func (s *Schema) object(t reflect.Type) *graphql.Object {
+	if o, ok := s.outputs[t]; ok {
+		return o
+	}
+
+	o := graphql.NewObject(graphql.ObjectConfig{
+		Name: s.uniqueName(t, typeName(t)),
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := graphql.Fields{}
+			for _, f := range structFields(t) {
+				typ := s.output(f.typ)
+				if typ == nil {
+					continue
+				}
+				index := f.index
+				fields[f.name] = &graphql.Field{
+					Type: typ,
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return fieldValue(p.Source, index), nil
+					},
+				}
+			}
+			for name, f := range s.fields[t] {
+				fields[name] = f
+			}
+			return fields
+		}),
+	})
+	s.outputs[t] = o
+	return o
+}
+
+func (s *Schema) inputObject(t reflect.Type) *graphql.InputObject {
+	if o, ok := s.inputs[t]; ok {
+		return o
+	}
+
+	o := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: s.uniqueName(t, typeName(t)+"Input"),
+		Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+			fields := graphql.InputObjectConfigFieldMap{}
+			for _, f := range structFields(t) {
+				if typ := s.input(f.typ); typ != nil {
+					fields[f.name] = &graphql.InputObjectFieldConfig{Type: typ}
+				}
+			}
+			return fields
+		}),
+	})
+	s.inputs[t] = o
+	return o
+}
+
+// uniqueName suffixes name when another Go type already uses it
+func (s *Schema) uniqueName(t reflect.Type, name string) string {
+	unique := name
+	for i := 2; ; i++ {
+		other, ok := s.names[unique]
+		if !ok || other == t {
+			break
+		}
+		unique = name + strconv.Itoa(i)
+	}
+	s.names[unique] = t
+	return unique
+}
+
+func scalar(t reflect.Type) *graphql.Scalar {
+	switch t.Kind() {
+	case reflect.String:
+		return graphql.String
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return graphql.Int
+	case reflect.Float32, reflect.Float64:
+		return graphql.Float
+	}
+	return nil
+}
+
+// parentOf returns the source of a field as P, a value or a pointer
+func parentOf[P any](source any) (P, bool) {
+	switch v := source.(type) {
+	case P:
+		return v, true
+	case *P:
+		if v != nil {
+			return *v, true
+		}
+	}
+	var zero P
+	return zero, false
+}
+
+// startResolve starts the span of a resolver, a child of the span of the operation
+func startResolve(p graphql.ResolveParams) (context.Context, trace.Span) {
+	name := p.Info.ParentType.Name() + "." + p.Info.FieldName
+	return observability.Tracer("graphql").Start(p.Context, "graphql.resolve "+name,
+		trace.WithAttributes(
+			attribute.String("graphql.field.name", p.Info.FieldName),
+			attribute.String("graphql.field.parent", p.Info.ParentType.Name()),
+		),
+	)
+}
+
+func endResolve(span trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.RecordError(err)
+		return
+	}
+	span.SetStatus(codes.Ok, "field resolved")
+}
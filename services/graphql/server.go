@@ -0,0 +1,148 @@
+package mygraphql
+
+import (
+	"context"
+	"net"
+
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	mycore "github.com/gianglt2198/platforms/server"
+	"github.com/gianglt2198/platforms/services/rest/app"
+	"github.com/gianglt2198/platforms/services/rest/middlewares"
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+const Protocol = "graphql"
+
+type (
+	// ServerConfig holds configuration for Server
+	ServerConfig struct {
+		// Name is the name of the server in the ServerRegistry
+		Name string
+		// Version is the version of the service
+		Version string
+		// ServiceName labels the traces and the metrics
+		ServiceName string
+		// Address is the address listened on, ":8081" by default
+		Address string
+		// IsProdEnv hides the detail of the server errors
+		IsProdEnv bool
+		// Middlewares run after the built-in ones, before the handler, e.g. the authentication
+		Middlewares []fiber.Handler
+	}
+
+	// Server serves a Handler on its own fiber app with the recovery, request id, tracing
+	// and metrics middlewares of the REST apps, for the services exposing GraphQL apart
+	Server struct {
+		*mycore.Lifecycle
+
+		cfg     ServerConfig
+		handler *Handler
+	}
+
+	// runner serves a fiber app
+	runner struct {
+		app *fiber.App
+	}
+)
+
+var _ mycore.Server = (*Server)(nil)
+
+// DefaultServerConfig returns the default configuration
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Name:        "graphql",
+		Version:     "1.0.0",
+		ServiceName: "platform-app",
+		Address:     ":8081",
+	}
+}
+
+func (m ServerConfig) apply(cfg *ServerConfig) {
+	if m.Name != "" {
+		cfg.Name = m.Name
+	}
+	if m.Version != "" {
+		cfg.Version = m.Version
+	}
+	if m.ServiceName != "" {
+		cfg.ServiceName = m.ServiceName
+	}
+	if m.Address != "" {
+		cfg.Address = m.Address
+	}
+	if m.IsProdEnv {
+		cfg.IsProdEnv = true
+	}
+	if len(m.Middlewares) > 0 {
+		cfg.Middlewares = append(cfg.Middlewares, m.Middlewares...)
+	}
+}
+
+// NewServer returns a stopped server of handler
+func NewServer(handler *Handler, logger oblogger.ObLogger, configs ...ServerConfig) *Server {
+	cfg := DefaultServerConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	s := &Server{
+		cfg:     cfg,
+		handler: handler,
+	}
+	s.Lifecycle = mycore.NewLifecycle(mycore.LifecycleConfig{
+		Name:     cfg.Name,
+		Protocol: Protocol,
+		Version:  cfg.Version,
+		Address:  cfg.Address,
+		Logger:   logger.GetLogger(),
+		Build:    s.build,
+		Metadata: func() map[string]any {
+			return map[string]any{"path": handler.cfg.Path}
+		},
+	})
+	return s
+}
+
+// build returns a fiber app serving the handler behind the middlewares of the REST apps
+func (s *Server) build() mycore.Runner {
+	fiberApp := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		ErrorHandler: routes.ErrorHandler(routes.ErrorHandlerConfig{
+			IsProdEnv: s.cfg.IsProdEnv,
+			Catalog:   s.handler.cfg.Catalog,
+		}),
+	})
+
+	fiberApp.Use(recover.New(recover.Config{
+		EnableStackTrace: !s.cfg.IsProdEnv,
+	}))
+	fiberApp.Use(middlewares.RequestIDMiddleware)
+	fiberApp.Use(middlewares.TracingMiddleware("main", "request_caller",
+		middlewares.TracingConfig{
+			ServiceName:    s.cfg.ServiceName,
+			ServiceVersion: s.cfg.Version,
+		}))
+	fiberApp.Use(middlewares.MetricMiddleware(middlewares.MetricConfig{
+		ServiceName:    s.cfg.ServiceName,
+		ServiceVersion: s.cfg.Version,
+	}))
+
+	fiberApp.Get("/health", app.HealthCheck)
+
+	for _, m := range s.cfg.Middlewares {
+		fiberApp.Use(m)
+	}
+	s.handler.Register(fiberApp)
+
+	return &runner{app: fiberApp}
+}
+
+func (r *runner) Serve(listener net.Listener) error {
+	return r.app.Listener(listener)
+}
+
+func (r *runner) Shutdown(ctx context.Context) error {
+	return r.app.ShutdownWithContext(ctx)
+}
@@ -0,0 +1,232 @@
+package mygraphql
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+var (
+	// DateTime is a time.Time as RFC 3339
+	DateTime = graphql.NewScalar(graphql.ScalarConfig{
+		Name:        "DateTime",
+		Description: "Date and time in RFC 3339 format",
+		Serialize: func(value any) any {
+			switch t := value.(type) {
+			case time.Time:
+				return t.Format(time.RFC3339Nano)
+			case *time.Time:
+				if t != nil {
+					return t.Format(time.RFC3339Nano)
+				}
+			}
+			return nil
+		},
+		ParseValue: func(value any) any {
+			if s, ok := value.(string); ok {
+				if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					return t
+				}
+			}
+			return nil
+		},
+		ParseLiteral: func(value ast.Value) any {
+			if s, ok := value.(*ast.StringValue); ok {
+				if t, err := time.Parse(time.RFC3339Nano, s.Value); err == nil {
+					return t
+				}
+			}
+			return nil
+		},
+	})
+
+	// JSON is any JSON value, the maps and the interfaces
+	JSON = graphql.NewScalar(graphql.ScalarConfig{
+		Name:        "JSON",
+		Description: "Any JSON value",
+		Serialize: func(value any) any {
+			if raw, ok := value.(json.RawMessage); ok {
+				var v any
+				if err := json.Unmarshal(raw, &v); err != nil {
+					return nil
+				}
+				return v
+			}
+			return value
+		},
+		ParseValue: func(value any) any {
+			return value
+		},
+		ParseLiteral: func(value ast.Value) any {
+			return astValue(value, nil)
+		},
+	})
+
+	timeType = reflect.TypeFor[time.Time]()
+	rawType  = reflect.TypeFor[json.RawMessage]()
+	// pagePkg is the package of routes.Page, whose fields are named like the meta of the
+	// envelopes
+	pagePkg   = reflect.TypeFor[routes.Meta]().PkgPath()
+	pageNames = map[string]string{
+		"Items":      "items",
+		"Total":      "total",
+		"Page":       "page",
+		"Take":       "take",
+		"NextCursor": "next_cursor",
+	}
+)
+
+// structField is a field of a struct, the fields of the embedded structs are flattened
+type structField struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+// structFields returns the fields of t named after their json tags, like the REST payloads
+func structFields(t reflect.Type) []structField {
+	page := t.PkgPath() == pagePkg && strings.HasPrefix(t.Name(), "Page[")
+
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != timeType {
+			for _, embedded := range structFields(ft) {
+				embedded.index = append([]int{i}, embedded.index...)
+				fields = append(fields, embedded)
+			}
+			continue
+		}
+
+		if page {
+			if name = pageNames[f.Name]; name == "" {
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{name: name, index: []int{i}, typ: f.Type})
+	}
+	return fields
+}
+
+// typeName names a Go type in the schema, the arguments of a generic type are appended
+// like in the OpenAPI documents, e.g. Page_Widget
+func typeName(t reflect.Type) string {
+	base, args, ok := strings.Cut(t.Name(), "[")
+	if !ok {
+		return base
+	}
+
+	var b strings.Builder
+	b.WriteString(base)
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		lists := strings.Count(arg, "[]")
+		arg = strings.TrimLeft(arg, "*[]")
+		if i := strings.LastIndex(arg, "."); i >= 0 {
+			arg = arg[i+1:]
+		}
+		b.WriteString("_" + strings.Repeat("List", lists) + arg)
+	}
+	return b.String()
+}
+
+// fieldValue returns the value of a struct field for the scalars of graphql-go, which
+// only know the predeclared types
+func fieldValue(source any, index []int) any {
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	f, err := v.FieldByIndexErr(index)
+	if err != nil {
+		return nil
+	}
+	return basicValue(f)
+}
+
+func basicValue(v reflect.Value) any {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem() == timeType {
+			return v.Interface()
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+// astValue returns the value of a literal, the variables are read from vars
+func astValue(value ast.Value, vars map[string]any) any {
+	switch v := value.(type) {
+	case *ast.Variable:
+		return vars[v.Name.Value]
+	case *ast.IntValue:
+		n, _ := strconv.Atoi(v.Value)
+		return n
+	case *ast.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.EnumValue:
+		return v.Value
+	case *ast.ListValue:
+		list := make([]any, 0, len(v.Values))
+		for _, item := range v.Values {
+			list = append(list, astValue(item, vars))
+		}
+		return list
+	case *ast.ObjectValue:
+		object := make(map[string]any, len(v.Fields))
+		for _, f := range v.Fields {
+			object[f.Name.Value] = astValue(f.Value, vars)
+		}
+		return object
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package mygrpc
+
+import (
+	"context"
+
+	"github.com/gianglt2198/platforms/common"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/validation"
+)
+
+// Unary exposes the use case of a common.Handler, the one served over REST by
+// routes.Usecase, as a unary method. decode maps the request message to the input of the
+// use case, which is validated like the REST payloads, and encode maps its result to the
+// response message:
+//
+//	func (s *widgetServer) GetWidget(ctx context.Context, req *pb.GetWidgetRequest) (*pb.Widget, error) {
+//		return mygrpc.Unary(s.usecase.GetWidget, decodeGetWidget, encodeWidget)(ctx, req)
+//	}
+func Unary[Req any, Resp any, T any, R any](f common.Handler[T, R], decode func(*Req) (T, error), encode func(R) (*Resp, error)) func(context.Context, *Req) (*Resp, error) {
+	return func(ctx context.Context, req *Req) (*Resp, error) {
+		input, err := decode(req)
+		if err != nil {
+			return nil, myerrors.PayloadInvalid(err.Error()).WithCause(err)
+		}
+
+		if err := validation.Validate(ctx, input); err != nil {
+			return nil, err
+		}
+
+		output, err := f(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		return encode(output)
+	}
+}
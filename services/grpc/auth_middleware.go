@@ -0,0 +1,85 @@
+package mygrpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gianglt2198/platforms/common"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/middleware"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const MetadataAuthorization = "authorization"
+
+// AuthConfig holds configuration for the authentication middleware
+type AuthConfig struct {
+	// Optional lets the calls without a token through anonymously
+	Optional bool
+	// Skip bypasses the authentication for some calls, the health checks and the
+	// reflection by default
+	Skip func(call *Call) bool
+}
+
+// DefaultAuthConfig returns the default configuration
+func DefaultAuthConfig() AuthConfig {
+	return AuthConfig{
+		Skip: isInfrastructure,
+	}
+}
+
+func (m AuthConfig) apply(cfg *AuthConfig) {
+	if m.Optional {
+		cfg.Optional = true
+	}
+	if m.Skip != nil {
+		cfg.Skip = m.Skip
+	}
+}
+
+// AuthMiddleware verifies the bearer token of the authorization metadata with authenticator
+// and stores the principal in the context, see common.PrincipalFromContext. A call without
+// token is rejected with auth.001 unless the authentication is optional
+func AuthMiddleware(authenticator *middleware.Authenticator, configs ...AuthConfig) Middleware {
+	cfg := DefaultAuthConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			if cfg.Skip != nil && cfg.Skip(call) {
+				return next(ctx, call)
+			}
+
+			token := bearerToken(incoming(ctx, MetadataAuthorization))
+			if token == "" {
+				if cfg.Optional {
+					return next(ctx, call)
+				}
+				return myerrors.New("auth.001", "")
+			}
+
+			principal, aerr := authenticator.Authenticate(ctx, token)
+			if aerr != nil {
+				return aerr
+			}
+
+			return next(common.WithPrincipal(ctx, principal), call)
+		}
+	}
+}
+
+func bearerToken(header string) string {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// isInfrastructure reports whether call is a health check or a reflection request
+func isInfrastructure(call *Call) bool {
+	return call.Service == grpc_health_v1.Health_ServiceDesc.ServiceName ||
+		strings.HasPrefix(call.Service, "grpc.reflection.")
+}
@@ -0,0 +1,307 @@
+package mygrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/problem"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+const (
+	MetadataAcceptLanguage = "accept-language"
+
+	// The keys of the ErrorInfo metadata carrying the AppError
+	errorInfoStatus   = "status"
+	errorInfoCategory = "category"
+)
+
+// ErrorConfig holds configuration for the error middleware
+type ErrorConfig struct {
+	// Domain is the domain of the ErrorInfo details, the name of the service
+	Domain string
+	// IsProdEnv hides the detail of the server errors
+	IsProdEnv bool
+	// Catalog localizes the messages and the field errors in the locale of the
+	// accept-language metadata, myerrors.DefaultCatalog by default
+	Catalog *myerrors.Catalog
+}
+
+// DefaultErrorConfig returns the default configuration
+func DefaultErrorConfig() ErrorConfig {
+	return ErrorConfig{
+		Domain:  "platform-app",
+		Catalog: myerrors.DefaultCatalog(),
+	}
+}
+
+func (m ErrorConfig) apply(cfg *ErrorConfig) {
+	if m.Domain != "" {
+		cfg.Domain = m.Domain
+	}
+	if m.IsProdEnv {
+		cfg.IsProdEnv = true
+	}
+	if m.Catalog != nil {
+		cfg.Catalog = m.Catalog
+	}
+}
+
+// ErrorMiddleware answers the errors of the handlers with a gRPC status, see Status. The
+// status errors returned by the handlers are kept
+func ErrorMiddleware(configs ...ErrorConfig) Middleware {
+	cfg := DefaultErrorConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			err := next(ctx, call)
+			if err == nil {
+				return nil
+			}
+
+			locale := cfg.Catalog.MatchLocale(incoming(ctx, MetadataAcceptLanguage))
+			return newStatus(err, locale, cfg).Err()
+		}
+	}
+}
+
+// Status converts err, an AppError, validation errors or any error, to a gRPC status in
+// locale. The code follows the category of the error, the details carry an ErrorInfo whose
+// reason is the error code and a BadRequest listing the field errors, like the problems of
+// the REST errors
+func Status(err error, locale string, configs ...ErrorConfig) *status.Status {
+	cfg := DefaultErrorConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	return newStatus(err, locale, cfg)
+}
+
+func newStatus(err error, locale string, cfg ErrorConfig) *status.Status {
+	if err == nil {
+		return nil
+	}
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.New(codes.Canceled, err.Error())
+	}
+
+	// The validation errors are the payload.001 of the problems, any other error is
+	// converted like the AppErrors of the replies, e.g. the Postgres errors
+	var validErrs validator.ValidationErrors
+	if !errors.As(err, &validErrs) {
+		err = myerrors.From(err)
+	}
+
+	p := problem.New(err, locale, problem.Config{
+		IsProdEnv: cfg.IsProdEnv,
+		Catalog:   cfg.Catalog,
+	})
+
+	category := myerrors.CategoryOf(p.Status)
+	var appErr *myerrors.AppError
+	if errors.As(err, &appErr) && appErr.Category != "" {
+		category = appErr.Category
+	}
+
+	message := p.Detail
+	if message == "" {
+		message = p.Title
+	}
+
+	info := &errdetails.ErrorInfo{
+		Reason: p.Code,
+		Domain: cfg.Domain,
+		Metadata: map[string]string{
+			errorInfoStatus:   strconv.Itoa(p.Status),
+			errorInfoCategory: string(category),
+		},
+	}
+	for k, v := range p.Metadata {
+		info.Metadata[k] = metadataValue(v)
+	}
+
+	details := []protoadapt.MessageV1{info}
+	if len(p.Errors) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, fe := range p.Errors {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fe.Field,
+				Description: fe.Message,
+				Reason:      fe.Code,
+			})
+		}
+		details = append(details, badRequest)
+	}
+	details = append(details, &errdetails.LocalizedMessage{Locale: locale, Message: message})
+
+	s := status.New(grpcCode(p.Status, category), message)
+	if withDetails, err := s.WithDetails(details...); err == nil {
+		return withDetails
+	}
+	return s
+}
+
+// FromStatus converts the status error of a call back to an AppError, the code and the
+// field errors are read from the details set by Status
+func FromStatus(err error) *myerrors.AppError {
+	if err == nil {
+		return nil
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return myerrors.From(err)
+	}
+	if s.Code() == codes.OK {
+		return nil
+	}
+
+	code := "grpc." + strings.ToLower(toSnake(s.Code().String()))
+	httpStatus := httpStatusOf(s.Code())
+	var (
+		category myerrors.Category
+		fields   []myerrors.FieldError
+		metadata map[string]any
+	)
+
+	for _, detail := range s.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if d.Reason != "" {
+				code = d.Reason
+			}
+			for k, v := range d.Metadata {
+				switch k {
+				case errorInfoStatus:
+					if n, err := strconv.Atoi(v); err == nil {
+						httpStatus = n
+					}
+				case errorInfoCategory:
+					category = myerrors.Category(v)
+				default:
+					if metadata == nil {
+						metadata = map[string]any{}
+					}
+					metadata[k] = v
+				}
+			}
+		case *errdetails.BadRequest:
+			for _, fv := range d.FieldViolations {
+				fields = append(fields, myerrors.FieldError{
+					Field:   fv.Field,
+					Code:    fv.Reason,
+					Message: fv.Description,
+				})
+			}
+		}
+	}
+
+	appErr := myerrors.NewAppError(code, s.Message(), httpStatus).WithCause(err)
+	if category != "" {
+		appErr.WithCategory(category)
+	}
+	for _, fe := range fields {
+		appErr.WithField(fe.Field, fe.Code, fe.Message)
+	}
+	for k, v := range metadata {
+		appErr.WithMetadata(k, v)
+	}
+	return appErr
+}
+
+// grpcCode returns the code of an error of the HTTP status and the category
+func grpcCode(httpStatus int, category myerrors.Category) codes.Code {
+	switch httpStatus {
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	}
+
+	switch category {
+	case myerrors.CategoryValidation:
+		return codes.InvalidArgument
+	case myerrors.CategoryNotFound:
+		return codes.NotFound
+	case myerrors.CategoryConflict:
+		return codes.AlreadyExists
+	case myerrors.CategoryUnauthorized:
+		return codes.Unauthenticated
+	case myerrors.CategoryForbidden:
+		return codes.PermissionDenied
+	case myerrors.CategoryTimeout:
+		return codes.DeadlineExceeded
+	case myerrors.CategoryUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// httpStatusOf returns the HTTP status of a code, for the statuses without ErrorInfo
+func httpStatusOf(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Canceled:
+		return 499
+	}
+	return http.StatusInternalServerError
+}
+
+// toSnake turns a code name like NotFound into not_found
+func toSnake(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func metadataValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,63 @@
+package mygrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/gianglt2198/platforms/common"
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	MetadataRequestID = "x-request-id"
+	REQUEST_ID        = "requestId"
+)
+
+// LoggerMiddleware logs every call with its request id, read from x-request-id or generated.
+// The id is stored in the context under the request id read by oblogger and
+// common.KEY_CORRELATION_ID, and sent back in the x-request-id header
+func LoggerMiddleware(logger oblogger.ObLogger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			requestId := incoming(ctx, MetadataRequestID)
+			if requestId == "" {
+				requestId = uuid.NewString()
+			}
+
+			ctx = context.WithValue(ctx, REQUEST_ID, requestId)
+			ctx = context.WithValue(ctx, common.KEY_CORRELATION_ID, requestId)
+			_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, requestId))
+
+			logFields := []zap.Field{
+				zap.String("method", call.FullMethod),
+				zap.String("request_id", requestId),
+			}
+
+			start := time.Now()
+			err := next(ctx, call)
+			logFields = append(logFields,
+				zap.String("code", status.Code(err).String()),
+				zap.Int64("duration_ms", time.Since(start).Milliseconds()),
+			)
+
+			if err != nil {
+				logger.GetLogger().With(logFields...).Error("Call failed", zap.Error(err))
+				return err
+			}
+
+			logger.GetLogger().With(logFields...).Info("Call succeeded")
+			return nil
+		}
+	}
+}
+
+// RequestID returns the request id of the call
+func RequestID(ctx context.Context) string {
+	requestId, _ := ctx.Value(REQUEST_ID).(string)
+	return requestId
+}
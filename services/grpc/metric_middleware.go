@@ -0,0 +1,152 @@
+package mygrpc
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gianglt2198/platforms/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/status"
+)
+
+// MetricConfig holds configuration for the metric middleware
+type MetricConfig struct {
+	// ServiceName is the name of the service being measured
+	ServiceName string
+	// ServiceVersion is the version of the service
+	ServiceVersion string
+	// Skip defines a function to skip the middleware
+	Skip func(call *Call) bool
+	// Metrics list to track calls
+	// *** counter ***
+	grpcCallsCounter       metric.Int64Counter
+	grpcCallErrorsCounter  metric.Int64Counter
+	grpcActiveCallsCounter metric.Int64UpDownCounter
+
+	// *** histogram ***
+	grpcCallDurationHistogram metric.Int64Histogram
+}
+
+// DefaultMetricConfig returns the default configuration
+func DefaultMetricConfig() MetricConfig {
+	return MetricConfig{
+		ServiceName:    "platform-app",
+		ServiceVersion: "1.0.0",
+		Skip:           isInfrastructure,
+	}
+}
+
+func (m MetricConfig) apply(cfg *MetricConfig) {
+	if m.ServiceName != "" {
+		cfg.ServiceName = m.ServiceName
+	}
+	if m.ServiceVersion != "" {
+		cfg.ServiceVersion = m.ServiceVersion
+	}
+	if m.Skip != nil {
+		cfg.Skip = m.Skip
+	}
+}
+
+// MetricMiddleware records the latency and the errors per method, labelled with the gRPC
+// status code
+func MetricMiddleware(configs ...MetricConfig) Middleware {
+	cfg := DefaultMetricConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	cfg.newCounters()
+	cfg.newHistograms()
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			if cfg.Skip != nil && cfg.Skip(call) {
+				return next(ctx, call)
+			}
+
+			start := time.Now().UTC()
+
+			attrs := []attribute.KeyValue{
+				attribute.String("rpc.service", call.Service),
+				attribute.String("rpc.method", call.Method),
+				attribute.String("service.name", cfg.ServiceName),
+				attribute.String("service.version", cfg.ServiceVersion),
+			}
+			metricAttributes := attribute.NewSet(attrs...)
+
+			cfg.grpcCallsCounter.Add(ctx, 1, metric.WithAttributeSet(metricAttributes))
+			cfg.grpcActiveCallsCounter.Add(ctx, 1, metric.WithAttributeSet(metricAttributes))
+
+			// Process
+			err := next(ctx, call)
+
+			// Recording Metric
+			statusAttributes := attribute.NewSet(append(attrs,
+				attribute.String("rpc.grpc.status_code", status.Code(err).String()),
+			)...)
+
+			cfg.grpcActiveCallsCounter.Add(ctx, -1, metric.WithAttributeSet(metricAttributes))
+			cfg.grpcCallDurationHistogram.Record(ctx,
+				time.Since(start).Milliseconds(),
+				metric.WithAttributeSet(statusAttributes))
+
+			if err != nil {
+				cfg.grpcCallErrorsCounter.Add(ctx, 1, metric.WithAttributeSet(statusAttributes))
+				return err
+			}
+
+			return nil
+		}
+	}
+}
+
+func (c *MetricConfig) newCounters() {
+	m := observability.Meter(c.ServiceName)
+
+	var err error
+
+	c.grpcCallsCounter, err = m.Int64Counter(
+		"grpc_server_calls_total",
+		metric.WithDescription("Total number of gRPC calls received."),
+		metric.WithUnit("{calls}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter grpc call counter failed: %v", err)
+	}
+
+	c.grpcCallErrorsCounter, err = m.Int64Counter(
+		"grpc_server_call_errors_total",
+		metric.WithDescription("Total number of gRPC calls answered with an error."),
+		metric.WithUnit("{calls}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter grpc call error counter failed: %v", err)
+	}
+
+	c.grpcActiveCallsCounter, err = m.Int64UpDownCounter(
+		"grpc_server_active_calls_total",
+		metric.WithDescription("Number of in-flight gRPC calls."),
+		metric.WithUnit("{calls}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter grpc active call counter failed: %v", err)
+	}
+}
+
+func (c *MetricConfig) newHistograms() {
+	m := observability.Meter(c.ServiceName)
+
+	var err error
+
+	c.grpcCallDurationHistogram, err = m.Int64Histogram(
+		"grpc_server_call_duration_milliseconds",
+		metric.WithDescription("The handling duration of a gRPC call."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter grpc call duration failed: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package mygrpc
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type (
+	// Call describes the RPC being handled
+	Call struct {
+		// FullMethod is the method as /package.Service/Method
+		FullMethod string
+		Service    string
+		Method     string
+		// Stream is set for the client and server streaming methods
+		Stream bool
+	}
+
+	// Handler handles a unary or a streaming call, the request and the response stay with
+	// the handler of the method
+	Handler func(ctx context.Context, call *Call) error
+
+	// Middleware wraps a Handler, it intercepts the unary and the streaming calls alike
+	Middleware func(next Handler) Handler
+)
+
+// Chain composes the middlewares, the first one is the outermost
+func Chain(ms ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(ms) - 1; i >= 0; i-- {
+			next = ms[i](next)
+		}
+		return next
+	}
+}
+
+// UnaryInterceptor runs the middlewares around the unary calls
+func UnaryInterceptor(ms ...Middleware) grpc.UnaryServerInterceptor {
+	chain := Chain(ms...)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var resp any
+		err := chain(func(ctx context.Context, _ *Call) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})(ctx, newCall(info.FullMethod, false))
+		return resp, err
+	}
+}
+
+// StreamInterceptor runs the middlewares around the streaming calls, the stream carries
+// the context they build
+func StreamInterceptor(ms ...Middleware) grpc.StreamServerInterceptor {
+	chain := Chain(ms...)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return chain(func(ctx context.Context, _ *Call) error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		})(ss.Context(), newCall(info.FullMethod, true))
+	}
+}
+
+func newCall(fullMethod string, stream bool) *Call {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return &Call{FullMethod: fullMethod, Service: service, Method: method, Stream: stream}
+}
+
+// serverStream replaces the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+// metadataCarrier adapts metadata.MD to the otel propagators
+type metadataCarrier metadata.MD
+
+func (m metadataCarrier) Get(key string) string {
+	if values := metadata.MD(m).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+func (m metadataCarrier) Set(key, value string) { metadata.MD(m).Set(key, value) }
+func (m metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectTrace writes the trace context of ctx into the metadata of an outgoing call
+func InjectTrace(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	} else {
+		md = md.Copy()
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// ExtractTrace returns ctx carrying the trace context found in the metadata of the call
+func ExtractTrace(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+}
+
+// incoming returns the first value of key in the metadata of the call
+func incoming(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
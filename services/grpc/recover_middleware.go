@@ -0,0 +1,28 @@
+package mygrpc
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+)
+
+// RecoverMiddleware turns a panic of the handler into an internal error instead of crashing
+// the process
+func RecoverMiddleware(logger oblogger.ObLogger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					cause := fmt.Errorf("panic handling %s: %v", call.FullMethod, r)
+					logger.Error(ctx, "[GrpcMiddleware]Recover: "+string(debug.Stack()), cause)
+					err = myerrors.InternalFailure("unexpected failure while handling the call").WithCause(cause)
+				}
+			}()
+
+			return next(ctx, call)
+		}
+	}
+}
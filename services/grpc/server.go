@@ -0,0 +1,238 @@
+package mygrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/gianglt2198/platforms/middleware"
+	oblogger "github.com/gianglt2198/platforms/observability/logger"
+	mycore "github.com/gianglt2198/platforms/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+const Protocol = "grpc"
+
+type (
+	// ServerConfig holds configuration for Server
+	ServerConfig struct {
+		// Name is the name of the server in the ServerRegistry
+		Name string
+		// Version is the version of the service
+		Version string
+		// ServiceName labels the traces and the metrics and is the domain of the errors
+		ServiceName string
+		// Address is the address listened on, ":9090" by default
+		Address string
+		// IsProdEnv hides the detail of the server errors
+		IsProdEnv bool
+		// Authenticator verifies the bearer tokens of the calls, no authentication when nil
+		Authenticator *middleware.Authenticator
+		// Auth configures the authentication of the calls
+		Auth AuthConfig
+		// DisableReflection does not register the reflection service
+		DisableReflection bool
+		// Middlewares run after the built-in ones, around the handlers of the methods
+		Middlewares []Middleware
+		// Options are passed to grpc.NewServer, e.g. the credentials
+		Options []grpc.ServerOption
+	}
+
+	// Server serves the gRPC services with the recovery, request id logging, tracing,
+	// metrics, error mapping and authentication middlewares, the health and the reflection
+	// services. The services are registered before Start, e.g. pb.RegisterWidgetServer(s, impl)
+	Server struct {
+		*mycore.Lifecycle
+
+		cfg         ServerConfig
+		middlewares []Middleware
+
+		mu       sync.Mutex
+		services []service
+	}
+
+	// service is a service registered on the server
+	service struct {
+		desc *grpc.ServiceDesc
+		impl any
+	}
+
+	// runner serves a grpc.Server and its health service
+	runner struct {
+		server *grpc.Server
+		health *health.Server
+	}
+)
+
+var (
+	_ mycore.Server         = (*Server)(nil)
+	_ grpc.ServiceRegistrar = (*Server)(nil)
+)
+
+// DefaultServerConfig returns the default configuration
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Name:        "grpc",
+		Version:     "1.0.0",
+		ServiceName: "platform-app",
+		Address:     ":9090",
+		Auth:        DefaultAuthConfig(),
+	}
+}
+
+func (m ServerConfig) apply(cfg *ServerConfig) {
+	if m.Name != "" {
+		cfg.Name = m.Name
+	}
+	if m.Version != "" {
+		cfg.Version = m.Version
+	}
+	if m.ServiceName != "" {
+		cfg.ServiceName = m.ServiceName
+	}
+	if m.Address != "" {
+		cfg.Address = m.Address
+	}
+	if m.IsProdEnv {
+		cfg.IsProdEnv = true
+	}
+	if m.Authenticator != nil {
+		cfg.Authenticator = m.Authenticator
+	}
+	m.Auth.apply(&cfg.Auth)
+	if m.DisableReflection {
+		cfg.DisableReflection = true
+	}
+	if len(m.Middlewares) > 0 {
+		cfg.Middlewares = append(cfg.Middlewares, m.Middlewares...)
+	}
+	if len(m.Options) > 0 {
+		cfg.Options = append(cfg.Options, m.Options...)
+	}
+}
+
+// New returns a stopped server
+func New(logger oblogger.ObLogger, configs ...ServerConfig) *Server {
+	cfg := DefaultServerConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	// The error middleware sits inside the observability ones so they see the status codes,
+	// the errors of the recovery and the authentication are mapped like the others
+	ms := []Middleware{
+		LoggerMiddleware(logger),
+		TracingMiddleware(TracingConfig{
+			ServiceName:    cfg.ServiceName,
+			ServiceVersion: cfg.Version,
+		}),
+		MetricMiddleware(MetricConfig{
+			ServiceName:    cfg.ServiceName,
+			ServiceVersion: cfg.Version,
+		}),
+		ErrorMiddleware(ErrorConfig{
+			Domain:    cfg.ServiceName,
+			IsProdEnv: cfg.IsProdEnv,
+		}),
+		RecoverMiddleware(logger),
+	}
+	if cfg.Authenticator != nil {
+		ms = append(ms, AuthMiddleware(cfg.Authenticator, cfg.Auth))
+	}
+	ms = append(ms, cfg.Middlewares...)
+
+	s := &Server{
+		cfg:         cfg,
+		middlewares: ms,
+	}
+	s.Lifecycle = mycore.NewLifecycle(mycore.LifecycleConfig{
+		Name:     cfg.Name,
+		Protocol: Protocol,
+		Version:  cfg.Version,
+		Address:  cfg.Address,
+		Logger:   logger.GetLogger(),
+		Build:    s.build,
+		Metadata: s.metadata,
+	})
+	return s
+}
+
+// RegisterService registers a service, it is served from the next Start
+func (s *Server) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.services = append(s.services, service{desc: desc, impl: impl})
+}
+
+// metadata lists the registered services in the health status
+func (s *Server) metadata() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	services := make([]string, 0, len(s.services))
+	for _, svc := range s.services {
+		services = append(services, svc.desc.ServiceName)
+	}
+	return map[string]any{"services": services}
+}
+
+// build returns a grpc.Server serving the registered services, the health service reports
+// each of them and the server as a whole ("") serving
+func (s *Server) build() mycore.Runner {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	opts := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(UnaryInterceptor(s.middlewares...)),
+		grpc.ChainStreamInterceptor(StreamInterceptor(s.middlewares...)),
+	}, s.cfg.Options...)
+	server := grpc.NewServer(opts...)
+
+	healthServer := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+
+	for _, svc := range s.services {
+		server.RegisterService(svc.desc, svc.impl)
+		healthServer.SetServingStatus(svc.desc.ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	}
+	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	if !s.cfg.DisableReflection {
+		reflection.Register(server)
+	}
+
+	return &runner{server: server, health: healthServer}
+}
+
+func (r *runner) Serve(listener net.Listener) error {
+	if err := r.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown reports the services as not serving and waits for the calls in flight, the ones
+// still running when ctx is done are cancelled
+func (r *runner) Shutdown(ctx context.Context) error {
+	r.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.server.GracefulStop()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		r.server.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
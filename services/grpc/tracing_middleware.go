@@ -0,0 +1,96 @@
+package mygrpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gianglt2198/platforms/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+// TracingConfig holds configuration for the tracing middleware
+type TracingConfig struct {
+	// ServiceName is the name of the service being traced
+	ServiceName string
+	// ServiceVersion is the version of the service
+	ServiceVersion string
+	// Skip defines a function to skip the middleware
+	Skip func(call *Call) bool
+}
+
+// DefaultTracingConfig returns the default configuration
+func DefaultTracingConfig() TracingConfig {
+	return TracingConfig{
+		ServiceName:    "platform-app",
+		ServiceVersion: "1.0.0",
+		Skip:           isInfrastructure,
+	}
+}
+
+func (m TracingConfig) apply(cfg *TracingConfig) {
+	if m.ServiceName != "" {
+		cfg.ServiceName = m.ServiceName
+	}
+	if m.ServiceVersion != "" {
+		cfg.ServiceVersion = m.ServiceVersion
+	}
+	if m.Skip != nil {
+		cfg.Skip = m.Skip
+	}
+}
+
+// TracingMiddleware continues the trace propagated in the metadata of the call with a
+// server span
+func TracingMiddleware(configs ...TracingConfig) Middleware {
+	cfg := DefaultTracingConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			if cfg.Skip != nil && cfg.Skip(call) {
+				return next(ctx, call)
+			}
+
+			ctx = ExtractTrace(ctx)
+			tracingCtx, span := observability.Tracer(call.Service).Start(ctx, "grpc "+call.FullMethod,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("rpc.system", "grpc"),
+					attribute.String("rpc.service", call.Service),
+					attribute.String("rpc.method", call.Method),
+					attribute.Bool("rpc.stream", call.Stream),
+					attribute.String("rpc.request_id", RequestID(ctx)),
+					attribute.String("service.name", cfg.ServiceName),
+					attribute.String("service.version", cfg.ServiceVersion),
+				),
+			)
+			defer span.End()
+
+			start := time.Now()
+			err := next(tracingCtx, call)
+
+			span.SetAttributes(
+				attribute.Int("rpc.grpc.status_code", int(status.Code(err))),
+				attribute.Float64("rpc.duration_ms", float64(time.Since(start).Milliseconds())),
+			)
+
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+				span.RecordError(err, trace.WithAttributes(
+					attribute.String("error.type", fmt.Sprintf("%T", err)),
+					attribute.String("error.message", err.Error()),
+				))
+				return err
+			}
+
+			span.SetStatus(codes.Ok, "call handled")
+			return nil
+		}
+	}
+}
@@ -1,15 +1,23 @@
 package app
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/ansrivas/fiberprometheus/v2"
 	oblogger "github.com/gianglt2198/platforms/observability/logger"
 	"github.com/gianglt2198/platforms/services/rest/config"
 	"github.com/gianglt2198/platforms/services/rest/middlewares"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
 	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/go-openapi/runtime/middleware"
 	"github.com/gofiber/contrib/swagger"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"gorm.io/gorm"
@@ -29,9 +37,29 @@ type Handler interface {
 
 func New(cfg *config.Config, db *gorm.DB, logger oblogger.ObLogger) *App {
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ErrorHandler: routes.ErrorHandler(routes.ErrorHandlerConfig{
+			IsProdEnv: cfg.IsProdEnv,
+		}),
+	})
 
-	app.Use(recover.New())
+	app.Use(recover.New(recover.Config{
+		EnableStackTrace: !cfg.IsProdEnv,
+	}))
+	if level := cfg.App.Compression; level != nil && *level != int(compress.LevelDisabled) {
+		app.Use(compress.New(compress.Config{
+			// The event streams are flushed event by event, compressing would buffer them, and
+			// the scrapes and the probes are small and frequent
+			Next: func(c *fiber.Ctx) bool {
+				switch c.Path() {
+				case "/metrics", "/health":
+					return true
+				}
+				return strings.Contains(c.Get(fiber.HeaderAccept), "text/event-stream")
+			},
+			Level: compress.Level(*level),
+		}))
+	}
 	app.Use(cors.New())
 	app.Use(middlewares.RequestIDMiddleware)
 	app.Use(middlewares.TracingMiddleware("main", "request_caller",
@@ -47,7 +75,7 @@ func New(cfg *config.Config, db *gorm.DB, logger oblogger.ObLogger) *App {
 	prometheus := fiberprometheus.New(cfg.App.Name)
 	prometheus.RegisterAt(app, "/metrics")
 	prometheus.SetSkipPaths([]string{
-		"/metrics", "/health", "/swagger",
+		"/metrics", "/health", "/swagger", "/openapi.json", "/docs",
 	})
 
 	app.Get("/health", HealthCheck)
@@ -69,9 +97,7 @@ func (a *App) RegisterHandlers(handlers ...Handler) {
 	}
 
 	a.app.Use(func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusNotFound).JSON(
-			routes.ErrorResponse(fiber.ErrBadGateway),
-		)
+		return fiber.NewError(fiber.StatusNotFound, "Cannot "+c.Method()+" "+c.Path())
 	})
 }
 
@@ -82,6 +108,45 @@ func (a *App) RegisterSwagger(fileContent []byte) {
 	}))
 }
 
+// RegisterOpenAPI serves the OpenAPI document of the routes built with routes.Usecase at
+// /openapi.json and its swagger UI at /docs. It must be called before RegisterHandlers,
+// whose fallback answers the routes registered after it, and the document is generated on
+// the first request once all the handlers are registered
+func (a *App) RegisterOpenAPI(configs ...openapi.Config) {
+	var (
+		once    sync.Once
+		content []byte
+		err     error
+	)
+
+	a.app.Get("/openapi.json", func(c *fiber.Ctx) error {
+		once.Do(func() {
+			content, err = json.Marshal(a.OpenAPI(configs...))
+		})
+		if err != nil {
+			return err
+		}
+
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(content)
+	})
+
+	a.app.Get("/docs", adaptor.HTTPHandler(middleware.SwaggerUI(middleware.SwaggerUIOpts{
+		SpecURL: "/openapi.json",
+		Title:   a.cfg.App.Name,
+	}, http.NotFoundHandler())))
+}
+
+// OpenAPI generates the OpenAPI document of the routes built with routes.Usecase
+func (a *App) OpenAPI(configs ...openapi.Config) *openapi.Document {
+	configs = append([]openapi.Config{{
+		Title:   a.cfg.App.Name,
+		Problem: routes.Problem{},
+	}}, configs...)
+
+	return openapi.Generate(a.app, configs...)
+}
+
 func (a *App) Start() error {
 	return a.app.Listen(fmt.Sprintf(":%d", a.cfg.App.Port))
 }
@@ -32,6 +32,11 @@ func NewError(svcErr *fiber.Error, appErr error) error {
 	}
 }
 
+// Unwrap exposes both errors to errors.Is and errors.As
+func (e AError) Unwrap() []error {
+	return []error{e.svcError, e.appError}
+}
+
 func (e AError) Error() string {
 	return errors.Join(e.svcError, e.appError).Error()
 }
@@ -20,6 +20,10 @@ type AppConfig struct {
 	Name string `mapstructure:"name"`
 	Env  string `mapstructure:"env"`
 	Port int    `mapstructure:"port"`
+	// Compression enables the gzip, deflate or brotli responses negotiated by
+	// Accept-Encoding at the level: 0 default, 1 best speed, 2 best compression. The
+	// responses are not compressed when it is absent or -1
+	Compression *int `mapstructure:"compression"`
 }
 
 type DatabaseConfig struct {
@@ -3,36 +3,35 @@ package middlewares
 import (
 	"encoding/json"
 
+	myerrors "github.com/gianglt2198/platforms/errors"
 	"github.com/gianglt2198/platforms/services/rest/routes"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 )
 
+// AllPayloadValidator binds the request to T, see routes.Bind for the precedence of the
+// sources, then checks it with routes.ValidateRequest
 func AllPayloadValidator[T any]() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-
 		var data T
-		var err error
-
-		_ = c.BodyParser(&data)
-
-		_ = c.ParamsParser(&data)
-
-		_ = c.QueryParser(&data)
 
-		err = routes.ValidateStruct(&data)
+		if err := routes.Bind(c, &data); err != nil {
+			return err
+		}
 
-		if err != nil {
+		if err := routes.ValidateRequest(c.UserContext(), &data); err != nil {
 			return err
 		}
 
 		c.Locals(routes.KEY_REQ_ALL_PARAMS, data)
 
-		return c.Next()
+		return routes.Next(c)
 	}
 }
 
-func ParseValidationError(err error) string {
+// ParseValidationError returns the failed tag of each field as JSON, or the message of the
+// tag in the catalog when a locale is given
+func ParseValidationError(err error, locale ...string) string {
 	if err == nil {
 		return ""
 	}
@@ -40,6 +39,10 @@ func ParseValidationError(err error) string {
 	errors := make(map[string]interface{})
 
 	for _, err := range err.(validator.ValidationErrors) {
+		if len(locale) > 0 {
+			errors[err.Field()] = myerrors.DefaultCatalog().FieldMessage(locale[0], err.Tag(), err.Field(), err.Param())
+			continue
+		}
 		errors[err.Field()] = err.Tag()
 	}
 
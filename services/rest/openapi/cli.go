@@ -0,0 +1,49 @@
+package openapi
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+)
+
+// WriteJSON writes the document as indented JSON
+func (d *Document) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// Dump is the command writing the document of a service, for contract tests and client
+// generation. It takes -o to write to a file rather than to stdout:
+//
+//	func main() {
+//		if len(os.Args) > 1 && os.Args[1] == "openapi" {
+//			if err := openapi.Dump(os.Args[2:], a.OpenAPI); err != nil {
+//				log.Fatal(err)
+//			}
+//			return
+//		}
+//		...
+//	}
+func Dump(args []string, generate func() *Document) error {
+	flags := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	output := flags.String("o", "", "file the document is written to, stdout when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return generate().WriteJSON(os.Stdout)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := generate().WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
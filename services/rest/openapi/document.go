@@ -0,0 +1,151 @@
+package openapi
+
+import "encoding/json"
+
+const Version = "3.1.0"
+
+type (
+	// Document is an OpenAPI 3.1 document, only the parts generated from the routes are modeled
+	Document struct {
+		OpenAPI    string                           `json:"openapi"`
+		Info       Info                             `json:"info"`
+		Servers    []Server                         `json:"servers,omitempty"`
+		Paths      map[string]map[string]*Operation `json:"paths"`
+		Components Components                       `json:"components"`
+	}
+
+	Info struct {
+		Title       string `json:"title"`
+		Version     string `json:"version"`
+		Description string `json:"description,omitempty"`
+	}
+
+	Server struct {
+		URL         string `json:"url"`
+		Description string `json:"description,omitempty"`
+	}
+
+	Components struct {
+		Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+		SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+	}
+
+	SecurityScheme struct {
+		Type         string `json:"type"`
+		Scheme       string `json:"scheme,omitempty"`
+		BearerFormat string `json:"bearerFormat,omitempty"`
+		Name         string `json:"name,omitempty"`
+		In           string `json:"in,omitempty"`
+	}
+
+	Operation struct {
+		OperationID string                `json:"operationId,omitempty"`
+		Summary     string                `json:"summary,omitempty"`
+		Description string                `json:"description,omitempty"`
+		Tags        []string              `json:"tags,omitempty"`
+		Parameters  []*Parameter          `json:"parameters,omitempty"`
+		RequestBody *RequestBody          `json:"requestBody,omitempty"`
+		Responses   map[string]*Response  `json:"responses"`
+		Security    []map[string][]string `json:"security,omitempty"`
+		Deprecated  bool                  `json:"deprecated,omitempty"`
+		// Extensions are the x- fields of the operation
+		Extensions map[string]any `json:"-"`
+	}
+
+	Parameter struct {
+		Name        string  `json:"name"`
+		In          string  `json:"in"`
+		Description string  `json:"description,omitempty"`
+		Required    bool    `json:"required,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	RequestBody struct {
+		Required bool                  `json:"required,omitempty"`
+		Content  map[string]*MediaType `json:"content"`
+	}
+
+	Response struct {
+		Description string                `json:"description"`
+		Headers     map[string]*Header    `json:"headers,omitempty"`
+		Content     map[string]*MediaType `json:"content,omitempty"`
+	}
+
+	Header struct {
+		Description string  `json:"description,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	MediaType struct {
+		Schema *Schema `json:"schema"`
+	}
+
+	Schema struct {
+		Ref                  string             `json:"$ref,omitempty"`
+		Type                 any                `json:"type,omitempty"`
+		Format               string             `json:"format,omitempty"`
+		Description          string             `json:"description,omitempty"`
+		Properties           map[string]*Schema `json:"properties,omitempty"`
+		Required             []string           `json:"required,omitempty"`
+		Items                *Schema            `json:"items,omitempty"`
+		AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+		Enum                 []any              `json:"enum,omitempty"`
+		Pattern              string             `json:"pattern,omitempty"`
+		Minimum              *float64           `json:"minimum,omitempty"`
+		Maximum              *float64           `json:"maximum,omitempty"`
+		ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+		ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+		MinLength            *int               `json:"minLength,omitempty"`
+		MaxLength            *int               `json:"maxLength,omitempty"`
+		MinItems             *int               `json:"minItems,omitempty"`
+		MaxItems             *int               `json:"maxItems,omitempty"`
+	}
+)
+
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	type operation Operation
+	content, err := json.Marshal((*operation)(o))
+	if err != nil || len(o.Extensions) == 0 {
+		return content, err
+	}
+
+	fields := map[string]any{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range o.Extensions {
+		fields[k] = v
+	}
+	return json.Marshal(fields)
+}
+
+// SetExtension sets an x- field of the operation
+func (o *Operation) SetExtension(name string, value any) {
+	if o.Extensions == nil {
+		o.Extensions = map[string]any{}
+	}
+	o.Extensions[name] = value
+}
+
+// AddResponse sets the response of a status unless the operation already has one
+func (o *Operation) AddResponse(status string, response *Response) {
+	if o.Responses == nil {
+		o.Responses = map[string]*Response{}
+	}
+	if _, ok := o.Responses[status]; !ok {
+		o.Responses[status] = response
+	}
+}
+
+// AddSecurity requires the security scheme for the operation
+func (o *Operation) AddSecurity(scheme string, scopes ...string) {
+	for _, s := range o.Security {
+		if _, ok := s[scheme]; ok {
+			return
+		}
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+	o.Security = append(o.Security, map[string][]string{scheme: scopes})
+}
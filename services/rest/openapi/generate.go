@@ -0,0 +1,336 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeProblem = "application/problem+json"
+	ContentTypeMsgpack = "application/msgpack"
+	ContentTypeCSV     = "text/csv"
+
+	// SecurityBearer is the scheme of the bearer tokens checked by middleware.Authentication
+	SecurityBearer = "bearerAuth"
+
+	problemSchema = "Problem"
+)
+
+// Config holds configuration for the generated document
+type Config struct {
+	Title       string
+	Version     string
+	Description string
+	Servers     []Server
+	// Problem is the error body of the API, e.g. routes.Problem{}
+	Problem any
+	// SecuritySchemes are the schemes the operations may require, bearerAuth is a JWT
+	// bearer token by default. Only the schemes in use are documented
+	SecuritySchemes map[string]*SecurityScheme
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		Title:   "API",
+		Version: "1.0.0",
+		SecuritySchemes: map[string]*SecurityScheme{
+			SecurityBearer: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		},
+	}
+}
+
+func (m Config) apply(cfg *Config) {
+	if m.Title != "" {
+		cfg.Title = m.Title
+	}
+	if m.Version != "" {
+		cfg.Version = m.Version
+	}
+	if m.Description != "" {
+		cfg.Description = m.Description
+	}
+	if len(m.Servers) > 0 {
+		cfg.Servers = m.Servers
+	}
+	if m.Problem != nil {
+		cfg.Problem = m.Problem
+	}
+	for name, scheme := range m.SecuritySchemes {
+		cfg.SecuritySchemes[name] = scheme
+	}
+}
+
+// Generate documents the routes of app built with routes.Usecase. The other routes, like
+// the health check, are left out
+func Generate(app *fiber.App, configs ...Config) *Document {
+	cfg := DefaultConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	s := newSchemas()
+	if cfg.Problem != nil {
+		s.components[problemSchema] = s.object(reflect.TypeOf(cfg.Problem), nil)
+	} else {
+		s.components[problemSchema] = &Schema{Type: "object"}
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: cfg.Title, Version: cfg.Version, Description: cfg.Description},
+		Servers: cfg.Servers,
+		Paths:   map[string]map[string]*Operation{},
+	}
+
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead {
+			continue
+		}
+
+		op := operation(s, route)
+		if op == nil {
+			continue
+		}
+
+		path := openAPIPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*Operation{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+
+		for _, requirement := range op.Security {
+			for name := range requirement {
+				if scheme, ok := cfg.SecuritySchemes[name]; ok {
+					if doc.Components.SecuritySchemes == nil {
+						doc.Components.SecuritySchemes = map[string]*SecurityScheme{}
+					}
+					doc.Components.SecuritySchemes[name] = scheme
+				}
+			}
+		}
+	}
+
+	doc.Components.Schemas = s.components
+	return doc
+}
+
+// ProblemResponse is an error response of the operation
+func ProblemResponse(description string) *Response {
+	return &Response{
+		Description: description,
+		Content: map[string]*MediaType{
+			ContentTypeProblem: {Schema: ref(problemSchema)},
+		},
+	}
+}
+
+func operation(s *schemas, route fiber.Route) *Operation {
+	var (
+		usecase Usecase
+		found   bool
+	)
+	for _, h := range route.Handlers {
+		if usecase, found = lookupUsecase(h); found {
+			break
+		}
+	}
+	// The handlers not built by routes.Usecase are documented by their annotations, like the
+	// streams
+	if !found && (len(route.Handlers) == 0 || len(lookupAnnotations(route.Handlers[len(route.Handlers)-1])) == 0) {
+		return nil
+	}
+
+	op := &Operation{
+		OperationID: operationID(route.Method, route.Path),
+		Responses:   map[string]*Response{},
+	}
+
+	request := usecase.Request
+	for request != nil && request.Kind() == reflect.Pointer {
+		request = request.Elem()
+	}
+	if request != nil && request.Kind() == reflect.Struct {
+		op.Parameters = parameters(s, request, route.Params)
+		if hasBody(route.Method) {
+			body := s.object(request, func(f reflect.StructField) bool { return !isParameter(f) })
+			if len(body.Properties) > 0 {
+				op.RequestBody = &RequestBody{
+					Required: len(body.Required) > 0,
+					Content:  map[string]*MediaType{ContentTypeJSON: {Schema: body}},
+				}
+			}
+		}
+	}
+	for _, name := range route.Params {
+		if !hasParameter(op.Parameters, name, "path") {
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	if found {
+		status := usecase.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &Response{Description: http.StatusText(status)}
+		if status != http.StatusNoContent {
+			body := &Schema{}
+			if usecase.Response != nil && usecase.Response.Kind() != reflect.Interface {
+				body = s.of(usecase.Response)
+			}
+			contentTypes := usecase.ContentTypes
+			if len(contentTypes) == 0 {
+				contentTypes = []string{ContentTypeJSON}
+			}
+			success.Content = map[string]*MediaType{}
+			for _, contentType := range contentTypes {
+				if contentType == ContentTypeCSV {
+					// The items of the list, one row each under a header of their fields
+					success.Content[contentType] = &MediaType{Schema: &Schema{Type: "string"}}
+					continue
+				}
+				success.Content[contentType] = &MediaType{Schema: body}
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+	}
+
+	if len(op.Parameters) > 0 || op.RequestBody != nil {
+		op.AddResponse("400", ProblemResponse(http.StatusText(http.StatusBadRequest)))
+	}
+
+	// The middlewares of the route and the ones run by the use case document themselves
+	for _, h := range append(append([]fiber.Handler{}, route.Handlers...), usecase.Middlewares...) {
+		for _, opt := range lookupAnnotations(h) {
+			opt(op)
+		}
+	}
+
+	op.AddResponse("default", ProblemResponse("Error"))
+	return op
+}
+
+func parameters(s *schemas, t reflect.Type, pathParams []string) []*Parameter {
+	var params []*Parameter
+
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				walk(f.Type)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+
+			for _, source := range []struct{ tag, in string }{
+				{queryTag, "query"}, {headerTag, "header"}, {pathTag, "path"}, {paramsTag, "path"},
+			} {
+				name, _, _ := strings.Cut(f.Tag.Get(source.tag), ",")
+				if name == "" || name == "-" || hasParameter(params, name, source.in) {
+					continue
+				}
+				if source.in == "path" && !contains(pathParams, name) {
+					continue
+				}
+
+				schema := s.of(f.Type)
+				required := applyRules(schema, f.Type, f.Tag.Get("validate"))
+				params = append(params, &Parameter{
+					Name:     name,
+					In:       source.in,
+					Required: required || source.in == "path",
+					Schema:   schema,
+				})
+			}
+		}
+	}
+	walk(t)
+
+	sort.SliceStable(params, func(i, j int) bool {
+		return parameterOrder(params[i].In) < parameterOrder(params[j].In)
+	})
+	return params
+}
+
+func parameterOrder(in string) int {
+	switch in {
+	case "path":
+		return 0
+	case "query":
+		return 1
+	}
+	return 2
+}
+
+func hasParameter(params []*Parameter, name, in string) bool {
+	for _, p := range params {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasBody(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// openAPIPath converts the parameters of a fiber path, /jobs/:id<int> is /jobs/{id}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+		name := strings.TrimPrefix(segment, ":")
+		if j := strings.IndexAny(name, "<?"); j >= 0 {
+			name = name[:j]
+		}
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/")
+}
+
+// operationID derives an id from the method and the path, GET /api/jobs/:id is getApiJobsId
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+
+	upper := true
+	for _, r := range openAPIPath(path) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package openapi
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	// Option documents an operation
+	Option func(op *Operation)
+
+	// Usecase is what routes.Usecase knows of a handler
+	Usecase struct {
+		Request reflect.Type
+		// Response is the type of the body, its envelope included
+		Response reflect.Type
+		// ContentTypes are the media types the response is negotiated to, JSON by default
+		ContentTypes []string
+		Status       int
+		Middlewares  []fiber.Handler
+	}
+
+	// recorded is what the registry knows of a handler, the handler is kept so its closure,
+	// whose address is the key, is never collected and its address never reused by another
+	recorded struct {
+		handler     fiber.Handler
+		usecase     *Usecase
+		annotations []Option
+	}
+)
+
+var (
+	registryMu sync.RWMutex
+	// registry holds the handlers built when the routes are set up, the handlers are not
+	// meant to be built per request as they are kept for the life of the process
+	registry = map[uintptr]*recorded{}
+)
+
+// handlerKey identifies a handler. A func value points to its closure, so the key is unique
+// per handler built by routes.Usecase, whose closure captures its use case, unlike the
+// address of its code shared by all the closures of a function
+func handlerKey(h fiber.Handler) uintptr {
+	return *(*uintptr)(unsafe.Pointer(&h))
+}
+
+// record returns the entry of h, created with h pinned, registryMu must be held
+func record(h fiber.Handler) *recorded {
+	key := handlerKey(h)
+	r, ok := registry[key]
+	if !ok {
+		r = &recorded{handler: h}
+		registry[key] = r
+	}
+	return r
+}
+
+// Record registers the handler built by routes.Usecase
+func Record(h fiber.Handler, u Usecase) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	record(h).usecase = &u
+}
+
+// Annotate returns h with options applied to the operations using it as a middleware. The
+// middlewares document this way what they add to the routes, like a security requirement
+// or a 429 response
+func Annotate(h fiber.Handler, opts ...Option) fiber.Handler {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if r, ok := registry[handlerKey(h)]; ok && r.annotations != nil {
+		r.annotations = append(r.annotations, opts...)
+		return h
+	}
+
+	// A closure capturing nothing is allocated once for all the calls of its function, the
+	// wrapper capturing h gives each annotated handler its own key
+	annotated := func(c *fiber.Ctx) error {
+		return h(c)
+	}
+	record(annotated).annotations = append([]Option{}, opts...)
+	return annotated
+}
+
+func lookupUsecase(h fiber.Handler) (Usecase, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if r, ok := registry[handlerKey(h)]; ok && r.usecase != nil {
+		return *r.usecase, true
+	}
+	return Usecase{}, false
+}
+
+func lookupAnnotations(h fiber.Handler) []Option {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if r, ok := registry[handlerKey(h)]; ok {
+		return r.annotations
+	}
+	return nil
+}
+
+// Summary sets the summary of the operation
+func Summary(summary string) Option {
+	return func(op *Operation) { op.Summary = summary }
+}
+
+// Description sets the description of the operation
+func Description(description string) Option {
+	return func(op *Operation) { op.Description = description }
+}
+
+// Tags adds tags grouping the operation
+func Tags(tags ...string) Option {
+	return func(op *Operation) { op.Tags = append(op.Tags, tags...) }
+}
+
+// OperationID sets the id of the operation, derived from its method and path by default
+func OperationID(id string) Option {
+	return func(op *Operation) { op.OperationID = id }
+}
+
+// Deprecated marks the operation as deprecated
+func Deprecated() Option {
+	return func(op *Operation) { op.Deprecated = true }
+}
+
+// QueryParam documents a query parameter of type t read by the handler outside of its
+// request struct
+func QueryParam(name string, t reflect.Type, description string) Option {
+	return func(op *Operation) {
+		if hasParameter(op.Parameters, name, "query") {
+			return
+		}
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:        name,
+			In:          "query",
+			Description: description,
+			Schema:      newSchemas().of(t),
+		})
+	}
+}
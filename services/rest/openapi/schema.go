@@ -0,0 +1,290 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pathTag   = "path"
+	paramsTag = "params"
+	queryTag  = "query"
+	headerTag = "header"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	schemaNameSanitizer = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// schemas builds the schemas of the types, the named structs become components
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// of returns the schema of t, a reference for the named structs
+func (s *schemas) of(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// The shape of a custom encoding is unknown
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t, nil)
+		}
+		return ref(s.component(t))
+	}
+	return &Schema{}
+}
+
+// component registers the schema of a named struct and returns its name
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+
+	name := schemaName(t)
+	if _, taken := s.components[name]; taken {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+
+	// Registered before building so recursive types refer to it
+	s.names[t] = name
+	s.components[name] = &Schema{}
+	*s.components[name] = *s.object(t, nil)
+	return name
+}
+
+// object returns the schema of the json fields of a struct accepted by keep
+func (s *schemas) object(t reflect.Type, keep func(f reflect.StructField) bool) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.fields(schema, t, keep)
+	return schema
+}
+
+func (s *schemas) fields(schema *Schema, t reflect.Type, keep func(f reflect.StructField) bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(schema, ft, keep)
+				continue
+			}
+		}
+		if !f.IsExported() || name == "-" || (keep != nil && !keep(f)) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		property := s.of(f.Type)
+		if strings.Contains(opts, "string") {
+			property = &Schema{Type: "string"}
+		}
+		if applyRules(property, f.Type, f.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// schemaName names the component of t, the type arguments of a generic type are reduced to
+// their names: Page[github.com/x/y.Job] is Page_Job
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	base, args, generic := strings.Cut(name, "[")
+	if !generic {
+		return name
+	}
+
+	var parts []string
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		list := strings.HasPrefix(arg, "[]")
+		arg = arg[strings.LastIndex(arg, ".")+1:]
+		if list {
+			// Envelope[[]Job] is not Envelope[Job]
+			arg = "List" + arg
+		}
+		parts = append(parts, schemaNameSanitizer.ReplaceAllString(arg, ""))
+	}
+	return base + "_" + strings.Join(parts, "_")
+}
+
+// applyRules translates the validate tag to constraints of the schema and reports whether
+// the field is required, the rules after dive apply to the items
+func applyRules(schema *Schema, t reflect.Type, tag string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "":
+		case "required":
+			required = true
+		case "dive":
+			if schema.Items != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				applyRules(schema.Items, t.Elem(), strings.Join(rules[i+1:], ","))
+			}
+			return required
+		case "min", "gte":
+			setBound(schema, t, param, false, false)
+		case "max", "lte":
+			setBound(schema, t, param, true, false)
+		case "gt":
+			setBound(schema, t, param, false, true)
+		case "lt":
+			setBound(schema, t, param, true, true)
+		case "len":
+			setBound(schema, t, param, false, false)
+			setBound(schema, t, param, true, false)
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && schema.Type != "string" {
+					schema.Enum = append(schema.Enum, n)
+				} else {
+					schema.Enum = append(schema.Enum, v)
+				}
+			}
+		case "email":
+			schema.Format = "email"
+		case "uuid", "uuid4", "uuid_rfc4122":
+			schema.Format = "uuid"
+		case "url", "uri", "http_url":
+			schema.Format = "uri"
+		case "ipv4":
+			schema.Format = "ipv4"
+		case "ipv6":
+			schema.Format = "ipv6"
+		case "e164":
+			schema.Pattern = `^\+[1-9][0-9]{7,14}$`
+		case "phone":
+			schema.Pattern = `^\+?[0-9 .()-]{7,}$`
+		case "numeric", "number":
+			schema.Pattern = `^[0-9]+$`
+		case "alpha":
+			schema.Pattern = `^[A-Za-z]+$`
+		case "alphanum":
+			schema.Pattern = `^[A-Za-z0-9]+$`
+		}
+	}
+	return required
+}
+
+// setBound sets the bound of a number, the length of a string or the size of an array
+func setBound(schema *Schema, t reflect.Type, param string, upper, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil || schema.Ref != "" || t == durationType {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		size := int(n)
+		if exclusive && upper {
+			size--
+		} else if exclusive {
+			size++
+		}
+
+		switch {
+		case t.Kind() == reflect.String && upper:
+			schema.MaxLength = &size
+		case t.Kind() == reflect.String:
+			schema.MinLength = &size
+		case upper:
+			schema.MaxItems = &size
+		default:
+			schema.MinItems = &size
+		}
+	default:
+		switch {
+		case upper && exclusive:
+			schema.ExclusiveMaximum = &n
+		case upper:
+			schema.Maximum = &n
+		case exclusive:
+			schema.ExclusiveMinimum = &n
+		default:
+			schema.Minimum = &n
+		}
+	}
+}
+
+// isParameter reports whether the binder fills the field from the query, the headers or
+// the path rather than from the body
+func isParameter(f reflect.StructField) bool {
+	for _, tag := range []string{pathTag, paramsTag, queryTag, headerTag} {
+		if name, _, _ := strings.Cut(f.Tag.Get(tag), ","); name != "" && name != "-" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,553 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	mydatabase "github.com/gianglt2198/platforms/database"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/query"
+	"github.com/gianglt2198/platforms/services/rest/middlewares"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gianglt2198/platforms/services/rest/routes"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	KEY_RESOURCE_ID = "resource_id"
+
+	ActionList   Action = "list"
+	ActionGet    Action = "get"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+type (
+	// Action is a route of a Resource
+	Action string
+
+	// ResourceConfig holds configuration for a Resource of T created from C and updated
+	// from U
+	ResourceConfig[T any, C any, U any] struct {
+		// Actions are the routes registered, all of them by default
+		Actions []Action
+		// Tag groups the operations in the OpenAPI document, the path by default
+		Tag string
+		// Fields are the fields the lists may filter, sort and select, see query.Parser
+		Fields []query.Field
+		// DefaultSort is the sort of the lists without one, -id by default
+		DefaultSort string
+		// DefaultPageSize and MaxPageSize bound page[size], 20 and 100 by default
+		DefaultPageSize int
+		MaxPageSize     int
+		// Preload are the relations loaded with the entities
+		Preload []string
+		// Middlewares run before each route, like the guards. routes.Usecase runs them so
+		// they must call routes.Next, mount middleware.Authentication on the router instead
+		Middlewares []fiber.Handler
+		// Scope restricts the entities reachable by the request, e.g. to its tenant
+		Scope func(ctx context.Context) (mydatabase.WhereOption, error)
+		// Authorize is called before each action with the entity it acts on, nil for the
+		// lists and the entity about to be inserted for the creations
+		Authorize func(ctx context.Context, action Action, entity *T) error
+		// ToEntity maps the creation DTO to the entity, by copying the fields of the same
+		// json name by default
+		ToEntity func(ctx context.Context, dto C) (*T, error)
+		// ToUpdate maps the update DTO to the columns to update, by default the fields of U
+		// matching a field of T by name, which must be pointers, slices or maps, the nil ones
+		// are left unchanged
+		ToUpdate func(ctx context.Context, dto U) (map[string]any, error)
+	}
+
+	// Resource is a handler exposing the REST routes of a repository:
+	//
+	//	GET    /path      list, filtered, sorted and paginated
+	//	GET    /path/:id  get
+	//	POST   /path      create from C
+	//	PATCH  /path/:id  update the fields set in U
+	//	DELETE /path/:id  delete, softly when T has a DeletedAt field
+	Resource[T any, C any, U any] struct {
+		path    string
+		repo    mydatabase.RepositoryIf[T]
+		cfg     ResourceConfig[T, C, U]
+		query   *query.Parser
+		columns map[string]*schema.Field
+		updates []updateField
+	}
+
+	// ListRequest is the query of a list parsed by query.Parser
+	ListRequest struct {
+		option *mydatabase.FindOption
+	}
+
+	// IDRequest identifies an entity
+	IDRequest struct {
+		ID int `params:"id" validate:"required,min=1"`
+	}
+
+	// updateField is a field of U mapped to a column of T
+	updateField struct {
+		index  []int
+		column string
+	}
+)
+
+var schemaCache = &sync.Map{}
+
+// DefaultResourceConfig returns the default configuration
+func DefaultResourceConfig[T any, C any, U any]() ResourceConfig[T, C, U] {
+	return ResourceConfig[T, C, U]{
+		Actions:         []Action{ActionList, ActionGet, ActionCreate, ActionUpdate, ActionDelete},
+		DefaultSort:     "-id",
+		DefaultPageSize: 20,
+		MaxPageSize:     100,
+	}
+}
+
+func (m ResourceConfig[T, C, U]) apply(cfg *ResourceConfig[T, C, U]) {
+	if len(m.Actions) > 0 {
+		cfg.Actions = m.Actions
+	}
+	if m.Tag != "" {
+		cfg.Tag = m.Tag
+	}
+	if len(m.Fields) > 0 {
+		cfg.Fields = m.Fields
+	}
+	if m.DefaultSort != "" {
+		cfg.DefaultSort = m.DefaultSort
+	}
+	if m.DefaultPageSize > 0 {
+		cfg.DefaultPageSize = m.DefaultPageSize
+	}
+	if m.MaxPageSize > 0 {
+		cfg.MaxPageSize = m.MaxPageSize
+	}
+	if len(m.Preload) > 0 {
+		cfg.Preload = m.Preload
+	}
+	if len(m.Middlewares) > 0 {
+		cfg.Middlewares = m.Middlewares
+	}
+	if m.Scope != nil {
+		cfg.Scope = m.Scope
+	}
+	if m.Authorize != nil {
+		cfg.Authorize = m.Authorize
+	}
+	if m.ToEntity != nil {
+		cfg.ToEntity = m.ToEntity
+	}
+	if m.ToUpdate != nil {
+		cfg.ToUpdate = m.ToUpdate
+	}
+}
+
+// NewResource returns the handler of the routes of repo mounted at path. The fields must be
+// columns of T, it panics otherwise as the routes would not work
+func NewResource[T any, C any, U any](path string, repo mydatabase.RepositoryIf[T], configs ...ResourceConfig[T, C, U]) *Resource[T, C, U] {
+	cfg := DefaultResourceConfig[T, C, U]()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	if cfg.Tag == "" {
+		cfg.Tag = strings.Trim(path, "/")
+	}
+
+	sch, err := schema.Parse(new(T), schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		panic(fmt.Sprintf("rest: cannot parse the schema of %T: %v", *new(T), err))
+	}
+
+	r := &Resource[T, C, U]{
+		path: "/" + strings.Trim(path, "/"),
+		repo: repo,
+		cfg:  cfg,
+		query: query.New[T](cfg.Fields, query.Config{
+			DefaultSort:     cfg.DefaultSort,
+			DefaultPageSize: cfg.DefaultPageSize,
+			MaxPageSize:     cfg.MaxPageSize,
+		}),
+		columns: sch.FieldsByDBName,
+	}
+	if cfg.ToUpdate == nil {
+		r.updates = updateFields(sch, reflect.TypeFor[U](), nil)
+	}
+
+	return r
+}
+
+func (r *Resource[T, C, U]) Register(router fiber.Router) {
+	tag := openapi.Tags(r.cfg.Tag)
+	// Usecase runs the middlewares last to first, the ones of the config run before the
+	// payload is bound
+	with := func(ms ...fiber.Handler) []fiber.Handler {
+		return append(ms, r.cfg.Middlewares...)
+	}
+
+	for _, action := range r.cfg.Actions {
+		switch action {
+		case ActionList:
+			router.Get(r.path, routes.Usecase(r.list, http.StatusOK,
+				with(routes.Doc(append(r.listDocs(), openapi.Summary("List the "+r.cfg.Tag), tag)...), r.bindList)...))
+		case ActionGet:
+			router.Get(r.path+"/:id", routes.Usecase(r.get, http.StatusOK,
+				with(routes.Doc(openapi.Summary("Get from the "+r.cfg.Tag+" by id"), tag),
+					middlewares.AllPayloadValidator[IDRequest]())...))
+		case ActionCreate:
+			router.Post(r.path, routes.Usecase(r.create, http.StatusCreated,
+				with(routes.Doc(openapi.Summary("Create in the "+r.cfg.Tag), tag),
+					middlewares.AllPayloadValidator[C]())...))
+		case ActionUpdate:
+			router.Patch(r.path+"/:id", routes.Usecase(r.update, http.StatusOK,
+				with(routes.Doc(openapi.Summary("Update in the "+r.cfg.Tag+" by id"), tag, integerID),
+					middlewares.AllPayloadValidator[U](), bindID)...))
+		case ActionDelete:
+			router.Delete(r.path+"/:id", routes.Usecase(r.delete, http.StatusNoContent,
+				with(routes.Doc(openapi.Summary("Delete from the "+r.cfg.Tag+" by id"), tag),
+					middlewares.AllPayloadValidator[IDRequest]())...))
+		}
+	}
+}
+
+func (r *Resource[T, C, U]) list(ctx context.Context, req ListRequest) (*routes.Page[T], error) {
+	where, params, err := r.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.authorize(ctx, ActionList, nil); err != nil {
+		return nil, err
+	}
+
+	option := *req.option
+	if option.Where != "" {
+		where = append(where, option.Where)
+	}
+	// The repository leaves the soft deleted rows in the queries without condition
+	if _, ok := r.columns["deleted_at"]; ok && len(where) == 0 {
+		where = append(where, "deleted_at IS NULL")
+	}
+	option.Where = strings.Join(where, " AND ")
+	option.Params = append(params, option.Params...)
+	option.Preload = r.cfg.Preload
+
+	total, items, aerr := r.repo.Pagination(ctx, &option)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	return &routes.Page[T]{
+		Items:     *items,
+		Total:     total,
+		Page:      option.Page,
+		Take:      option.Take,
+		PageParam: "page[number]",
+		TakeParam: "page[size]",
+	}, nil
+}
+
+func (r *Resource[T, C, U]) get(ctx context.Context, req IDRequest) (*T, error) {
+	entity, err := r.find(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.authorize(ctx, ActionGet, entity); err != nil {
+		return nil, err
+	}
+
+	return entity, nil
+}
+
+func (r *Resource[T, C, U]) create(ctx context.Context, dto C) (*T, error) {
+	entity, err := r.toEntity(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.authorize(ctx, ActionCreate, entity); err != nil {
+		return nil, err
+	}
+
+	created, aerr := r.repo.Create(ctx, entity)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	return created[0], nil
+}
+
+func (r *Resource[T, C, U]) update(ctx context.Context, dto U) (*T, error) {
+	id, _ := ctx.Value(KEY_RESOURCE_ID).(int)
+
+	entity, err := r.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.authorize(ctx, ActionUpdate, entity); err != nil {
+		return nil, err
+	}
+
+	values, err := r.toUpdate(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return entity, nil
+	}
+
+	cond, err := r.byID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if aerr := r.repo.UpdateByMap(ctx, values, cond); aerr != nil {
+		return nil, aerr
+	}
+
+	return r.find(ctx, id)
+}
+
+func (r *Resource[T, C, U]) delete(ctx context.Context, req IDRequest) (any, error) {
+	entity, err := r.find(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.authorize(ctx, ActionDelete, entity); err != nil {
+		return nil, err
+	}
+
+	cond, err := r.byID(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if aerr := r.repo.DeleteBy(ctx, cond); aerr != nil {
+		return nil, aerr
+	}
+
+	return nil, nil
+}
+
+// find returns the entity of id in the scope of the request, the soft deleted ones are not
+// found
+func (r *Resource[T, C, U]) find(ctx context.Context, id int) (*T, error) {
+	cond, err := r.byID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, aerr := r.repo.FindOneBy(ctx, &mydatabase.FindOption{
+		Where:   cond.Where,
+		Params:  cond.Params,
+		Preload: r.cfg.Preload,
+	})
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	return entity, nil
+}
+
+// byID returns the condition of the entity of id in the scope of the request, the updates and
+// the deletions are filtered by it too so they cannot reach an entity out of the scope
+func (r *Resource[T, C, U]) byID(ctx context.Context, id int) (mydatabase.WhereOption, error) {
+	where, params, err := r.scope(ctx)
+	if err != nil {
+		return mydatabase.WhereOption{}, err
+	}
+
+	return mydatabase.WhereOption{
+		Where:  strings.Join(append([]string{"id = ?"}, where...), " AND "),
+		Params: append([]any{id}, params...),
+	}, nil
+}
+
+func (r *Resource[T, C, U]) scope(ctx context.Context) ([]string, []any, error) {
+	if r.cfg.Scope == nil {
+		return nil, nil, nil
+	}
+
+	cond, err := r.cfg.Scope(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cond.Where == "" {
+		return nil, nil, nil
+	}
+
+	return []string{"(" + cond.Where + ")"}, cond.Params, nil
+}
+
+func (r *Resource[T, C, U]) authorize(ctx context.Context, action Action, entity *T) error {
+	if r.cfg.Authorize == nil {
+		return nil
+	}
+	return r.cfg.Authorize(ctx, action, entity)
+}
+
+func (r *Resource[T, C, U]) toEntity(ctx context.Context, dto C) (*T, error) {
+	if r.cfg.ToEntity != nil {
+		return r.cfg.ToEntity(ctx, dto)
+	}
+
+	data, err := json.Marshal(dto)
+	if err != nil {
+		return nil, myerrors.InternalFailure("cannot map the payload to the entity").WithCause(err)
+	}
+	entity := new(T)
+	if err := json.Unmarshal(data, entity); err != nil {
+		return nil, myerrors.InternalFailure("cannot map the payload to the entity").WithCause(err)
+	}
+	return entity, nil
+}
+
+// toUpdate returns the columns to update, the ones returned by ToUpdate must be columns of T
+// so the keys of the map never reach the query unchecked
+func (r *Resource[T, C, U]) toUpdate(ctx context.Context, dto U) (map[string]any, error) {
+	if r.cfg.ToUpdate != nil {
+		values, err := r.cfg.ToUpdate(ctx, dto)
+		if err != nil {
+			return nil, err
+		}
+		for column := range values {
+			if _, ok := r.columns[column]; !ok {
+				return nil, myerrors.InternalFailure(fmt.Sprintf("cannot update the unknown column %s", column))
+			}
+		}
+		return values, nil
+	}
+
+	values := map[string]any{}
+	v := reflect.ValueOf(dto)
+	for _, f := range r.updates {
+		field := v.FieldByIndex(f.index)
+		switch field.Kind() {
+		case reflect.Pointer:
+			if field.IsNil() {
+				continue
+			}
+			values[f.column] = field.Elem().Interface()
+		case reflect.Slice, reflect.Map:
+			if field.IsNil() {
+				continue
+			}
+			values[f.column] = field.Interface()
+		}
+	}
+	return values, nil
+}
+
+// bindList parses the query of the lists
+func (r *Resource[T, C, U]) bindList(c *fiber.Ctx) error {
+	values := url.Values{}
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		values.Add(string(key), string(value))
+	})
+
+	option, aerr := r.query.Parse(values)
+	if aerr != nil {
+		return aerr
+	}
+
+	c.Locals(routes.KEY_REQ_ALL_PARAMS, ListRequest{option: option})
+	return routes.Next(c)
+}
+
+// listDocs documents the query of the lists
+func (r *Resource[T, C, U]) listDocs() []openapi.Option {
+	var sorts, selects []string
+	docs := []openapi.Option{
+		openapi.QueryParam("page[number]", reflect.TypeFor[int](), "Page number, from 1"),
+		openapi.QueryParam("page[size]", reflect.TypeFor[int](), fmt.Sprintf("Page size, at most %d", r.cfg.MaxPageSize)),
+	}
+	for _, f := range r.query.Fields() {
+		if f.Sort {
+			sorts = append(sorts, f.Name)
+		}
+		if f.Select {
+			selects = append(selects, f.Name)
+		}
+		if len(f.Filter) == 0 {
+			continue
+		}
+		ops := make([]string, len(f.Filter))
+		for i, op := range f.Filter {
+			ops[i] = string(op)
+		}
+		docs = append(docs, openapi.QueryParam("filter["+f.Name+"]", r.query.Type(f.Name),
+			"Equal to the value, filter["+f.Name+"][operator] takes the operators "+strings.Join(ops, ", ")))
+	}
+	if len(sorts) > 0 {
+		docs = append(docs, openapi.QueryParam("sort", reflect.TypeFor[string](),
+			"Comma separated fields prefixed with - for a descending order: "+strings.Join(sorts, ", ")))
+	}
+	if len(selects) > 0 {
+		docs = append(docs, openapi.QueryParam("fields", reflect.TypeFor[string](),
+			"Comma separated fields returned: "+strings.Join(selects, ", ")))
+	}
+	return docs
+}
+
+// bindID passes the id of the path to the handlers whose request is the payload
+func bindID(c *fiber.Ctx) error {
+	var req IDRequest
+	if err := routes.Bind(c, &req); err != nil {
+		return err
+	}
+	if err := routes.ValidateRequest(c.UserContext(), &req); err != nil {
+		return err
+	}
+
+	c.Locals(KEY_RESOURCE_ID, req.ID)
+	return routes.Next(c)
+}
+
+// integerID documents the id of the path of the routes taking it through bindID
+func integerID(op *openapi.Operation) {
+	for _, p := range op.Parameters {
+		if p.In == "path" && p.Name == "id" {
+			p.Schema = &openapi.Schema{Type: "integer", Format: "int64", Minimum: new(float64)}
+		}
+	}
+}
+
+// updateFields maps the fields of U to the columns of T of the same name, it panics when a
+// field has none so the DTO cannot silently lose fields, or when it is not a pointer, a slice
+// or a map as its zero value could not be told apart from an absent field
+func updateFields(sch *schema.Schema, t reflect.Type, index []int) []updateField {
+	var fields []updateField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		idx := append(append([]int{}, index...), i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, updateFields(sch, f.Type, idx)...)
+			continue
+		}
+		if !f.IsExported() || f.Tag.Get("json") == "-" {
+			continue
+		}
+
+		field := sch.LookUpField(f.Name)
+		if field == nil || field.DBName == "" {
+			panic(fmt.Sprintf("rest: field %s of %s is not a column of %s", f.Name, t, sch.Name))
+		}
+		switch f.Type.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+		default:
+			panic(fmt.Sprintf("rest: field %s of %s must be a pointer to be left unchanged when absent", f.Name, t))
+		}
+		fields = append(fields, updateField{index: idx, column: field.DBName})
+	}
+	return fields
+}
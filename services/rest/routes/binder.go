@@ -0,0 +1,289 @@
+package routes
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	pathTag   = "path"
+	paramsTag = "params"
+	queryTag  = "query"
+	headerTag = "header"
+)
+
+type (
+	// binding is a field filled from a source of the request
+	binding struct {
+		index  []int
+		source string
+		name   string
+	}
+
+	// bindingError is a value of the request that does not fit its field
+	bindingError struct {
+		field string
+		err   error
+	}
+)
+
+var (
+	bindings sync.Map // reflect.Type -> []binding
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// Bind fills out, a pointer to a struct, from the request. The sources are read in order,
+// a value present in a later source replaces the earlier one:
+//  1. the body, JSON by its json tags or a form by its form tags
+//  2. the query string, by the query tags
+//  3. the headers, by the header tags
+//  4. the path parameters, by the path tags (params is accepted too)
+//
+// so an id in the path cannot be overridden by the body. A value that does not fit its
+// field is a payload.001 error listing the fields in error
+func Bind(c *fiber.Ctx, out any) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return myerrors.InternalFailure(fmt.Sprintf("cannot bind the request to %T", out))
+	}
+
+	if err := bindBody(c, out); err != nil {
+		return err
+	}
+
+	aerr := myerrors.PayloadInvalid("request parameters are invalid")
+	var errs []error
+
+	v = v.Elem()
+	for _, b := range bindingsOf(v.Type()) {
+		values := sourceValues(c, b)
+		if len(values) == 0 {
+			continue
+		}
+
+		field, err := v.FieldByIndexErr(b.index)
+		if err != nil {
+			continue
+		}
+		if err := setField(field, values); err != nil {
+			aerr = aerr.WithField(b.name, "type", "")
+			errs = append(errs, &bindingError{field: b.name, err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return aerr.WithCause(errors.Join(errs...))
+	}
+	return nil
+}
+
+func (e *bindingError) Error() string {
+	return e.field + ": " + e.err.Error()
+}
+
+func (e *bindingError) Unwrap() error {
+	return e.err
+}
+
+func bindBody(c *fiber.Ctx, out any) error {
+	body := c.Body()
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	ctype := strings.ToLower(c.Get(fiber.HeaderContentType))
+	switch {
+	case strings.HasPrefix(ctype, fiber.MIMEApplicationForm), strings.HasPrefix(ctype, fiber.MIMEMultipartForm):
+		if err := c.BodyParser(out); err != nil {
+			return myerrors.PayloadInvalid("request body is invalid").WithCause(err)
+		}
+		return nil
+	case ctype == "", strings.Contains(ctype, "json"):
+		return decodeJSON(body, out)
+	}
+
+	return fiber.ErrUnsupportedMediaType
+}
+
+func decodeJSON(body []byte, out any) error {
+	err := json.Unmarshal(body, out)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return myerrors.PayloadInvalid("request body is invalid").
+			WithField(typeErr.Field, "type", "").
+			WithCause(err)
+	}
+	return myerrors.PayloadInvalid("request body is not valid JSON").WithCause(err)
+}
+
+func sourceValues(c *fiber.Ctx, b binding) []string {
+	switch b.source {
+	case queryTag:
+		var values []string
+		for _, v := range c.Context().QueryArgs().PeekMulti(b.name) {
+			values = append(values, string(v))
+		}
+		return values
+	case headerTag:
+		if v := c.Get(b.name); v != "" {
+			return []string{v}
+		}
+	case pathTag:
+		if v := c.Params(b.name); v != "" {
+			return []string{v}
+		}
+	}
+	return nil
+}
+
+// bindingsOf returns the fields of t filled from the query, the headers and the path, in
+// the order they are applied
+func bindingsOf(t reflect.Type) []binding {
+	if cached, ok := bindings.Load(t); ok {
+		return cached.([]binding)
+	}
+
+	var query, header, path []binding
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			idx := append(append([]int{}, index...), i)
+
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				walk(f.Type, idx)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+
+			if name := tagName(f, queryTag); name != "" {
+				query = append(query, binding{index: idx, source: queryTag, name: name})
+			}
+			if name := tagName(f, headerTag); name != "" {
+				header = append(header, binding{index: idx, source: headerTag, name: name})
+			}
+			if name := tagName(f, pathTag); name != "" {
+				path = append(path, binding{index: idx, source: pathTag, name: name})
+			} else if name := tagName(f, paramsTag); name != "" {
+				path = append(path, binding{index: idx, source: pathTag, name: name})
+			}
+		}
+	}
+	walk(t, nil)
+
+	res := append(append(query, header...), path...)
+	bindings.Store(t, res)
+	return res
+}
+
+func tagName(f reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// ParseValue converts value to t the way Bind converts the query parameters, for the values
+// read outside of a struct like the filters of a list
+func ParseValue(t reflect.Type, value string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	v := reflect.New(t).Elem()
+	if err := setField(v, []string{value}); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// setField converts the values to the type of field, a slice takes the repeated values or
+// the comma separated ones
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setField(elem.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if reflect.PointerTo(field.Type()).Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(values[0]))
+	}
+
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setField(slice.Index(i), []string{strings.TrimSpace(value)}); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	value := values[0]
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Type() == durationType {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gofiber/fiber/v2"
+)
+
+type bindRequest struct {
+	ID      int           `json:"id" path:"id"`
+	Name    string        `json:"name" query:"name"`
+	Tenant  string        `json:"tenant" header:"X-Tenant"`
+	Tags    []string      `query:"tag"`
+	Limit   *int          `query:"limit"`
+	Timeout time.Duration `query:"timeout"`
+	Active  bool          `query:"active"`
+	Note    string        `json:"note"`
+}
+
+// bind runs Bind on a request to /items/:id and returns what it bound
+func bind[T any](t *testing.T, req *http.Request) (T, error) {
+	t.Helper()
+
+	var (
+		out     T
+		bindErr error
+	)
+	app := fiber.New()
+	app.All("/items/:id", func(c *fiber.Ctx) error {
+		bindErr = Bind(c, &out)
+		return nil
+	})
+
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	return out, bindErr
+}
+
+func TestBindPrecedence(t *testing.T) {
+	body := `{"id": 99, "name": "body", "tenant": "body", "note": "kept"}`
+	req := httptest.NewRequest(http.MethodPost, "/items/7?name=query&tag=a&tag=b&limit=5&timeout=2s&active=true", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set("X-Tenant", "header")
+
+	got, err := bind[bindRequest](t, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := 5
+	want := bindRequest{
+		ID:      7,
+		Name:    "query",
+		Tenant:  "header",
+		Tags:    []string{"a", "b"},
+		Limit:   &limit,
+		Timeout: 2 * time.Second,
+		Active:  true,
+		Note:    "kept",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Bind = %+v, want %+v", got, want)
+	}
+}
+
+func TestBindKeepsTheBodyWithoutOtherSources(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/items/7?tag=a,%20b", strings.NewReader(`{"name": "body"}`))
+
+	got, err := bind[bindRequest](t, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "body" || !reflect.DeepEqual(got.Tags, []string{"a", "b"}) || got.Limit != nil {
+		t.Errorf("Bind = %+v", got)
+	}
+}
+
+func TestBindTypeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		ctype  string
+		fields []string
+	}{
+		{
+			name:   "path and query",
+			target: "/items/seven?limit=many&timeout=2s",
+			fields: []string{"limit", "id"},
+		},
+		{
+			name:   "slice item",
+			target: "/items/7?tag=a&timeout=soon",
+			fields: []string{"timeout"},
+		},
+		{
+			name:   "json body",
+			target: "/items/7",
+			body:   `{"name": 5}`,
+			ctype:  fiber.MIMEApplicationJSON,
+			fields: []string{"name"},
+		},
+		{
+			name:   "malformed json body",
+			target: "/items/7",
+			body:   `{"name":`,
+			ctype:  fiber.MIMEApplicationJSON,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			if tt.ctype != "" {
+				req.Header.Set(fiber.HeaderContentType, tt.ctype)
+			}
+
+			_, err := bind[bindRequest](t, req)
+
+			var aerr *myerrors.AppError
+			if !errors.As(err, &aerr) || aerr.Code != "payload.001" {
+				t.Fatalf("Bind error = %v, want payload.001", err)
+			}
+			var fields []string
+			if aerr.Details != nil {
+				for _, fe := range aerr.Details.Fields {
+					fields = append(fields, fe.Field)
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestBindRejects(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/items/7", strings.NewReader("<a/>"))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationXML)
+	if _, err := bind[bindRequest](t, req); !errors.Is(err, fiber.ErrUnsupportedMediaType) {
+		t.Errorf("xml body = %v", err)
+	}
+
+	if _, err := bind[int](t, httptest.NewRequest(http.MethodGet, "/items/7", nil)); err == nil {
+		t.Error("binding to a non struct succeeded")
+	}
+}
@@ -0,0 +1,175 @@
+package routes
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	// Envelope is the body of the successful responses of Usecase
+	Envelope[T any] struct {
+		Success bool   `json:"success"`
+		Data    T      `json:"data"`
+		Meta    *Meta  `json:"meta,omitempty"`
+		Links   *Links `json:"links,omitempty"`
+	}
+
+	// ErrorEnvelope is the body of the errors of the handlers not rendering problems
+	ErrorEnvelope struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+
+	// Meta is the pagination of a list
+	Meta struct {
+		Total      int    `json:"total"`
+		Page       int    `json:"page,omitempty"`
+		Take       int    `json:"take,omitempty"`
+		NextCursor string `json:"next_cursor,omitempty"`
+	}
+
+	// Links are the URLs of the pages around the current one
+	Links struct {
+		Self  string `json:"self"`
+		First string `json:"first,omitempty"`
+		Prev  string `json:"prev,omitempty"`
+		Next  string `json:"next,omitempty"`
+		Last  string `json:"last,omitempty"`
+	}
+
+	// Page is the result of a list, Usecase renders its items as the data of the envelope
+	// and its pagination as the meta and the links. A page of a cursor sets NextCursor,
+	// the next link then carries the cursor rather than a page number
+	Page[T any] struct {
+		Items      []T
+		Total      int
+		Page       int
+		Take       int
+		NextCursor string
+
+		// PageParam, TakeParam and CursorParam are the query parameters of the links, page,
+		// take and cursor by default
+		PageParam   string
+		TakeParam   string
+		CursorParam string
+	}
+
+	// paginated is implemented by Page whatever its items
+	paginated interface {
+		envelope(c *fiber.Ctx) any
+		envelopeType() reflect.Type
+	}
+)
+
+var bracketUnescaper = strings.NewReplacer("%5B", "[", "%5D", "]")
+
+func (p Page[T]) envelope(c *fiber.Ctx) any {
+	items := p.Items
+	if items == nil {
+		items = []T{}
+	}
+
+	return &Envelope[[]T]{
+		Success: true,
+		Data:    items,
+		Meta: &Meta{
+			Total:      p.Total,
+			Page:       p.Page,
+			Take:       p.Take,
+			NextCursor: p.NextCursor,
+		},
+		Links: p.links(c),
+	}
+}
+
+func (p Page[T]) envelopeType() reflect.Type {
+	return reflect.TypeFor[Envelope[[]T]]()
+}
+
+func (p Page[T]) links(c *fiber.Ctx) *Links {
+	pageParam := defaultString(p.PageParam, "page")
+	takeParam := defaultString(p.TakeParam, "take")
+	cursorParam := defaultString(p.CursorParam, "cursor")
+
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	link := func(set map[string]string) string {
+		values := url.Values{}
+		for k, v := range query {
+			values[k] = v
+		}
+		for k, v := range set {
+			values.Set(k, v)
+		}
+		// The brackets of page[number] are kept readable, like in the request
+		return c.Path() + "?" + bracketUnescaper.Replace(values.Encode())
+	}
+
+	links := &Links{Self: c.OriginalURL()}
+	if p.NextCursor != "" {
+		links.Next = link(map[string]string{cursorParam: p.NextCursor})
+		return links
+	}
+	if p.Page < 1 || p.Take < 1 {
+		return links
+	}
+
+	take := strconv.Itoa(p.Take)
+	last := max((p.Total+p.Take-1)/p.Take, 1)
+
+	links.First = link(map[string]string{pageParam: "1", takeParam: take})
+	links.Last = link(map[string]string{pageParam: strconv.Itoa(last), takeParam: take})
+	if p.Page > 1 {
+		links.Prev = link(map[string]string{pageParam: strconv.Itoa(min(p.Page-1, last)), takeParam: take})
+	}
+	if p.Page < last {
+		links.Next = link(map[string]string{pageParam: strconv.Itoa(p.Page + 1), takeParam: take})
+	}
+	return links
+}
+
+// wrap returns the body of a result, in an envelope unless raw
+func wrap[R any](c *fiber.Ctx, result R, raw bool) any {
+	if raw {
+		return result
+	}
+	if p, ok := any(result).(paginated); ok && !isNil(result) {
+		return p.envelope(c)
+	}
+	return SuccessResponse(result)
+}
+
+// bodyType is the type of the body rendered for the results of R
+func bodyType[R any](raw bool) reflect.Type {
+	t := reflect.TypeFor[R]()
+	if raw {
+		return t
+	}
+	if p, ok := zeroOf(t).(paginated); ok {
+		return p.envelopeType()
+	}
+	return reflect.TypeFor[Envelope[R]]()
+}
+
+// zeroOf returns a value of t whose methods can be called, a new value for the pointers
+func zeroOf(t reflect.Type) any {
+	if t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem()).Interface()
+	}
+	return reflect.Zero(t).Interface()
+}
+
+func isNil(v any) bool {
+	rv := reflect.ValueOf(v)
+	return !rv.IsValid() || (rv.Kind() == reflect.Pointer && rv.IsNil())
+}
+
+func defaultString(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
@@ -1,23 +1,10 @@
 package routes
 
 import (
-	"errors"
-
 	"github.com/gofiber/fiber/v2"
 )
 
-type APIError struct {
-	Status  int
-	Message interface{}
-}
-
+// FromError renders err with the error handler of the app, see ErrorHandler
 func FromError(ctx *fiber.Ctx, err error) error {
-	var apiError APIError
-	var svcError *fiber.Error
-	if errors.As(err, &svcError) {
-		apiError.Message = ErrorResponse(svcError)
-		apiError.Status = svcError.Code
-	}
-
-	return ctx.Status(apiError.Status).JSON(apiError.Message)
+	return ctx.App().Config().ErrorHandler(ctx, err)
 }
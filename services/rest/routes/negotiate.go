@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gianglt2198/platforms/pkg/tools/encoder"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	MIMEMsgpack  = openapi.ContentTypeMsgpack
+	MIMEXMsgpack = "application/x-msgpack"
+	MIMECSV      = openapi.ContentTypeCSV
+)
+
+// Render writes body in the format accepted by the request, JSON when the request accepts
+// anything. Msgpack is always offered, CSV only for the lists, whose items are the rows.
+// A request accepting none of them gets a 406
+func Render(c *fiber.Ctx, status int, body any) error {
+	c.Vary(fiber.HeaderAccept)
+
+	switch c.Accepts(offers(tabular(body))...) {
+	case fiber.MIMEApplicationJSON:
+		return c.Status(status).JSON(body)
+	case MIMEMsgpack, MIMEXMsgpack:
+		// The encoder holds its buffer, one per response
+		data, err := encoder.NewMsgpackEncoder().Encode(body)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, MIMEMsgpack)
+		return c.Status(status).SendString(data)
+	case MIMECSV:
+		data, err := encodeCSV(reflect.ValueOf(tabular(body)))
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, MIMECSV+"; charset=utf-8")
+		return c.Status(status).Send(data)
+	}
+
+	return fiber.ErrNotAcceptable
+}
+
+func offers(rows any) []string {
+	offers := []string{fiber.MIMEApplicationJSON, MIMEMsgpack, MIMEXMsgpack}
+	if rows != nil {
+		offers = append(offers, MIMECSV)
+	}
+	return offers
+}
+
+// contentTypes are the media types documented for a body of t
+func contentTypes(t reflect.Type) []string {
+	types := []string{openapi.ContentTypeJSON, MIMEMsgpack}
+	if rowsType(t) != nil {
+		types = append(types, MIMECSV)
+	}
+	return types
+}
+
+// tabular returns the rows of a list body, the data of an envelope or the body itself,
+// nil when it is not a list of structs
+func tabular(body any) any {
+	v := reflect.ValueOf(body)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct && isEnvelope(v.Type()) {
+		v = v.FieldByName("Data")
+	}
+	if !v.IsValid() || rowsType(v.Type()) == nil {
+		return nil
+	}
+	return v.Interface()
+}
+
+// rowsType returns the struct of the rows of a body of t, nil when it has none
+func rowsType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct && isEnvelope(t) {
+		t = t.Field(1).Type
+	}
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return nil
+	}
+
+	t = t.Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == reflect.TypeFor[time.Time]() {
+		return nil
+	}
+	return t
+}
+
+func isEnvelope(t reflect.Type) bool {
+	return t.PkgPath() == reflect.TypeFor[Envelope[any]]().PkgPath() && strings.HasPrefix(t.Name(), "Envelope[")
+}
+
+type column struct {
+	name  string
+	index []int
+}
+
+// columns are the fields encoded to JSON, the embedded structs flattened
+func columns(t reflect.Type, index []int) []column {
+	var cols []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		fieldIndex := append(append([]int{}, index...), i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			cols = append(cols, columns(ft, fieldIndex)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		cols = append(cols, column{name: name, index: fieldIndex})
+	}
+	return cols
+}
+
+func encodeCSV(rows reflect.Value) ([]byte, error) {
+	cols := columns(rowsType(rows.Type()), nil)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = col.name
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	record := make([]string, len(cols))
+	for i := 0; i < rows.Len(); i++ {
+		row := reflect.Indirect(rows.Index(i))
+		for j, col := range cols {
+			cell, err := csvCell(row, col.index)
+			if err != nil {
+				return nil, err
+			}
+			record[j] = cell
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvCell formats a field of row, the scalars as text and the others as JSON
+func csvCell(row reflect.Value, index []int) (string, error) {
+	v, err := row.FieldByIndexErr(index)
+	if err != nil {
+		// A field of a nil embedded struct
+		return "", nil
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.Format(time.RFC3339), nil
+	case encoding.TextMarshaler:
+		text, err := value.MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), nil
+	}
+
+	data, err := json.Marshal(v.Interface())
+	return string(data), err
+}
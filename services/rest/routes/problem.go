@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/pkg/problem"
+	restcommon "github.com/gianglt2198/platforms/services/rest/common"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	ContentTypeProblem = "application/problem+json"
+
+	KEY_REQUEST_ID = "requestId"
+)
+
+type (
+	// Problem is an error response following RFC 7807, see problem.Problem
+	Problem = problem.Problem
+
+	// ErrorHandlerConfig holds configuration for the problem error handler
+	ErrorHandlerConfig struct {
+		// IsProdEnv hides the detail of the server errors and the internal metadata of all
+		// the errors
+		IsProdEnv bool
+		// TypeURI returns the type of the problem of an error code, "urn:problem:<code>" by default
+		TypeURI func(code string) string
+		// Catalog localizes the details and the field errors, myerrors.DefaultCatalog by default
+		Catalog *myerrors.Catalog
+	}
+)
+
+// DefaultErrorHandlerConfig returns the default configuration
+func DefaultErrorHandlerConfig() ErrorHandlerConfig {
+	def := problem.DefaultConfig()
+	return ErrorHandlerConfig{
+		TypeURI: def.TypeURI,
+		Catalog: def.Catalog,
+	}
+}
+
+func (m ErrorHandlerConfig) apply(cfg *ErrorHandlerConfig) {
+	if m.IsProdEnv {
+		cfg.IsProdEnv = true
+	}
+	if m.TypeURI != nil {
+		cfg.TypeURI = m.TypeURI
+	}
+	if m.Catalog != nil {
+		cfg.Catalog = m.Catalog
+	}
+}
+
+// ErrorHandler renders every error reaching fiber as application/problem+json, in the
+// locale of the catalog best matching the Accept-Language of the request
+func ErrorHandler(configs ...ErrorHandlerConfig) fiber.ErrorHandler {
+	cfg := DefaultErrorHandlerConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return func(ctx *fiber.Ctx, err error) error {
+		locale := cfg.Catalog.MatchLocale(ctx.Get(fiber.HeaderAcceptLanguage))
+		ctx.Set(fiber.HeaderContentLanguage, locale)
+
+		problem := NewProblem(err, locale, cfg)
+		problem.Instance = ctx.OriginalURL()
+		if requestID, ok := ctx.Locals(KEY_REQUEST_ID).(string); ok {
+			problem.RequestID = requestID
+		}
+
+		body, mErr := json.Marshal(problem)
+		if mErr != nil {
+			return ctx.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		ctx.Set(fiber.HeaderContentType, ContentTypeProblem)
+		return ctx.Status(problem.Status).Send(body)
+	}
+}
+
+// NewProblem converts err, an AppError, a restcommon.AError, validation errors or a
+// fiber.Error, to a problem in locale
+func NewProblem(err error, locale string, cfg ErrorHandlerConfig) *Problem {
+	return problem.New(err, locale, problem.Config{
+		IsProdEnv: cfg.IsProdEnv,
+		TypeURI:   cfg.TypeURI,
+		Catalog:   cfg.Catalog,
+		StatusOf:  fiberStatus,
+	})
+}
+
+// fiberStatus returns the status of a fiber.Error, the detail of an AError is its app error
+func fiberStatus(err error) (int, string, bool) {
+	var fiberErr *fiber.Error
+	if !errors.As(err, &fiberErr) {
+		return 0, "", false
+	}
+
+	detail := fiberErr.Message
+	var aErr restcommon.AError
+	if errors.As(err, &aErr) && aErr.AppError() != nil {
+		detail = aErr.AppError().Error()
+	}
+	return fiberErr.Code, detail, true
+}
@@ -1,19 +1,19 @@
 package routes
 
-func ErrorResponse(err error) *map[string]interface{} {
-
-	res := make(map[string]interface{})
-	res["success"] = false
-	res["error"] = err.Error()
-
-	return &res
+// ErrorResponse is the body of an error for the handlers not rendering problems, see
+// ErrorHandler for the errors of Usecase
+func ErrorResponse(err error) *ErrorEnvelope {
+	return &ErrorEnvelope{
+		Success: false,
+		Error:   err.Error(),
+	}
 }
 
-func SuccessResponse(data interface{}) *map[string]interface{} {
-
-	res := make(map[string]interface{})
-	res["success"] = true
-	res["data"] = data
-
-	return &res
+// SuccessResponse wraps data in the envelope of Usecase, for the handlers writing their
+// response themselves
+func SuccessResponse[T any](data T) *Envelope[T] {
+	return &Envelope[T]{
+		Success: true,
+		Data:    data,
+	}
 }
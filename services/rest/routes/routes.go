@@ -1,24 +1,95 @@
 package routes
 
 import (
-	"context"
+	"errors"
+	"reflect"
 
+	"github.com/gianglt2198/platforms/common"
+	myerrors "github.com/gianglt2198/platforms/errors"
 	restcommon "github.com/gianglt2198/platforms/services/rest/common"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
 	"github.com/gofiber/fiber/v2"
 )
 
 const (
 	KEY_REQ_ALL_PARAMS = "parsed_all_params"
+	KEY_INLINE         = "usecase_inline"
+	KEY_AFTER          = "usecase_after"
 )
 
-type Handler[T any, R any] func(context.Context, T) (R, error)
+// Next runs the next handler of the stack, unless the middleware is run by Usecase
+func Next(ctx *fiber.Ctx) error {
+	if inline, _ := ctx.Locals(KEY_INLINE).(bool); inline {
+		return nil
+	}
+	return ctx.Next()
+}
+
+// errStopped ends the middlewares of Usecase, see Stop
+var errStopped = errors.New("routes: response written by a middleware")
+
+// Stop ends the request after a middleware wrote the response itself, like a cached one
+func Stop(ctx *fiber.Ctx) error {
+	if inline, _ := ctx.Locals(KEY_INLINE).(bool); inline {
+		return errStopped
+	}
+	return nil
+}
+
+// After runs fn once the response is written, to record it. Run by Usecase, fn is called
+// after the handler or the error of a later middleware was rendered, otherwise after the
+// rest of the stack
+func After(ctx *fiber.Ctx, fn func(ctx *fiber.Ctx)) error {
+	if inline, _ := ctx.Locals(KEY_INLINE).(bool); inline {
+		after, _ := ctx.Locals(KEY_AFTER).([]func(*fiber.Ctx))
+		ctx.Locals(KEY_AFTER, append(after, fn))
+		return nil
+	}
 
+	if err := ctx.Next(); err != nil {
+		if err := FromError(ctx, err); err != nil {
+			return err
+		}
+	}
+	fn(ctx)
+	return nil
+}
+
+// Handler is a use case served by Usecase, see common.Handler
+type Handler[T any, R any] = common.Handler[T, R]
+
+// Usecase adapts f to a fiber handler, it is documented by openapi.Generate from T, R,
+// the success status and the middlewares. The result is rendered in an Envelope, a Page
+// with its pagination, in the format negotiated by Render
 func Usecase[T any, R any](f Handler[T, R], successStatus int, ms ...fiber.Handler) fiber.Handler {
-	return func(ctx *fiber.Ctx) error {
-		// Apply middlewares in reverse order
+	return usecase(f, successStatus, false, ms)
+}
+
+// RawUsecase is Usecase without the envelope, the result is the body of the response
+func RawUsecase[T any, R any](f Handler[T, R], successStatus int, ms ...fiber.Handler) fiber.Handler {
+	return usecase(f, successStatus, true, ms)
+}
+
+func usecase[T any, R any](f Handler[T, R], successStatus int, raw bool, ms []fiber.Handler) fiber.Handler {
+	run := func(ctx *fiber.Ctx) error {
+		// Apply middlewares in reverse order, they call Next which must not run the rest of
+		// the stack of the app meanwhile
+		ctx.Locals(KEY_INLINE, true)
+		defer ctx.Locals(KEY_INLINE, nil)
 		for i := len(ms) - 1; i >= 0; i-- {
 			middleware := ms[i]
 			if err := middleware(ctx); err != nil {
+				if errors.Is(err, errStopped) {
+					return nil
+				}
+				// Errors carrying a status, like the authorization guards ones, keep it
+				var (
+					aerr *myerrors.AppError
+					ferr *fiber.Error
+				)
+				if errors.As(err, &aerr) || errors.As(err, &ferr) {
+					return FromError(ctx, err)
+				}
 				return FromError(ctx, restcommon.NewError(fiber.ErrBadRequest, err))
 			}
 		}
@@ -35,6 +106,44 @@ func Usecase[T any, R any](f Handler[T, R], successStatus int, ms ...fiber.Handl
 			return FromError(ctx, err)
 		}
 
-		return ctx.Status(successStatus).JSON(SuccessResponse(result))
+		if successStatus == fiber.StatusNoContent {
+			return ctx.SendStatus(successStatus)
+		}
+		if err := Render(ctx, successStatus, wrap(ctx, result, raw)); err != nil {
+			return FromError(ctx, err)
+		}
+		return nil
+	}
+
+	h := func(ctx *fiber.Ctx) error {
+		err := run(ctx)
+
+		// The middlewares registered with After see the response, last registered first
+		after, _ := ctx.Locals(KEY_AFTER).([]func(*fiber.Ctx))
+		ctx.Locals(KEY_AFTER, nil)
+		for i := len(after) - 1; i >= 0; i-- {
+			after[i](ctx)
+		}
+
+		return err
 	}
+
+	response := bodyType[R](raw)
+	openapi.Record(h, openapi.Usecase{
+		Request:      reflect.TypeFor[T](),
+		Response:     response,
+		ContentTypes: contentTypes(response),
+		Status:       successStatus,
+		Middlewares:  ms,
+	})
+	return h
+}
+
+// Doc documents the operation of a Usecase, e.g.
+//
+//	routes.Usecase(h.list, fiber.StatusOK, routes.Doc(openapi.Summary("List the jobs"), openapi.Tags("jobs")))
+func Doc(opts ...openapi.Option) fiber.Handler {
+	return openapi.Annotate(func(ctx *fiber.Ctx) error {
+		return Next(ctx)
+	}, opts...)
 }
@@ -1,9 +1,55 @@
 package routes
 
-import "github.com/go-playground/validator/v10"
+import (
+	"context"
+
+	"github.com/gianglt2198/platforms/pkg/validation"
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	// Enum is implemented by the types checked by the enum rule, see validation.Enum
+	Enum = validation.Enum
+
+	// RequestValidator is implemented by the requests needing checks across their fields
+	// or against the request, see validation.RequestValidator
+	RequestValidator = validation.RequestValidator
+)
+
+// Validator returns the validator shared by the requests of every transport, see
+// validation.Validator for its rules
+func Validator() *validator.Validate {
+	return validation.Validator()
+}
+
+// RegisterRule adds a validation rule to the shared validator, it must be called at
+// startup before serving requests
+func RegisterRule(tag string, fn validator.Func) error {
+	return validation.RegisterRule(tag, fn)
+}
+
+// RegisterStructRule adds a check across the fields of types to the shared validator, it
+// must be called at startup before serving requests
+func RegisterStructRule(fn validator.StructLevelFunc, types ...any) {
+	validation.RegisterStructRule(fn, types...)
+}
 
 func ValidateStruct(data interface{}) error {
-	v := validator.New(validator.WithRequiredStructEnabled())
+	return validation.Struct(data)
+}
+
+// ValidateRequest checks the validate tags of data, then its RequestValidator hook
+func ValidateRequest(ctx context.Context, data any) error {
+	return validation.Request(ctx, data)
+}
+
+// Validate checks input like ValidateRequest, see validation.Validate
+func Validate[T any](ctx context.Context, input T) error {
+	return validation.Validate(ctx, input)
+}
 
-	return v.Struct(data)
+// FieldPath returns the path of a failed field without the name of the root struct,
+// e.g. items[0].name
+func FieldPath(fe validator.FieldError) string {
+	return validation.FieldPath(fe)
 }
@@ -0,0 +1,215 @@
+package stream
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gianglt2198/platforms/common"
+	mynats "github.com/gianglt2198/platforms/pkg/nats"
+	core "github.com/gianglt2198/platforms/services/ed"
+	"github.com/nats-io/nats.go"
+)
+
+// ErrExpired is returned by Feed.Since when the events after an id are no longer known, the
+// client is then sent a reset event
+var ErrExpired = errors.New("stream: events expired")
+
+type (
+	// Event is a message of a subject pushed to the clients
+	Event struct {
+		// ID orders the events of a feed, the clients resume after it with Last-Event-ID
+		ID uint64
+		// Epoch namespaces the ids of a feed whose ids are not global, like the ones of a
+		// BrokerFeed local to its replica. The id sent is "<epoch>-<id>"
+		Epoch string
+		// Name is the type of the event, the subject of the message or EventReset
+		Name    string
+		Subject string
+		Data    []byte
+		Header  nats.Header
+		// Principal published the message, when its header carries one
+		Principal *common.Principal
+	}
+
+	// Feed delivers the events of the subjects to a Hub
+	Feed interface {
+		// Subscribe delivers the events of subject published from now on to fn until stop
+		Subscribe(ctx context.Context, subject string, fn func(ev *Event)) (stop func() error, err error)
+		// Since returns the events of subject after the event id of epoch, ErrExpired when
+		// they are no longer known or the epoch is not the one of the feed
+		Since(ctx context.Context, subject, epoch string, id uint64) ([]*Event, error)
+	}
+
+	// Subscriber is the part of MqBroker used by BrokerFeed
+	Subscriber interface {
+		Subscribe(ctx context.Context, subject string, handler mynats.MsgHandler, configs ...core.SubscribeConfig) (*core.Subscription, error)
+	}
+
+	// BrokerFeedConfig holds configuration for BrokerFeed
+	BrokerFeedConfig struct {
+		// Size is the number of events kept per subject to resume from, 256 by default
+		Size int
+	}
+
+	// BrokerFeed subscribes to core NATS through MqBroker on every replica and keeps the last
+	// events in memory. The ids are local to the feed and carry its epoch, a client resuming
+	// on another replica or after a restart gets a reset, use a JetStreamFeed when the
+	// connections are not sticky
+	BrokerFeed struct {
+		broker Subscriber
+		cfg    BrokerFeedConfig
+		epoch  string
+
+		mu    sync.Mutex
+		seq   uint64
+		rings map[string]*ring
+	}
+
+	// ring holds the last events of a subject
+	ring struct {
+		// start is the sequence when the subscription started, the later events are kept
+		start uint64
+		// evicted is the id of the last event dropped to make room
+		evicted uint64
+		events  []*Event
+	}
+)
+
+// DefaultBrokerFeedConfig returns the default configuration
+func DefaultBrokerFeedConfig() BrokerFeedConfig {
+	return BrokerFeedConfig{
+		Size: 256,
+	}
+}
+
+func (m BrokerFeedConfig) apply(cfg *BrokerFeedConfig) {
+	if m.Size > 0 {
+		cfg.Size = m.Size
+	}
+}
+
+func NewBrokerFeed(broker Subscriber, configs ...BrokerFeedConfig) *BrokerFeed {
+	cfg := DefaultBrokerFeedConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	epoch := make([]byte, 6)
+	_, _ = rand.Read(epoch)
+
+	return &BrokerFeed{
+		broker: broker,
+		cfg:    cfg,
+		epoch:  hex.EncodeToString(epoch),
+		rings:  map[string]*ring{},
+	}
+}
+
+func (f *BrokerFeed) Subscribe(ctx context.Context, subject string, fn func(ev *Event)) (func() error, error) {
+	f.mu.Lock()
+	r := &ring{start: f.seq}
+	f.rings[subject] = r
+	f.mu.Unlock()
+
+	forget := func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.rings[subject] == r {
+			delete(f.rings, subject)
+		}
+	}
+
+	// Every replica pushes to its own clients, so none joins a queue group
+	sub, err := f.broker.Subscribe(ctx, subject, func(ctx context.Context, msg *nats.Msg) error {
+		ev := &Event{
+			Epoch:   f.epoch,
+			Name:    msg.Subject,
+			Subject: msg.Subject,
+			Data:    msg.Data,
+			Header:  msg.Header,
+		}
+		ev.Principal, _ = common.PrincipalFromContext(ctx)
+
+		f.mu.Lock()
+		f.seq++
+		ev.ID = f.seq
+		r.events = append(r.events, ev)
+		if len(r.events) > f.cfg.Size {
+			r.evicted = r.events[0].ID
+			r.events[0] = nil
+			r.events = r.events[1:]
+		}
+		f.mu.Unlock()
+
+		fn(ev)
+		return nil
+	}, core.SubscribeConfig{Broadcast: true})
+	if err != nil {
+		forget()
+		return nil, err
+	}
+
+	return func() error {
+		forget()
+		return sub.Unsubscribe()
+	}, nil
+}
+
+func (f *BrokerFeed) Since(_ context.Context, subject, epoch string, id uint64) ([]*Event, error) {
+	// An id of another replica or before a restart is unknown
+	if epoch != f.epoch {
+		return nil, ErrExpired
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r, ok := f.rings[subject]
+	if !ok || id < r.start || id < r.evicted || id > f.seq {
+		return nil, ErrExpired
+	}
+
+	var events []*Event
+	for _, ev := range r.events {
+		if ev.ID > id {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// formatID returns the id sent for ev, "" when it has none
+func formatID(ev *Event) string {
+	if ev.ID == 0 {
+		return ""
+	}
+	id := strconv.FormatUint(ev.ID, 10)
+	if ev.Epoch != "" {
+		id = ev.Epoch + "-" + id
+	}
+	return id
+}
+
+// parseID reads a Last-Event-ID into its epoch and its id, false when there is none
+func parseID(value string) (string, uint64, bool) {
+	if value == "" {
+		return "", 0, false
+	}
+
+	var epoch string
+	if i := strings.LastIndexByte(value, '-'); i >= 0 {
+		epoch, value = value[:i], value[i+1:]
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		// Not an id of a feed, past the last one so the client gets a reset
+		return epoch, math.MaxUint64, true
+	}
+	return epoch, id, true
+}
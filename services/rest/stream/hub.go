@@ -0,0 +1,332 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gianglt2198/platforms/common"
+)
+
+// EventReset is sent to a client whose missed events are no longer known, it reloads its
+// state instead
+const EventReset = "reset"
+
+var (
+	// errSlow ends the stream of a client whose buffer is full, it resumes after reconnecting
+	errSlow = errors.New("stream: slow client")
+	// errClosed ends the streams of a closed Hub
+	errClosed = errors.New("stream: hub closed")
+)
+
+type (
+	// HubConfig holds configuration for Hub
+	HubConfig struct {
+		// ServiceName labels the metrics
+		ServiceName string
+		// Linger keeps the subscription of a subject without clients, so the clients
+		// reconnecting meanwhile resume from the feed. 30 seconds by default
+		Linger time.Duration
+	}
+
+	// Hub shares the subscriptions of the subjects between the clients connected to the
+	// replica, the events are pushed by SSE or WebSocket
+	Hub struct {
+		feed    Feed
+		cfg     HubConfig
+		metrics *streamMetrics
+
+		ctx    context.Context
+		cancel context.CancelFunc
+
+		mu     sync.RWMutex
+		topics map[string]*topic
+	}
+
+	// topic is a subject subscribed for its clients
+	topic struct {
+		clients map[*client]struct{}
+		stop    func() error
+		linger  *time.Timer
+	}
+
+	// client is a connection streaming events
+	client struct {
+		principal *common.Principal
+		filter    func(p *common.Principal, ev *Event) bool
+		events    chan *Event
+		// slow is closed when an event did not fit in events
+		slow     chan struct{}
+		slowOnce sync.Once
+	}
+
+	// session is a stream prepared from a request, it outlives the fiber context
+	session struct {
+		name      string
+		subjects  []string
+		principal *common.Principal
+		resume    bool
+		epoch     string
+		lastID    uint64
+		cfg       StreamConfig
+	}
+)
+
+// DefaultHubConfig returns the default configuration
+func DefaultHubConfig() HubConfig {
+	return HubConfig{
+		ServiceName: "platform-app",
+		Linger:      30 * time.Second,
+	}
+}
+
+func (m HubConfig) apply(cfg *HubConfig) {
+	if m.ServiceName != "" {
+		cfg.ServiceName = m.ServiceName
+	}
+	if m.Linger != 0 {
+		cfg.Linger = m.Linger
+	}
+}
+
+// NewHub returns a hub over feed, e.g. NewBrokerFeed(broker)
+func NewHub(feed Feed, configs ...HubConfig) *Hub {
+	cfg := DefaultHubConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	// The subscriptions log with the context of the hub, which carries a request id like the
+	// job workers
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), "requestId", "stream"))
+	return &Hub{
+		feed:    feed,
+		cfg:     cfg,
+		metrics: getMetrics(),
+		ctx:     ctx,
+		cancel:  cancel,
+		topics:  map[string]*topic{},
+	}
+}
+
+// Close ends the streams and the subscriptions
+func (h *Hub) Close() error {
+	h.cancel()
+
+	h.mu.Lock()
+	var stops []func() error
+	for subject, t := range h.topics {
+		if t.linger != nil {
+			t.linger.Stop()
+		}
+		stops = append(stops, t.stop)
+		delete(h.topics, subject)
+	}
+	h.mu.Unlock()
+
+	return stopAll(stops)
+}
+
+func (h *Hub) join(subjects []string, c *client) error {
+	h.mu.Lock()
+
+	if h.ctx.Err() != nil {
+		h.mu.Unlock()
+		return errClosed
+	}
+
+	for i, subject := range subjects {
+		t, ok := h.topics[subject]
+		if !ok {
+			stop, err := h.feed.Subscribe(h.ctx, subject, func(ev *Event) {
+				h.publish(subject, ev)
+			})
+			if err != nil {
+				stops := h.leaveLocked(subjects[:i], c)
+				h.mu.Unlock()
+				_ = stopAll(stops)
+				return err
+			}
+			t = &topic{clients: map[*client]struct{}{}, stop: stop}
+			h.topics[subject] = t
+		}
+
+		if t.linger != nil {
+			t.linger.Stop()
+			t.linger = nil
+		}
+		t.clients[c] = struct{}{}
+	}
+
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *Hub) leave(subjects []string, c *client) {
+	h.mu.Lock()
+	stops := h.leaveLocked(subjects, c)
+	h.mu.Unlock()
+
+	_ = stopAll(stops)
+}
+
+// leaveLocked removes c from the subjects and returns the subscriptions to stop. They are
+// stopped once h.mu is released, stopping waits for the deliveries which take h.mu
+func (h *Hub) leaveLocked(subjects []string, c *client) []func() error {
+	var stops []func() error
+	for _, subject := range subjects {
+		t, ok := h.topics[subject]
+		if !ok {
+			continue
+		}
+
+		delete(t.clients, c)
+		if len(t.clients) > 0 || t.linger != nil {
+			continue
+		}
+		if h.cfg.Linger < 0 {
+			delete(h.topics, subject)
+			stops = append(stops, t.stop)
+			continue
+		}
+
+		t.linger = time.AfterFunc(h.cfg.Linger, func() {
+			h.mu.Lock()
+			if h.topics[subject] != t || len(t.clients) > 0 {
+				h.mu.Unlock()
+				return
+			}
+			delete(h.topics, subject)
+			h.mu.Unlock()
+
+			_ = t.stop()
+		})
+	}
+	return stops
+}
+
+func stopAll(stops []func() error) error {
+	var errs []error
+	for _, stop := range stops {
+		errs = append(errs, stop())
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Hub) publish(subject string, ev *Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	t, ok := h.topics[subject]
+	if !ok {
+		return
+	}
+	for c := range t.clients {
+		if c.filter != nil && !c.filter(c.principal, ev) {
+			continue
+		}
+
+		select {
+		case c.events <- ev:
+		default:
+			// Waiting would hold the subscription of every client, the slow one resumes
+			// after reconnecting instead
+			c.slowOnce.Do(func() {
+				close(c.slow)
+				h.metrics.slow(h.ctx, h.cfg.ServiceName, subject)
+			})
+		}
+	}
+}
+
+// serve streams the events of s until ctx is done or send fails, the missed events first
+// when the client resumes
+func (h *Hub) serve(ctx context.Context, transport string, s *session, send func(ev *Event) error, heartbeat func() error) error {
+	c := &client{
+		principal: s.principal,
+		filter:    s.cfg.Filter,
+		events:    make(chan *Event, s.cfg.Buffer),
+		slow:      make(chan struct{}),
+	}
+
+	if err := h.join(s.subjects, c); err != nil {
+		return err
+	}
+	defer h.leave(s.subjects, c)
+
+	h.metrics.connected(h.ctx, h.cfg.ServiceName, s.name, transport)
+	defer h.metrics.disconnected(h.ctx, h.cfg.ServiceName, s.name, transport)
+
+	count := func(err error) error {
+		if err == nil {
+			h.metrics.sent(h.ctx, h.cfg.ServiceName, s.name, transport)
+		}
+		return err
+	}
+
+	// The live events received meanwhile wait in the buffer, the ones replayed are skipped
+	var replayed uint64
+	if s.resume {
+		missed, err := h.missed(ctx, s)
+		if errors.Is(err, ErrExpired) {
+			err = count(send(&Event{Name: EventReset}))
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, ev := range missed {
+			if c.filter != nil && !c.filter(c.principal, ev) {
+				continue
+			}
+			if err := count(send(ev)); err != nil {
+				return err
+			}
+			replayed = ev.ID
+		}
+	}
+
+	ticker := time.NewTicker(s.cfg.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-h.ctx.Done():
+			return errClosed
+		case <-c.slow:
+			return errSlow
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		case ev := <-c.events:
+			if ev.ID <= replayed {
+				continue
+			}
+			if err := count(send(ev)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// missed returns the events of the subjects after the last id of s, in order
+func (h *Hub) missed(ctx context.Context, s *session) ([]*Event, error) {
+	var missed []*Event
+	for _, subject := range s.subjects {
+		events, err := h.feed.Since(ctx, subject, s.epoch, s.lastID)
+		if err != nil {
+			return nil, err
+		}
+		missed = append(missed, events...)
+	}
+
+	sort.Slice(missed, func(i, j int) bool {
+		return missed[i].ID < missed[j].ID
+	})
+	return missed, nil
+}
@@ -0,0 +1,172 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gianglt2198/platforms/common"
+	mynats "github.com/gianglt2198/platforms/pkg/nats"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type (
+	// JetStreamFeedConfig holds configuration for JetStreamFeed
+	JetStreamFeedConfig struct {
+		// MaxReplay bounds the events replayed to a client resuming, a client further behind
+		// gets a reset. 1000 by default
+		MaxReplay int
+		// FetchWait bounds the wait of each replayed event, 2 seconds by default
+		FetchWait time.Duration
+		// Signer verifies the principals of the events at the time the stream stored them, see
+		// MqBroker.PrincipalSigner. The events have no principal when nil
+		Signer *mynats.PrincipalSigner
+	}
+
+	// JetStreamFeed reads the subjects from a JetStream stream capturing them, the ids are the
+	// sequences of the stream so a client resumes on any replica while the stream keeps the
+	// events. Get the JetStream context with MqBroker.JetStream
+	JetStreamFeed struct {
+		js     jetstream.JetStream
+		stream string
+		cfg    JetStreamFeedConfig
+	}
+)
+
+// DefaultJetStreamFeedConfig returns the default configuration
+func DefaultJetStreamFeedConfig() JetStreamFeedConfig {
+	return JetStreamFeedConfig{
+		MaxReplay: 1000,
+		FetchWait: 2 * time.Second,
+	}
+}
+
+func (m JetStreamFeedConfig) apply(cfg *JetStreamFeedConfig) {
+	if m.MaxReplay > 0 {
+		cfg.MaxReplay = m.MaxReplay
+	}
+	if m.FetchWait > 0 {
+		cfg.FetchWait = m.FetchWait
+	}
+	if m.Signer != nil {
+		cfg.Signer = m.Signer
+	}
+}
+
+func NewJetStreamFeed(js jetstream.JetStream, stream string, configs ...JetStreamFeedConfig) *JetStreamFeed {
+	cfg := DefaultJetStreamFeedConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+
+	return &JetStreamFeed{
+		js:     js,
+		stream: stream,
+		cfg:    cfg,
+	}
+}
+
+func (f *JetStreamFeed) Subscribe(ctx context.Context, subject string, fn func(ev *Event)) (func() error, error) {
+	consumer, err := f.js.OrderedConsumer(ctx, f.stream, jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{subject},
+		DeliverPolicy:  jetstream.DeliverNewPolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	consuming, err := consumer.Consume(func(msg jetstream.Msg) {
+		if ev, err := f.event(ctx, msg); err == nil {
+			fn(ev)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		consuming.Stop()
+		return nil
+	}, nil
+}
+
+func (f *JetStreamFeed) Since(ctx context.Context, subject, epoch string, id uint64) ([]*Event, error) {
+	// The sequences of the stream are global, an id with an epoch comes from a BrokerFeed
+	if epoch != "" {
+		return nil, ErrExpired
+	}
+
+	stream, err := f.js.Stream(ctx, f.stream)
+	if err != nil {
+		return nil, err
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The events after id were deleted, or id is not a sequence of the stream
+	if id+1 < info.State.FirstSeq || id > info.State.LastSeq {
+		return nil, ErrExpired
+	}
+
+	last, err := stream.GetLastMsgForSubject(ctx, subject)
+	if errors.Is(err, jetstream.ErrMsgNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if last.Sequence <= id {
+		return nil, nil
+	}
+
+	consumer, err := stream.OrderedConsumer(ctx, jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{subject},
+		DeliverPolicy:  jetstream.DeliverByStartSequencePolicy,
+		OptStartSeq:    id + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	for {
+		msg, err := consumer.Next(jetstream.FetchMaxWait(f.cfg.FetchWait))
+		if err != nil {
+			return nil, err
+		}
+
+		ev, err := f.event(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+
+		if ev.ID >= last.Sequence {
+			return events, nil
+		}
+		if len(events) >= f.cfg.MaxReplay {
+			return nil, ErrExpired
+		}
+	}
+}
+
+func (f *JetStreamFeed) event(ctx context.Context, msg jetstream.Msg) (*Event, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	ev := &Event{
+		ID:      meta.Sequence.Stream,
+		Name:    msg.Subject(),
+		Subject: msg.Subject(),
+		Data:    msg.Data(),
+		Header:  msg.Headers(),
+	}
+	ev.Principal, _ = common.PrincipalFromContext(f.cfg.Signer.ExtractAt(
+		common.WithPrincipal(ctx, nil), msg.Subject(), msg.Headers(), meta.Timestamp,
+	))
+	return ev, nil
+}
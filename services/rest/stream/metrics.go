@@ -0,0 +1,109 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/gianglt2198/platforms/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type streamMetrics struct {
+	// *** counter ***
+	connectionsCounter       metric.Int64Counter
+	activeConnectionsCounter metric.Int64UpDownCounter
+	eventsCounter            metric.Int64Counter
+	slowClientsCounter       metric.Int64Counter
+}
+
+var (
+	metrics     *streamMetrics
+	metricsOnce sync.Once
+)
+
+func getMetrics() *streamMetrics {
+	metricsOnce.Do(func() {
+		metrics = newStreamMetrics()
+	})
+	return metrics
+}
+
+func newStreamMetrics() *streamMetrics {
+	m := observability.Meter("stream")
+	s := &streamMetrics{}
+
+	var err error
+
+	s.connectionsCounter, err = m.Int64Counter(
+		"stream_connections_total",
+		metric.WithDescription("Total number of streaming connections opened."),
+		metric.WithUnit("{connections}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter stream connections counter failed: %v", err)
+	}
+
+	s.activeConnectionsCounter, err = m.Int64UpDownCounter(
+		"stream_active_connections",
+		metric.WithDescription("Number of open streaming connections."),
+		metric.WithUnit("{connections}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter stream active connections counter failed: %v", err)
+	}
+
+	s.eventsCounter, err = m.Int64Counter(
+		"stream_events_sent_total",
+		metric.WithDescription("Total number of events sent to the streaming clients."),
+		metric.WithUnit("{events}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter stream events counter failed: %v", err)
+	}
+
+	s.slowClientsCounter, err = m.Int64Counter(
+		"stream_slow_clients_total",
+		metric.WithDescription("Total number of streaming clients disconnected for falling behind."),
+		metric.WithUnit("{connections}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter stream slow clients counter failed: %v", err)
+	}
+
+	return s
+}
+
+func (s *streamMetrics) connected(ctx context.Context, service, name, transport string) {
+	attrs := metric.WithAttributes(
+		attribute.String("service.name", service),
+		attribute.String("stream.name", name),
+		attribute.String("stream.transport", transport),
+	)
+	s.connectionsCounter.Add(ctx, 1, attrs)
+	s.activeConnectionsCounter.Add(ctx, 1, attrs)
+}
+
+func (s *streamMetrics) disconnected(ctx context.Context, service, name, transport string) {
+	s.activeConnectionsCounter.Add(ctx, -1, metric.WithAttributes(
+		attribute.String("service.name", service),
+		attribute.String("stream.name", name),
+		attribute.String("stream.transport", transport),
+	))
+}
+
+func (s *streamMetrics) sent(ctx context.Context, service, name, transport string) {
+	s.eventsCounter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("service.name", service),
+		attribute.String("stream.name", name),
+		attribute.String("stream.transport", transport),
+	))
+}
+
+func (s *streamMetrics) slow(ctx context.Context, service, subject string) {
+	s.slowClientsCounter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("service.name", service),
+		attribute.String("messaging.destination", subject),
+	))
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gofiber/fiber/v2"
+)
+
+const MIMEEventStream = "text/event-stream"
+
+// SSE streams the events of the subjects as Server-Sent Events, the id of an event resumes
+// the stream with Last-Event-ID. The EventSource of the browsers authenticates with a
+// short-lived token in the query or a cookie:
+//
+//	router.Get("/events", middleware.Authentication(middleware.AuthConfig{
+//		Keys: keys,
+//		TokenSources: []middleware.TokenSource{
+//			middleware.TokenFromQuery(middleware.QueryAccessToken, time.Minute),
+//			middleware.TokenFromCookie("access_token"),
+//		},
+//	}), hub.SSE(stream.StreamConfig{
+//		Subjects: stream.Subjects("notifications.{tenant}.{principal}"),
+//	}))
+func (h *Hub) SSE(configs ...StreamConfig) fiber.Handler {
+	cfg := newStreamConfig(configs)
+
+	return openapi.Annotate(func(c *fiber.Ctx) error {
+		s, err := h.session(c, cfg)
+		if err != nil {
+			return err
+		}
+
+		c.Set(fiber.HeaderContentType, MIMEEventStream)
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		// Proxies buffering the response would hold the events
+		c.Set("X-Accel-Buffering", "no")
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			w.WriteString("retry: " + strconv.FormatInt(cfg.Retry.Milliseconds(), 10) + "\n\n")
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			// A write fails once the client is gone, which ends the stream
+			_ = h.serve(h.ctx, "sse", s, func(ev *Event) error {
+				writeEvent(w, ev)
+				return w.Flush()
+			}, func() error {
+				w.WriteString(": heartbeat\n\n")
+				return w.Flush()
+			})
+		})
+		return nil
+	}, sseDocs)
+}
+
+func writeEvent(w *bufio.Writer, ev *Event) {
+	if id := formatID(ev); id != "" {
+		w.WriteString("id: " + id + "\n")
+	}
+	w.WriteString("event: " + ev.Name + "\n")
+
+	// Each line of the data is a data field, the client joins them back
+	for _, line := range bytes.Split(ev.Data, []byte("\n")) {
+		w.WriteString("data: ")
+		w.Write(bytes.TrimSuffix(line, []byte("\r")))
+		w.WriteString("\n")
+	}
+	w.WriteString("\n")
+}
+
+func sseDocs(op *openapi.Operation) {
+	op.Parameters = append(op.Parameters, tokenParameter(), &openapi.Parameter{
+		Name:        HeaderLastEventID,
+		In:          "header",
+		Description: "Id of the last event received, the stream resumes after it",
+		Schema:      &openapi.Schema{Type: "string"},
+	})
+	op.AddResponse("200", &openapi.Response{
+		Description: "Stream of the events, the subject is the event name and the message the data",
+		Content: map[string]*openapi.MediaType{
+			MIMEEventStream: {Schema: &openapi.Schema{Type: "string"}},
+		},
+	})
+	op.AddResponse("401", openapi.ProblemResponse(http.StatusText(http.StatusUnauthorized)))
+}
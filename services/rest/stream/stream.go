@@ -0,0 +1,204 @@
+package stream
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gianglt2198/platforms/common"
+	myerrors "github.com/gianglt2198/platforms/errors"
+	"github.com/gianglt2198/platforms/middleware"
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderLastEventID = "Last-Event-ID"
+	// QueryLastEventID resumes the clients which cannot set the header, like the browser
+	// WebSockets
+	QueryLastEventID = "last_event_id"
+)
+
+type (
+	// SubjectsFunc returns the subjects streamed to the caller of a request
+	SubjectsFunc func(c *fiber.Ctx, p *common.Principal) ([]string, error)
+
+	// StreamConfig holds configuration for the SSE and WebSocket endpoints
+	StreamConfig struct {
+		// Name labels the metrics of the endpoint, the path of the route by default
+		Name string
+		// Subjects are the subjects of the caller, see Subjects. Required
+		Subjects SubjectsFunc
+		// Filter drops the events the caller must not see, e.g. SameTenant
+		Filter func(p *common.Principal, ev *Event) bool
+		// Anonymous streams to the requests without principal, rejected with auth.001 by
+		// default. The browsers cannot set the Authorization header, the authentication reads
+		// their token with middleware.TokenFromQuery or middleware.TokenFromCookie
+		Anonymous bool
+		// Origins are the origins of the pages allowed to open the WebSockets, the origin of
+		// the request only by default. "*" allows any, which exposes the streams authenticated
+		// by a cookie to any site
+		Origins []string
+		// Heartbeat is the interval of the heartbeats keeping the idle connections open
+		// through the proxies, 15 seconds by default
+		Heartbeat time.Duration
+		// Buffer is the number of events waiting for a client, a client falling further behind
+		// is disconnected and resumes after reconnecting. 64 by default
+		Buffer int
+		// Retry is the reconnection delay sent to the SSE clients, 3 seconds by default
+		Retry time.Duration
+	}
+)
+
+// DefaultStreamConfig returns the default configuration
+func DefaultStreamConfig() StreamConfig {
+	return StreamConfig{
+		Heartbeat: 15 * time.Second,
+		Buffer:    64,
+		Retry:     3 * time.Second,
+	}
+}
+
+func (m StreamConfig) apply(cfg *StreamConfig) {
+	if m.Name != "" {
+		cfg.Name = m.Name
+	}
+	if m.Subjects != nil {
+		cfg.Subjects = m.Subjects
+	}
+	if m.Filter != nil {
+		cfg.Filter = m.Filter
+	}
+	if m.Anonymous {
+		cfg.Anonymous = true
+	}
+	if len(m.Origins) > 0 {
+		cfg.Origins = m.Origins
+	}
+	if m.Heartbeat > 0 {
+		cfg.Heartbeat = m.Heartbeat
+	}
+	if m.Buffer > 0 {
+		cfg.Buffer = m.Buffer
+	}
+	if m.Retry > 0 {
+		cfg.Retry = m.Retry
+	}
+}
+
+func newStreamConfig(configs []StreamConfig) StreamConfig {
+	cfg := DefaultStreamConfig()
+	for _, c := range configs {
+		c.apply(&cfg)
+	}
+	if cfg.Subjects == nil {
+		panic("stream: StreamConfig.Subjects is required")
+	}
+	return cfg
+}
+
+// Subjects streams the subjects of the patterns, {principal} and {tenant} are replaced by the
+// id and the tenant of the caller, e.g. "notifications.{tenant}.{principal}". A caller
+// without them is refused
+func Subjects(patterns ...string) SubjectsFunc {
+	return func(_ *fiber.Ctx, p *common.Principal) ([]string, error) {
+		subjects := make([]string, 0, len(patterns))
+		for _, pattern := range patterns {
+			subject := pattern
+			for placeholder, value := range map[string]func() string{
+				"{principal}": func() string { return p.ID },
+				"{tenant}":    func() string { return p.TenantID },
+			} {
+				if !strings.Contains(subject, placeholder) {
+					continue
+				}
+				if p == nil {
+					return nil, myerrors.New("auth.001", "")
+				}
+				token := value()
+				if !validToken(token) {
+					return nil, myerrors.MQAccessDenined()
+				}
+				subject = strings.ReplaceAll(subject, placeholder, token)
+			}
+			subjects = append(subjects, subject)
+		}
+		return subjects, nil
+	}
+}
+
+// validToken reports whether value is a single token of a subject, it must not widen the
+// subscription with a wildcard
+func validToken(value string) bool {
+	return value != "" && !strings.ContainsAny(value, ".*> \t\r\n")
+}
+
+// SameTenant lets through the events published by a principal of the tenant of the caller
+func SameTenant(p *common.Principal, ev *Event) bool {
+	return p != nil && ev.Principal != nil && p.TenantID == ev.Principal.TenantID
+}
+
+// SamePrincipal lets through the events published on behalf of the caller
+func SamePrincipal(p *common.Principal, ev *Event) bool {
+	return p != nil && ev.Principal != nil && p.ID == ev.Principal.ID
+}
+
+// allowedOrigin reports whether the page opening the stream is allowed, a request without
+// Origin does not come from a browser
+func allowedOrigin(c *fiber.Ctx, origins []string) bool {
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	if len(origins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == string(c.Request().Host())
+	}
+	return slices.Contains(origins, "*") || slices.Contains(origins, origin)
+}
+
+// tokenParameter documents the token of the browsers, read by middleware.TokenFromQuery
+func tokenParameter() *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        middleware.QueryAccessToken,
+		In:          "query",
+		Description: "Short-lived access token of the clients which cannot set the Authorization header",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
+
+// session prepares the stream of the request, the fiber context is released before the
+// events are streamed
+func (h *Hub) session(c *fiber.Ctx, cfg StreamConfig) (*session, error) {
+	p, _ := middleware.CurrentPrincipal(c)
+	if p == nil && !cfg.Anonymous {
+		return nil, myerrors.New("auth.001", "")
+	}
+
+	subjects, err := cfg.Subjects(c, p)
+	if err != nil {
+		return nil, err
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = c.Route().Path
+	}
+
+	lastID := c.Get(HeaderLastEventID)
+	if lastID == "" {
+		lastID = c.Query(QueryLastEventID)
+	}
+	epoch, id, resume := parseID(lastID)
+
+	return &session{
+		name:      name,
+		subjects:  subjects,
+		principal: p,
+		resume:    resume,
+		epoch:     epoch,
+		lastID:    id,
+		cfg:       cfg,
+	}, nil
+}
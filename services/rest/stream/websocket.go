@@ -0,0 +1,119 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gianglt2198/platforms/services/rest/openapi"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+const keySession = "stream_session"
+
+// frame is an event sent over a WebSocket
+type frame struct {
+	ID    string `json:"id,omitempty"`
+	Event string `json:"event"`
+	Data  any    `json:"data,omitempty"`
+}
+
+// WebSocket streams the events of the subjects over a WebSocket, each event is a text frame
+// {"id", "event", "data"}. The id resumes the stream with the last_event_id query parameter.
+// The browsers authenticate with a short-lived token in the query:
+//
+//	router.Get("/ws", middleware.Authentication(middleware.AuthConfig{
+//		Keys:         keys,
+//		TokenSources: []middleware.TokenSource{middleware.TokenFromQuery(middleware.QueryAccessToken, time.Minute)},
+//	}), hub.WebSocket(stream.StreamConfig{
+//		Subjects: stream.Subjects("notifications.{tenant}.{principal}"),
+//	}))
+func (h *Hub) WebSocket(configs ...StreamConfig) fiber.Handler {
+	cfg := newStreamConfig(configs)
+
+	// The origin is checked before the upgrade, against the origin of the request when
+	// cfg.Origins is empty which the websocket package cannot express
+	wsConfig := websocket.Config{Origins: cfg.Origins}
+	if len(wsConfig.Origins) == 0 {
+		wsConfig.Origins = []string{"*"}
+	}
+
+	upgrade := websocket.New(func(conn *websocket.Conn) {
+		s := conn.Locals(keySession).(*session)
+
+		// The client sends nothing, reading notices it closed the connection
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			defer cancel()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		err := h.serve(ctx, "websocket", s, func(ev *Event) error {
+			_ = conn.SetWriteDeadline(time.Now().Add(cfg.Heartbeat))
+			return conn.WriteJSON(newFrame(ev))
+		}, func() error {
+			return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(cfg.Heartbeat))
+		})
+
+		code, reason := websocket.CloseNormalClosure, ""
+		switch {
+		case errors.Is(err, errSlow):
+			code, reason = websocket.CloseTryAgainLater, "slow client"
+		case errors.Is(err, errClosed):
+			code = websocket.CloseGoingAway
+		}
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	}, wsConfig)
+
+	return openapi.Annotate(func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		if !allowedOrigin(c, cfg.Origins) {
+			return fiber.NewError(fiber.StatusForbidden, "origin is not allowed")
+		}
+
+		s, err := h.session(c, cfg)
+		if err != nil {
+			return err
+		}
+
+		c.Locals(keySession, s)
+		return upgrade(c)
+	}, websocketDocs)
+}
+
+func newFrame(ev *Event) *frame {
+	f := &frame{ID: formatID(ev), Event: ev.Name}
+	if len(ev.Data) > 0 {
+		if json.Valid(ev.Data) {
+			f.Data = json.RawMessage(ev.Data)
+		} else {
+			f.Data = string(ev.Data)
+		}
+	}
+	return f
+}
+
+func websocketDocs(op *openapi.Operation) {
+	op.Parameters = append(op.Parameters, tokenParameter(), &openapi.Parameter{
+		Name:        QueryLastEventID,
+		In:          "query",
+		Description: "Id of the last event received, the stream resumes after it",
+		Schema:      &openapi.Schema{Type: "string"},
+	})
+	op.AddResponse("101", &openapi.Response{
+		Description: "WebSocket of the events, each a text frame {id, event, data}",
+	})
+	op.AddResponse("401", openapi.ProblemResponse(http.StatusText(http.StatusUnauthorized)))
+	op.AddResponse("403", openapi.ProblemResponse(http.StatusText(http.StatusForbidden)))
+	op.AddResponse("426", openapi.ProblemResponse(http.StatusText(http.StatusUpgradeRequired)))
+}